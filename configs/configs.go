@@ -9,6 +9,7 @@ type Config struct {
 	Redis RedisConfig       `mapstructure:"redis"`
 	Email EmailConfig       `mapstructure:"email"`
 	JWT   JWTConfig         `mapstructure:"jwt"`
+	Game  GameConfig        `mapstructure:"game"`
 }
 
 type ApplicationConfig struct {
@@ -35,6 +36,7 @@ type RedisConfig struct {
 	Port     int    `mapstructure:"port"`
 	Password string `mapstructure:"password"`
 	DB       int    `mapstructure:"db"`
+	WsBroker bool   `mapstructure:"ws-broker"`
 }
 
 type EmailConfig struct {
@@ -52,3 +54,15 @@ type KafkaConfig struct {
 type JWTConfig struct {
 	Secret string `mapstructure:"secret"`
 }
+
+// GameConfig RerollLimit、RerollCost 未配置时使用默认值，配置为0时表示禁止换题、换题不扣分
+type GameConfig struct {
+	RerollLimit *int `mapstructure:"reroll-limit"`
+	RerollCost  *int `mapstructure:"reroll-cost"`
+	// ReconnectGrace 团队房间断线重连宽限秒数
+	ReconnectGrace  int      `mapstructure:"reconnect-grace"`
+	ChatMaxLength   int      `mapstructure:"chat-max-length"`
+	ChatRateLimit   int      `mapstructure:"chat-rate-limit"`
+	ChatHistorySize int      `mapstructure:"chat-history-size"`
+	ChatFilterWords []string `mapstructure:"chat-filter-words"`
+}
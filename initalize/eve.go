@@ -11,6 +11,7 @@ func Eve() {
 	zlog.Warnf("开始释放资源！")
 	logic.StopCfQueue()
 	logic.StopSinglePlayerCron()
+	logic.StopWsBroker()
 	errRedis := global.Rdb.Close()
 	if errRedis != nil {
 		zlog.Errorf("Redis关闭失败 ：%v", errRedis.Error())
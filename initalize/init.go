@@ -36,6 +36,37 @@ func Init() {
 	// 对命令行参数进行处理
 	flags.Run()
 
+	// 写入默认成就定义
+	if err := logic.InitAchievements(); err != nil {
+		zlog.Warnf("初始化成就定义失败：%v", err)
+	}
+	// 写入默认团队房间模式
+	if err := logic.InitTeamRoomModes(); err != nil {
+		zlog.Warnf("初始化团队房间模式失败：%v", err)
+	}
+
+	// 迁移旧版本团队房间JSON数据
+	if err := logic.MigrateTeamRoomData(); err != nil {
+		zlog.Warnf("迁移团队房间数据失败：%v", err)
+	}
+
+	// 开启 ws-broker 且其他实例仍在运行时不结算房间，只接管租约已过期的团队房间，其余由持有租约的实例负责
+	if logic.HasLiveWsInstances() {
+		zlog.Warnf("检测到其他运行中的实例，跳过排行榜重建和房间结算")
+		logic.StartWsBroker()
+		logic.StartCfQueue()
+		logic.StartSinglePlayerCron()
+		if err := logic.StartAllActiveTeamRooms(); err != nil {
+			zlog.Warnf("初始化接管团队房间失败：%v", err)
+		}
+		return
+	}
+
+	// 根据数据库重建排行榜
+	if err := logic.RebuildLeaderboards(); err != nil {
+		zlog.Warnf("重建排行榜失败：%v", err)
+	}
+
 	// 关闭所有活跃的单人房间
 	err := logic.FinishAllActiveSinglePlayerRooms()
 	if err != nil {
@@ -43,6 +74,7 @@ func Init() {
 	}
 	logic.FinishAllActiveTeamRooms(context.Background())
 
+	logic.StartWsBroker()
 	logic.StartCfQueue()
 	logic.StartSinglePlayerCron()
 	err = logic.StartAllActiveTeamRooms()
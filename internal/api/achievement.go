@@ -0,0 +1,25 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"tgwp/log/zlog"
+	"tgwp/logic"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+func ListAchievements(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	resp, err := logic.NewAchievementLogic().ListAchievements(ctx)
+	response.Response(c, resp, err)
+}
+
+func ListUserAchievements(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.UserAchievementListReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewAchievementLogic().ListUserAchievements(ctx, req)
+	response.Response(c, resp, err)
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"tgwp/log/zlog"
+	"tgwp/logic"
+	"tgwp/response"
+	"tgwp/types"
+	"tgwp/utils/jwtUtils"
+)
+
+func GetDailyChallenge(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	resp, err := logic.NewDailyChallengeLogic().GetToday(ctx)
+	response.Response(c, resp, err)
+}
+
+func CreateDailyChallengeRoom(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	userID := jwtUtils.GetUserId(c)
+	resp, err := logic.NewDailyChallengeLogic().CreateRoom(ctx, userID)
+	response.Response(c, resp, err)
+}
+
+func GetDailyChallengeLeaderboard(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.DailyChallengeLeaderboardReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewDailyChallengeLogic().Leaderboard(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func GetDailyChallengeStreak(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.DailyChallengeStreakReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewDailyChallengeLogic().GetStreak(ctx, req)
+	response.Response(c, resp, err)
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"tgwp/log/zlog"
+	"tgwp/logic"
+	"tgwp/response"
+	"tgwp/types"
+	"tgwp/utils/jwtUtils"
+)
+
+func ListLeaderboard(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.LeaderboardListReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewLeaderboardLogic().List(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func GetLeaderboardRank(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.LeaderboardRankReq](c)
+	if err != nil {
+		return
+	}
+	req.UserID = jwtUtils.GetUserId(c)
+	resp, err := logic.NewLeaderboardLogic().GetRank(ctx, req)
+	response.Response(c, resp, err)
+}
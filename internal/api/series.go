@@ -0,0 +1,82 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"tgwp/log/zlog"
+	"tgwp/logic"
+	"tgwp/response"
+	"tgwp/types"
+	"tgwp/utils/jwtUtils"
+)
+
+func CreateSeries(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.SeriesCreateReq](c)
+	if err != nil {
+		return
+	}
+	req.UserID = jwtUtils.GetUserId(c)
+	resp, err := logic.NewSeriesLogic().CreateSeries(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func GetSeries(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.SeriesReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewSeriesLogic().GetSeries(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func ListSeries(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.SeriesListReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewSeriesLogic().ListSeries(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func GetSeriesStandings(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.SeriesReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewSeriesLogic().Standings(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func StartSeriesRound(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.SeriesRoundReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewSeriesLogic().NextRound(ctx, jwtUtils.GetUserId(c), jwtUtils.GetRole(c), req)
+	response.Response(c, resp, err)
+}
+
+func AddSeriesRoom(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.SeriesAddRoomReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewSeriesLogic().AddRoom(ctx, jwtUtils.GetUserId(c), jwtUtils.GetRole(c), req)
+	response.Response(c, resp, err)
+}
+
+func PlaySeriesRound(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.SeriesPlayReq](c)
+	if err != nil {
+		return
+	}
+	req.UserID = jwtUtils.GetUserId(c)
+	resp, err := logic.NewSeriesLogic().PlayRound(ctx, req)
+	response.Response(c, resp, err)
+}
@@ -36,3 +36,14 @@ func AbandonSinglePlayerRoom(c *gin.Context) {
 	resp, err := logic.NewSinglePlayerLogic().AbandonRoom(ctx, userID, req)
 	response.Response(c, resp, err)
 }
+
+func RerollSinglePlayerRoom(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.SinglePlayerRerollReq](c)
+	if err != nil {
+		return
+	}
+	userID := jwtUtils.GetUserId(c)
+	resp, err := logic.NewSinglePlayerLogic().RerollRoom(ctx, userID, req)
+	response.Response(c, resp, err)
+}
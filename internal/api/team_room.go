@@ -1,6 +1,9 @@
 package api
 
 import (
+	"fmt"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"tgwp/log/zlog"
 	"tgwp/logic"
@@ -40,8 +43,152 @@ func ListTeamRooms(c *gin.Context) {
 	response.Response(c, resp, err)
 }
 
+func ListUserTeamRooms(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.TeamRoomUserRoomsReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewTeamRoomLogic().ListUserRooms(ctx, req)
+	response.Response(c, resp, err)
+}
+
 func ListTeamRoomModes(c *gin.Context) {
 	ctx := zlog.GetCtxFromGin(c)
 	resp, err := logic.NewTeamRoomLogic().ListModes(ctx)
 	response.Response(c, resp, err)
 }
+
+func CreateTeamRoomMode(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.TeamRoomModeReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewTeamRoomLogic().CreateMode(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func UpdateTeamRoomMode(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.TeamRoomModeReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewTeamRoomLogic().UpdateMode(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func DeleteTeamRoomMode(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.TeamRoomModeDeleteReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewTeamRoomLogic().DeleteMode(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func CreateTeamRoomProblemSet(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.TeamRoomProblemSetCreateReq](c)
+	if err != nil {
+		return
+	}
+	req.UserID = jwtUtils.GetUserId(c)
+	resp, err := logic.NewTeamRoomLogic().CreateProblemSet(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func GetTeamRoomProblemSet(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.TeamRoomProblemSetReq](c)
+	if err != nil {
+		return
+	}
+	req.UserID = jwtUtils.GetUserId(c)
+	resp, err := logic.NewTeamRoomLogic().GetProblemSet(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func ListTeamRoomProblemSets(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.TeamRoomProblemSetListReq](c)
+	if err != nil {
+		return
+	}
+	req.UserID = jwtUtils.GetUserId(c)
+	resp, err := logic.NewTeamRoomLogic().ListProblemSets(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func DeleteTeamRoomProblemSet(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.TeamRoomProblemSetReq](c)
+	if err != nil {
+		return
+	}
+	req.UserID = jwtUtils.GetUserId(c)
+	resp, err := logic.NewTeamRoomLogic().DeleteProblemSet(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func GetUserTeamStats(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.UserTeamStatsReq](c)
+	if err != nil {
+		return
+	}
+	resp, err := logic.NewTeamRoomLogic().GetUserStats(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func ListTeamRoomEvents(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.TeamRoomEventReq](c)
+	if err != nil {
+		return
+	}
+	req.UserID = jwtUtils.GetUserId(c)
+	resp, err := logic.NewTeamRoomLogic().ListEvents(ctx, req)
+	response.Response(c, resp, err)
+}
+
+// ExportTeamRoom 成功时直接返回文件内容，失败时返回统一的错误结构
+func ExportTeamRoom(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.TeamRoomExportReq](c)
+	if err != nil {
+		return
+	}
+	req.UserID = jwtUtils.GetUserId(c)
+	resp, err := logic.NewTeamRoomLogic().ExportRoom(ctx, req)
+	if err != nil {
+		response.Response(c, nil, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", resp.FileName))
+	c.Data(http.StatusOK, resp.ContentType, resp.Content)
+}
+
+func ListTeamRoomChat(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.TeamRoomChatHistoryReq](c)
+	if err != nil {
+		return
+	}
+	req.UserID = jwtUtils.GetUserId(c)
+	resp, err := logic.NewTeamRoomLogic().ListChat(ctx, req)
+	response.Response(c, resp, err)
+}
+
+func GetTeamRoomInvite(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	req, err := types.BindReq[types.TeamRoomInviteReq](c)
+	if err != nil {
+		return
+	}
+	req.UserID = jwtUtils.GetUserId(c)
+	resp, err := logic.NewTeamRoomLogic().GetInvite(ctx, req)
+	response.Response(c, resp, err)
+}
@@ -8,6 +8,7 @@ import (
 	"tgwp/log/zlog"
 	"tgwp/logic"
 	"tgwp/response"
+	"tgwp/types"
 	"tgwp/utils/jwtUtils"
 )
 
@@ -19,11 +20,23 @@ func WebsocketConnect(c *gin.Context) {
 		return
 	}
 	rootID := parseRootID(c)
-	if err := logic.GetWsHub().Serve(ctx, c.Writer, c.Request, userID, rootID); err != nil {
+	invite := types.TeamRoomInviteCredential{
+		InviteCode:  c.Query("invite_code"),
+		InviteToken: c.Query("invite_token"),
+	}
+	spectate, _ := strconv.ParseBool(c.Query("spectate"))
+	version, _ := strconv.Atoi(c.Query("v"))
+	if err := logic.GetWsHub().Serve(ctx, c.Writer, c.Request, userID, jwtUtils.GetRole(c), rootID, version, invite, spectate); err != nil {
 		zlog.CtxErrorf(ctx, "websocket连接失败:%v", err)
 	}
 }
 
+func GetWsCatalog(c *gin.Context) {
+	ctx := zlog.GetCtxFromGin(c)
+	resp := logic.GetWsHub().GetWsCatalog(ctx)
+	response.Response(c, resp, nil)
+}
+
 func parseRootID(c *gin.Context) int64 {
 	rootIDStr := c.Query("root_id")
 	if rootIDStr == "" {
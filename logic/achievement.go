@@ -0,0 +1,216 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tgwp/global"
+	"tgwp/log/zlog"
+	"tgwp/model"
+	"tgwp/repo"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+// 成就触发条件类型，成就定义本身存放在数据库中
+const (
+	achievementTriggerSingleSolveCount = "single_solve_count"
+	achievementTriggerSolveDifficulty  = "solve_difficulty"
+	achievementTriggerDailyStreak      = "daily_streak"
+	achievementTriggerTeamFirstBlood   = "team_first_blood"
+	achievementTriggerTeamAllSolved    = "team_all_solved"
+)
+
+var defaultAchievements = []model.Achievement{
+	{Code: "first_ac", Name: "初次AC", Description: "在单人模式中首次通过题目", Trigger: achievementTriggerSingleSolveCount, Threshold: 1, Sort: 10},
+	{Code: "solve_10", Name: "渐入佳境", Description: "在单人模式中累计通过10道题目", Trigger: achievementTriggerSingleSolveCount, Threshold: 10, Sort: 20},
+	{Code: "solve_100", Name: "百题斩", Description: "在单人模式中累计通过100道题目", Trigger: achievementTriggerSingleSolveCount, Threshold: 100, Sort: 30},
+	{Code: "difficulty_2000", Name: "高手", Description: "通过一道难度2000及以上的题目", Trigger: achievementTriggerSolveDifficulty, Threshold: 2000, Sort: 40},
+	{Code: "streak_10", Name: "持之以恒", Description: "连续10天完成每日挑战", Trigger: achievementTriggerDailyStreak, Threshold: 10, Sort: 50},
+	{Code: "team_first_blood", Name: "一血", Description: "在团队房间中拿下第一道通过的题目", Trigger: achievementTriggerTeamFirstBlood, Threshold: 1, Sort: 60},
+	{Code: "team_all_solved", Name: "全员AK", Description: "参与的团队房间通过了全部题目", Trigger: achievementTriggerTeamAllSolved, Threshold: 1, Sort: 70},
+}
+
+type AchievementLogic struct {
+}
+
+func NewAchievementLogic() *AchievementLogic {
+	return &AchievementLogic{}
+}
+
+func (l *AchievementLogic) ListAchievements(ctx context.Context) (resp types.AchievementListResp, err error) {
+	_ = ctx
+	items, err := repo.NewAchievementRepo(global.DB).List()
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	resp.Achievements = make([]types.AchievementInfo, 0, len(items))
+	for _, item := range items {
+		resp.Achievements = append(resp.Achievements, buildAchievementInfo(item))
+	}
+	return resp, nil
+}
+
+func (l *AchievementLogic) ListUserAchievements(ctx context.Context, req types.UserAchievementListReq) (resp types.UserAchievementListResp, err error) {
+	_ = ctx
+	if req.UserID == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	achievementRepo := repo.NewAchievementRepo(global.DB)
+	definitions, err := achievementRepo.List()
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	definitionMap := make(map[string]model.Achievement, len(definitions))
+	for _, item := range definitions {
+		definitionMap[item.Code] = item
+	}
+	unlocked, err := achievementRepo.ListByUser(req.UserID)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	resp.Achievements = make([]types.UserAchievementInfo, 0, len(unlocked))
+	for _, item := range unlocked {
+		definition, ok := definitionMap[item.Code]
+		if !ok {
+			continue
+		}
+		resp.Achievements = append(resp.Achievements, types.UserAchievementInfo{
+			AchievementInfo: buildAchievementInfo(definition),
+			RoomID:          item.RoomID,
+			UnlockedAt:      item.UnlockedAt,
+		})
+	}
+	return resp, nil
+}
+
+func buildAchievementInfo(item model.Achievement) types.AchievementInfo {
+	return types.AchievementInfo{
+		Code:        item.Code,
+		Name:        item.Name,
+		Description: item.Description,
+		Icon:        item.Icon,
+		Trigger:     item.Trigger,
+		Threshold:   item.Threshold,
+	}
+}
+
+// InitAchievements 写入默认成就定义
+func InitAchievements() error {
+	items := make([]model.Achievement, len(defaultAchievements))
+	copy(items, defaultAchievements)
+	return repo.NewAchievementRepo(global.DB).CreateIfAbsent(items)
+}
+
+// achievementEvent 房间结算时产生的事件，一个事件对应一名玩家
+type achievementEvent struct {
+	UserID     int64
+	RoomID     int64
+	Solved     bool
+	Difficulty int
+	FirstBlood bool
+	AllSolved  bool
+	Single     bool
+}
+
+type achievementChecker struct {
+	event       achievementEvent
+	solveCount  *int64
+	dailyStreak *int
+}
+
+func (c *achievementChecker) match(item model.Achievement) bool {
+	switch item.Trigger {
+	case achievementTriggerSingleSolveCount:
+		if !c.event.Single || !c.event.Solved {
+			return false
+		}
+		if c.solveCount == nil {
+			count, err := repo.NewSinglePlayerRoomRepo(global.DB).CountSolvedByUser(c.event.UserID)
+			if err != nil {
+				zlog.Warnf("成就统计通过数失败：%v", err)
+				return false
+			}
+			c.solveCount = &count
+		}
+		return *c.solveCount >= int64(item.Threshold)
+	case achievementTriggerSolveDifficulty:
+		return c.event.Solved && c.event.Difficulty >= item.Threshold
+	case achievementTriggerDailyStreak:
+		if !c.event.Single || !c.event.Solved {
+			return false
+		}
+		if c.dailyStreak == nil {
+			dates, err := repo.NewDailyChallengeRepo(global.DB).ListSolvedDates(c.event.UserID)
+			if err != nil {
+				zlog.Warnf("成就统计连续挑战失败：%v", err)
+				return false
+			}
+			streak := calcCurrentStreak(dates, time.Now())
+			c.dailyStreak = &streak
+		}
+		return *c.dailyStreak >= item.Threshold
+	case achievementTriggerTeamFirstBlood:
+		return c.event.FirstBlood
+	case achievementTriggerTeamAllSolved:
+		return c.event.AllSolved
+	default:
+		return false
+	}
+}
+
+// checkAchievements 根据房间结算事件解锁成就并推送给玩家
+func checkAchievements(event achievementEvent) {
+	if event.UserID == 0 {
+		return
+	}
+	achievementRepo := repo.NewAchievementRepo(global.DB)
+	definitions, err := achievementRepo.List()
+	if err != nil {
+		zlog.Warnf("读取成就定义失败：%v", err)
+		return
+	}
+	unlocked, err := achievementRepo.ListByUser(event.UserID)
+	if err != nil {
+		zlog.Warnf("读取玩家成就失败：%v", err)
+		return
+	}
+	owned := make(map[string]struct{}, len(unlocked))
+	for _, item := range unlocked {
+		owned[item.Code] = struct{}{}
+	}
+	checker := &achievementChecker{event: event}
+	for _, definition := range definitions {
+		if _, ok := owned[definition.Code]; ok {
+			continue
+		}
+		if !checker.match(definition) {
+			continue
+		}
+		record := model.UserAchievement{
+			UserID:     event.UserID,
+			Code:       definition.Code,
+			RoomID:     event.RoomID,
+			UnlockedAt: time.Now().Unix(),
+		}
+		created, err := achievementRepo.Unlock(&record)
+		if err != nil {
+			zlog.Warnf("解锁成就失败：%v", err)
+			continue
+		}
+		if !created {
+			continue
+		}
+		GetWsHub().SendToUser(event.UserID, types.WsResponse{
+			Type:    "achievement_unlocked",
+			Code:    response.SUCCESS.Code,
+			Message: response.SUCCESS.Msg,
+			Data: types.UserAchievementInfo{
+				AchievementInfo: buildAchievementInfo(definition),
+				RoomID:          record.RoomID,
+				UnlockedAt:      record.UnlockedAt,
+			},
+		})
+	}
+}
@@ -25,12 +25,14 @@ type CfSubmission struct {
 	SubmissionID int64  `json:"submission_id"`
 	ProblemID    string `json:"problem_id"`
 	Verdict      string `json:"verdict"`
+	CreatedAt    int64  `json:"created_at"`
 }
 
 type CfQueue struct {
 	mu          sync.RWMutex
 	submissions map[int64][]CfSubmission
 	handles     map[int64]string
+	tracked     map[int64]int
 	queue       []int64
 	queued      map[int64]struct{}
 	scanTicker  *time.Ticker
@@ -47,6 +49,7 @@ func GetCfQueue() *CfQueue {
 		cfQueue = &CfQueue{
 			submissions: make(map[int64][]CfSubmission),
 			handles:     make(map[int64]string),
+			tracked:     make(map[int64]int),
 			queue:       make([]int64, 0),
 			queued:      make(map[int64]struct{}),
 		}
@@ -92,10 +95,13 @@ func (q *CfQueue) scanLoop() {
 	for {
 		select {
 		case <-q.scanTicker.C:
-			userIDs := GetWsHub().ActiveUserIDs()
+			userIDs := GetWsHub().LocalUserIDs()
 			for _, userID := range userIDs {
 				q.enqueue(userID)
 			}
+			for _, userID := range q.trackedUserIDs() {
+				q.enqueue(userID)
+			}
 		case <-q.stopCh:
 			return
 		}
@@ -143,6 +149,36 @@ func (q *CfQueue) enqueue(userID int64) {
 	q.queued[userID] = struct{}{}
 }
 
+// TrackUser 登记需要持续轮询的用户，没有连接时也会轮询，需与 UntrackUser 成对调用
+func (q *CfQueue) TrackUser(userID int64) {
+	if userID == 0 {
+		return
+	}
+	q.mu.Lock()
+	q.tracked[userID]++
+	q.mu.Unlock()
+}
+
+func (q *CfQueue) UntrackUser(userID int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.tracked[userID] <= 1 {
+		delete(q.tracked, userID)
+		return
+	}
+	q.tracked[userID]--
+}
+
+func (q *CfQueue) trackedUserIDs() []int64 {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	ids := make([]int64, 0, len(q.tracked))
+	for userID := range q.tracked {
+		ids = append(ids, userID)
+	}
+	return ids
+}
+
 func (q *CfQueue) pop() (int64, bool) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -216,9 +252,10 @@ type cfStatusResponse struct {
 }
 
 type cfSubmission struct {
-	ID      int64     `json:"id"`
-	Verdict string    `json:"verdict"`
-	Problem cfProblem `json:"problem"`
+	ID                  int64     `json:"id"`
+	CreationTimeSeconds int64     `json:"creationTimeSeconds"`
+	Verdict             string    `json:"verdict"`
+	Problem             cfProblem `json:"problem"`
 }
 
 type cfProblem struct {
@@ -263,6 +300,7 @@ func (q *CfQueue) fetchSubmissions(ctx context.Context, handle string) ([]CfSubm
 			SubmissionID: item.ID,
 			ProblemID:    problemID,
 			Verdict:      item.Verdict,
+			CreatedAt:    item.CreationTimeSeconds,
 		})
 		if len(items) >= cfMaxSubmissions {
 			break
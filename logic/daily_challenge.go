@@ -0,0 +1,311 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"tgwp/global"
+	"tgwp/log/zlog"
+	"tgwp/model"
+	"tgwp/repo"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const dailyChallengeDateLayout = "2006-01-02"
+
+type dailyChallengeBand struct {
+	Min int
+	Max int
+}
+
+// 按星期划分难度，周一最简单，周六最难
+var dailyChallengeBands = map[time.Weekday]dailyChallengeBand{
+	time.Monday:    {Min: 800, Max: 1000},
+	time.Tuesday:   {Min: 1000, Max: 1200},
+	time.Wednesday: {Min: 1200, Max: 1400},
+	time.Thursday:  {Min: 1400, Max: 1600},
+	time.Friday:    {Min: 1600, Max: 1800},
+	time.Saturday:  {Min: 1800, Max: 2100},
+	time.Sunday:    {Min: 1300, Max: 1700},
+}
+
+type DailyChallengeLogic struct {
+}
+
+func NewDailyChallengeLogic() *DailyChallengeLogic {
+	return &DailyChallengeLogic{}
+}
+
+func (l *DailyChallengeLogic) GetToday(ctx context.Context) (resp types.DailyChallengeTodayResp, err error) {
+	_ = ctx
+	challenge, problem, err := getDailyChallenge(time.Now())
+	if err != nil {
+		return resp, err
+	}
+	resp.Challenge = buildDailyChallengeInfo(challenge, problem)
+	return resp, nil
+}
+
+func (l *DailyChallengeLogic) CreateRoom(ctx context.Context, userID int64) (resp types.DailyChallengeRoomResp, err error) {
+	_ = ctx
+	if userID == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	user, err := repo.NewUserRepo(global.DB).GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resp, response.ErrResp(err, response.MEMBER_NOT_EXIST)
+		}
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	challenge, problem, err := getDailyChallenge(time.Now())
+	if err != nil {
+		return resp, err
+	}
+	resp.Challenge = buildDailyChallengeInfo(challenge, problem)
+	challengeRepo := repo.NewDailyChallengeRepo(global.DB)
+	roomRepo := repo.NewSinglePlayerRoomRepo(global.DB)
+	record, err := challengeRepo.GetRecord(userID, challenge.Date)
+	if err == nil && record.ID != 0 {
+		room, err := roomRepo.GetByID(record.RoomID)
+		if err != nil {
+			return resp, response.ErrResp(err, response.DATABASE_ERROR)
+		}
+		if room.Status != 0 {
+			return resp, response.ErrResp(errors.New("daily challenge attempted"), response.PARAM_NOT_VALID)
+		}
+		GetSinglePlayerManager().StartRoom(room, problem)
+		resp.Room = buildSingleRoomInfo(room, problem)
+		return resp, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	rating := user.Rating
+	if rating <= 800 {
+		rating = 800
+	}
+	room := model.SinglePlayerRoom{
+		ProblemID:     problem.ID,
+		UserID:        userID,
+		RatingBefore:  rating,
+		ChallengeDate: challenge.Date,
+	}
+	// 重复请求命中挑战记录的唯一索引时房间一并回滚
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := repo.NewSinglePlayerRoomRepo(tx).Create(&room); err != nil {
+			return err
+		}
+		return repo.NewDailyChallengeRepo(tx).CreateRecord(&model.DailyChallengeRecord{
+			Date:   challenge.Date,
+			UserID: userID,
+			RoomID: room.ID,
+		})
+	})
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	GetSinglePlayerManager().StartRoom(room, problem)
+	resp.Room = buildSingleRoomInfo(room, problem)
+	return resp, nil
+}
+
+func (l *DailyChallengeLogic) Leaderboard(ctx context.Context, req types.DailyChallengeLeaderboardReq) (resp types.DailyChallengeLeaderboardResp, err error) {
+	_ = ctx
+	date := req.Date
+	if date == "" {
+		date = time.Now().Format(dailyChallengeDateLayout)
+	}
+	if _, err := time.ParseInLocation(dailyChallengeDateLayout, date, time.Local); err != nil {
+		return resp, response.ErrResp(err, response.PARAM_NOT_VALID)
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+	records, total, err := repo.NewDailyChallengeRepo(global.DB).ListSolvedByDate(date, offset, limit)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	userIDs := make([]int64, 0, len(records))
+	for _, record := range records {
+		userIDs = append(userIDs, record.UserID)
+	}
+	usernames, err := getUsernames(userIDs)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	items := make([]types.DailyChallengeLeaderboardItem, 0, len(records))
+	for i, record := range records {
+		items = append(items, types.DailyChallengeLeaderboardItem{
+			Rank:         offset + i + 1,
+			UserID:       record.UserID,
+			Username:     usernames[record.UserID],
+			SolveSeconds: record.SolveSeconds,
+			Penalty:      record.Penalty,
+			Score:        record.SolveSeconds + int64(record.Penalty*60),
+		})
+	}
+	resp.Date = date
+	resp.Total = total
+	resp.Items = items
+	return resp, nil
+}
+
+func (l *DailyChallengeLogic) GetStreak(ctx context.Context, req types.DailyChallengeStreakReq) (resp types.DailyChallengeStreakResp, err error) {
+	_ = ctx
+	if req.UserID == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	dates, err := repo.NewDailyChallengeRepo(global.DB).ListSolvedDates(req.UserID)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	today := time.Now().Format(dailyChallengeDateLayout)
+	resp.TotalSolved = len(dates)
+	resp.TodaySolved = len(dates) > 0 && dates[0] == today
+	resp.CurrentStreak = calcCurrentStreak(dates, time.Now())
+	resp.LongestStreak = calcLongestStreak(dates)
+	return resp, nil
+}
+
+// getDailyChallenge 获取某天的挑战，不存在时按日期确定性地选出一道题并落库
+func getDailyChallenge(day time.Time) (model.DailyChallenge, model.CodeforcesProblem, error) {
+	date := day.Format(dailyChallengeDateLayout)
+	challengeRepo := repo.NewDailyChallengeRepo(global.DB)
+	problemRepo := repo.NewCodeforcesProblemRepo(global.DB)
+	challenge, err := challengeRepo.GetByDate(date)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return challenge, model.CodeforcesProblem{}, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if err != nil {
+		band := dailyChallengeBands[day.Weekday()]
+		count, err := problemRepo.CountByDifficulty(band.Min, band.Max)
+		if err != nil {
+			return challenge, model.CodeforcesProblem{}, response.ErrResp(err, response.DATABASE_ERROR)
+		}
+		if count == 0 {
+			return challenge, model.CodeforcesProblem{}, response.ErrResp(errors.New("problem empty"), response.MESSAGE_NOT_EXIST)
+		}
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(date))
+		picked, err := problemRepo.GetByDifficultyOffset(band.Min, band.Max, int(int64(hash.Sum32())%count))
+		if err != nil {
+			return challenge, model.CodeforcesProblem{}, response.ErrResp(err, response.DATABASE_ERROR)
+		}
+		if err := challengeRepo.CreateIfAbsent(&model.DailyChallenge{
+			Date:       date,
+			ProblemID:  picked.ID,
+			Difficulty: picked.Difficulty,
+		}); err != nil {
+			return challenge, model.CodeforcesProblem{}, response.ErrResp(err, response.DATABASE_ERROR)
+		}
+		challenge, err = challengeRepo.GetByDate(date)
+		if err != nil {
+			return challenge, model.CodeforcesProblem{}, response.ErrResp(err, response.DATABASE_ERROR)
+		}
+	}
+	problem, err := problemRepo.GetByID(challenge.ProblemID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return challenge, problem, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
+		}
+		return challenge, problem, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	return challenge, problem, nil
+}
+
+func buildDailyChallengeInfo(challenge model.DailyChallenge, problem model.CodeforcesProblem) types.DailyChallengeInfo {
+	solvedCount, err := repo.NewDailyChallengeRepo(global.DB).CountSolvedByDate(challenge.Date)
+	if err != nil {
+		zlog.Warnf("统计每日挑战通过人数失败：%v", err)
+	}
+	return types.DailyChallengeInfo{
+		Date:        challenge.Date,
+		ProblemID:   problem.ID,
+		ProblemURL:  problem.Url,
+		Difficulty:  problem.Difficulty,
+		SolvedCount: solvedCount,
+	}
+}
+
+// recordDailyChallengeResult 单人房间结算后同步每日挑战记录
+func recordDailyChallengeResult(room model.SinglePlayerRoom) {
+	if room.ChallengeDate == "" {
+		return
+	}
+	solveSeconds := int64(0)
+	if room.Status == 2 {
+		solveSeconds = room.EndTime - room.CreatedAt.Unix()
+	}
+	if err := repo.NewDailyChallengeRepo(global.DB).FinishRecord(room.ID, room.Status, solveSeconds, room.Penalty); err != nil {
+		zlog.Warnf("每日挑战记录更新失败：%v", err)
+	}
+}
+
+// calcCurrentStreak dates 需按日期倒序，今天未完成时从昨天开始计算
+func calcCurrentStreak(dates []string, now time.Time) int {
+	if len(dates) == 0 {
+		return 0
+	}
+	expected := now
+	if dates[0] != expected.Format(dailyChallengeDateLayout) {
+		expected = expected.AddDate(0, 0, -1)
+	}
+	streak := 0
+	for _, date := range dates {
+		if date != expected.Format(dailyChallengeDateLayout) {
+			break
+		}
+		streak++
+		expected = expected.AddDate(0, 0, -1)
+	}
+	return streak
+}
+
+func calcLongestStreak(dates []string) int {
+	longest := 0
+	current := 0
+	var prev time.Time
+	for i, date := range dates {
+		day, err := time.ParseInLocation(dailyChallengeDateLayout, date, time.Local)
+		if err != nil {
+			continue
+		}
+		if i > 0 && prev.AddDate(0, 0, -1).Equal(day) {
+			current++
+		} else {
+			current = 1
+		}
+		if current > longest {
+			longest = current
+		}
+		prev = day
+	}
+	return longest
+}
+
+func getUsernames(userIDs []int64) (map[int64]string, error) {
+	result := make(map[int64]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+	users, err := repo.NewUserRepo(global.DB).ListByIDs(userIDs)
+	if err != nil {
+		return result, err
+	}
+	for _, user := range users {
+		result[user.ID] = user.Username
+	}
+	return result, nil
+}
@@ -0,0 +1,255 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"tgwp/global"
+	"tgwp/log/zlog"
+	"tgwp/repo"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	LeaderboardTypeRating   = "rating"
+	LeaderboardTypeWeek     = "week"
+	LeaderboardTypeMonth    = "month"
+	LeaderboardTypeTeamWins = "team_wins"
+
+	REDIS_LEADERBOARD_RATING    = "leaderboard:rating"
+	REDIS_LEADERBOARD_WEEK      = "leaderboard:solves:week:%s"
+	REDIS_LEADERBOARD_MONTH     = "leaderboard:solves:month:%s"
+	REDIS_LEADERBOARD_TEAM_WINS = "leaderboard:team_wins"
+
+	leaderboardWeekTTL  = 8 * 7 * 24 * time.Hour
+	leaderboardMonthTTL = 400 * 24 * time.Hour
+)
+
+type LeaderboardLogic struct {
+}
+
+func NewLeaderboardLogic() *LeaderboardLogic {
+	return &LeaderboardLogic{}
+}
+
+func (l *LeaderboardLogic) List(ctx context.Context, req types.LeaderboardListReq) (resp types.LeaderboardListResp, err error) {
+	key, ok := getLeaderboardKey(req.Type, time.Now())
+	if !ok {
+		return resp, response.ErrResp(errors.New("type not exist"), response.PARAM_NOT_VALID)
+	}
+	if global.Rdb == nil {
+		return resp, response.ErrResp(errors.New("redis not init"), response.REDIS_ERROR)
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	offset := int64((page - 1) * limit)
+	total, err := global.Rdb.ZCard(ctx, key).Result()
+	if err != nil {
+		return resp, response.ErrResp(err, response.REDIS_ERROR)
+	}
+	entries, err := global.Rdb.ZRevRangeWithScores(ctx, key, offset, offset+int64(limit)-1).Result()
+	if err != nil {
+		return resp, response.ErrResp(err, response.REDIS_ERROR)
+	}
+	userIDs := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		userID, _ := strconv.ParseInt(fmt.Sprint(entry.Member), 10, 64)
+		userIDs = append(userIDs, userID)
+	}
+	usernames, err := getUsernames(userIDs)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	items := make([]types.LeaderboardItem, 0, len(entries))
+	for i, entry := range entries {
+		items = append(items, types.LeaderboardItem{
+			Rank:     offset + int64(i) + 1,
+			UserID:   userIDs[i],
+			Username: usernames[userIDs[i]],
+			Score:    int64(entry.Score),
+		})
+	}
+	resp.Type = req.Type
+	resp.Total = total
+	resp.Items = items
+	return resp, nil
+}
+
+func (l *LeaderboardLogic) GetRank(ctx context.Context, req types.LeaderboardRankReq) (resp types.LeaderboardRankResp, err error) {
+	if req.UserID == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	key, ok := getLeaderboardKey(req.Type, time.Now())
+	if !ok {
+		return resp, response.ErrResp(errors.New("type not exist"), response.PARAM_NOT_VALID)
+	}
+	if global.Rdb == nil {
+		return resp, response.ErrResp(errors.New("redis not init"), response.REDIS_ERROR)
+	}
+	resp.Type = req.Type
+	member := strconv.FormatInt(req.UserID, 10)
+	total, err := global.Rdb.ZCard(ctx, key).Result()
+	if err != nil {
+		return resp, response.ErrResp(err, response.REDIS_ERROR)
+	}
+	resp.Total = total
+	rank, err := global.Rdb.ZRevRank(ctx, key, member).Result()
+	if errors.Is(err, redis.Nil) {
+		return resp, nil
+	}
+	if err != nil {
+		return resp, response.ErrResp(err, response.REDIS_ERROR)
+	}
+	score, err := global.Rdb.ZScore(ctx, key, member).Result()
+	if err != nil {
+		return resp, response.ErrResp(err, response.REDIS_ERROR)
+	}
+	resp.Ranked = true
+	resp.Rank = rank + 1
+	resp.Score = int64(score)
+	return resp, nil
+}
+
+func getLeaderboardKey(boardType string, now time.Time) (string, bool) {
+	switch boardType {
+	case LeaderboardTypeRating:
+		return REDIS_LEADERBOARD_RATING, true
+	case LeaderboardTypeWeek:
+		year, week := now.ISOWeek()
+		return fmt.Sprintf(REDIS_LEADERBOARD_WEEK, fmt.Sprintf("%d-W%02d", year, week)), true
+	case LeaderboardTypeMonth:
+		return fmt.Sprintf(REDIS_LEADERBOARD_MONTH, now.Format("2006-01")), true
+	case LeaderboardTypeTeamWins:
+		return REDIS_LEADERBOARD_TEAM_WINS, true
+	default:
+		return "", false
+	}
+}
+
+// updateSingleLeaderboards 单人房间结算后增量更新排行榜
+func updateSingleLeaderboards(userID int64, rating int, rated bool, solved bool, endTime int64) {
+	if global.Rdb == nil || userID == 0 {
+		return
+	}
+	ctx := context.Background()
+	member := strconv.FormatInt(userID, 10)
+	pipe := global.Rdb.TxPipeline()
+	if rated {
+		pipe.ZAdd(ctx, REDIS_LEADERBOARD_RATING, &redis.Z{Score: float64(rating), Member: member})
+	}
+	if solved {
+		at := time.Unix(endTime, 0)
+		weekKey, _ := getLeaderboardKey(LeaderboardTypeWeek, at)
+		monthKey, _ := getLeaderboardKey(LeaderboardTypeMonth, at)
+		pipe.ZIncrBy(ctx, weekKey, 1, member)
+		pipe.Expire(ctx, weekKey, leaderboardWeekTTL)
+		pipe.ZIncrBy(ctx, monthKey, 1, member)
+		pipe.Expire(ctx, monthKey, leaderboardMonthTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		zlog.Warnf("更新排行榜失败：%v", err)
+	}
+}
+
+func addTeamWins(userIDs []int64) {
+	if global.Rdb == nil || len(userIDs) == 0 {
+		return
+	}
+	ctx := context.Background()
+	pipe := global.Rdb.TxPipeline()
+	for _, userID := range userIDs {
+		pipe.ZIncrBy(ctx, REDIS_LEADERBOARD_TEAM_WINS, 1, strconv.FormatInt(userID, 10))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		zlog.Warnf("更新团队胜场排行榜失败：%v", err)
+	}
+}
+
+// RebuildLeaderboards 启动时根据数据库重建排行榜
+func RebuildLeaderboards() error {
+	if global.Rdb == nil {
+		return nil
+	}
+	now := time.Now()
+	users, err := repo.NewUserRepo(global.DB).ListAll()
+	if err != nil {
+		return err
+	}
+	ratings := make(map[int64]float64, len(users))
+	for _, user := range users {
+		ratings[user.ID] = float64(user.Rating)
+	}
+	if err := replaceLeaderboard(REDIS_LEADERBOARD_RATING, ratings, 0); err != nil {
+		return err
+	}
+	roomRepo := repo.NewSinglePlayerRoomRepo(global.DB)
+	weekStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	weekStart = weekStart.AddDate(0, 0, -((int(weekStart.Weekday()) + 6) % 7))
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	periods := []struct {
+		boardType string
+		since     time.Time
+		ttl       time.Duration
+	}{
+		{LeaderboardTypeWeek, weekStart, leaderboardWeekTTL},
+		{LeaderboardTypeMonth, monthStart, leaderboardMonthTTL},
+	}
+	for _, period := range periods {
+		counts, err := roomRepo.CountSolvedGroupByUser(period.since.Unix())
+		if err != nil {
+			return err
+		}
+		scores := make(map[int64]float64, len(counts))
+		for _, item := range counts {
+			scores[item.UserID] = float64(item.Count)
+		}
+		key, _ := getLeaderboardKey(period.boardType, now)
+		if err := replaceLeaderboard(key, scores, period.ttl); err != nil {
+			return err
+		}
+	}
+	rooms, err := repo.NewTeamRoomRepo(global.DB).ListFinished()
+	if err != nil {
+		return err
+	}
+	if err := loadTeamRoomPlayers(rooms); err != nil {
+		return err
+	}
+	wins := make(map[int64]float64)
+	for _, room := range rooms {
+		for _, userID := range getTeamRoomWinners(room) {
+			wins[userID]++
+		}
+	}
+	return replaceLeaderboard(REDIS_LEADERBOARD_TEAM_WINS, wins, 0)
+}
+
+func replaceLeaderboard(key string, scores map[int64]float64, ttl time.Duration) error {
+	ctx := context.Background()
+	pipe := global.Rdb.TxPipeline()
+	pipe.Del(ctx, key)
+	if len(scores) > 0 {
+		members := make([]*redis.Z, 0, len(scores))
+		for userID, score := range scores {
+			members = append(members, &redis.Z{Score: score, Member: strconv.FormatInt(userID, 10)})
+		}
+		pipe.ZAdd(ctx, key, members...)
+		if ttl > 0 {
+			pipe.Expire(ctx, key, ttl)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,82 @@
+package logic
+
+import (
+	"time"
+
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	roomTimerInterval      = 30 * time.Second
+	roomTimerWarningBefore = 15 * time.Minute
+
+	roomTypeSingle = "single"
+	roomTypeTeam   = "team"
+)
+
+// roomClock 房间自己持有的计时器：到点触发结束、定期推送剩余时间、剩余15分钟时提醒
+type roomClock struct {
+	endAt    time.Time
+	deadline *time.Timer
+	warning  *time.Timer
+	ticker   *time.Ticker
+}
+
+func newRoomClock(endAt time.Time) *roomClock {
+	c := &roomClock{
+		ticker: time.NewTicker(roomTimerInterval),
+	}
+	c.reset(endAt)
+	return c
+}
+
+// reset 重新设置结束时间，用于暂停、延长等场景
+func (c *roomClock) reset(endAt time.Time) {
+	if c.deadline != nil {
+		c.deadline.Stop()
+	}
+	if c.warning != nil {
+		c.warning.Stop()
+	}
+	c.endAt = endAt
+	c.deadline = time.NewTimer(time.Until(endAt))
+	// 剩余不足15分钟时不再提醒；负时长的计时器创建后可能已触发，Stop 无法撤回，因此只创建未到期的停止计时器
+	if remaining := time.Until(endAt); remaining > roomTimerWarningBefore {
+		c.warning = time.NewTimer(remaining - roomTimerWarningBefore)
+	} else {
+		c.warning = time.NewTimer(roomTimerWarningBefore)
+		c.warning.Stop()
+	}
+}
+
+// pause 暂停期间不触发结束和提醒，恢复时调用 reset
+func (c *roomClock) pause() {
+	c.deadline.Stop()
+	c.warning.Stop()
+}
+
+func (c *roomClock) stop() {
+	c.deadline.Stop()
+	c.warning.Stop()
+	c.ticker.Stop()
+}
+
+func buildRoomTimerResp(msgType string, roomType string, roomID int64, endAt time.Time) types.WsResponse {
+	remaining := time.Until(endAt)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return types.WsResponse{
+		Type:    msgType,
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.RoomTimerData{
+			RoomID:     roomID,
+			RoomType:   roomType,
+			Remaining:  int64(remaining.Seconds()),
+			EndAt:      endAt.Unix(),
+			ServerTime: time.Now().Unix(),
+		},
+	}
+}
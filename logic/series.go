@@ -0,0 +1,735 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"tgwp/global"
+	"tgwp/model"
+	"tgwp/repo"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	seriesAggregationSum        = "sum"
+	seriesAggregationBestN      = "best_n"
+	seriesAggregationRankPoints = "rank_points"
+
+	seriesNameMaxLen           = 64
+	seriesDefaultMinDifficulty = 800
+	seriesDefaultMaxDifficulty = 3500
+)
+
+// seriesDefaultRankPoints 未配置名次得分时使用，第9名及以后不得分
+var seriesDefaultRankPoints = []int{10, 8, 6, 5, 4, 3, 2, 1}
+
+type SeriesLogic struct {
+}
+
+func NewSeriesLogic() *SeriesLogic {
+	return &SeriesLogic{}
+}
+
+// seriesRoundResult 玩家在一轮中的成绩，分数高者在前，分数相同时罚时少者在前
+type seriesRoundResult struct {
+	Score   int64
+	Penalty int64
+	Rank    int
+}
+
+func (l *SeriesLogic) CreateSeries(ctx context.Context, req types.SeriesCreateReq) (resp types.SeriesInfo, err error) {
+	_ = ctx
+	name := strings.TrimSpace(req.Name)
+	if req.UserID == 0 || name == "" {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if utf8.RuneCountInString(name) > seriesNameMaxLen || utf8.RuneCountInString(req.Description) > 255 {
+		return resp, response.ErrResp(errors.New("name too long"), response.PARAM_NOT_VALID)
+	}
+	item := model.Series{
+		Name:        name,
+		Description: strings.TrimSpace(req.Description),
+		CreatorID:   req.UserID,
+		RoomType:    req.RoomType,
+		Aggregation: req.Aggregation,
+		BestN:       req.BestN,
+	}
+	switch req.RoomType {
+	case roomTypeTeam:
+		if req.Room == nil {
+			return resp, response.ErrResp(errors.New("room config blank"), response.PARAM_NOT_COMPLETE)
+		}
+		config := *req.Room
+		// 每轮开始时间在开启新一轮时指定
+		config.StartAt = 0
+		configBytes, _ := json.Marshal(config)
+		item.RoomConfig = string(configBytes)
+	case roomTypeSingle:
+		item.MinDifficulty, item.MaxDifficulty = req.MinDifficulty, req.MaxDifficulty
+		if item.MinDifficulty == 0 && item.MaxDifficulty == 0 {
+			item.MinDifficulty, item.MaxDifficulty = seriesDefaultMinDifficulty, seriesDefaultMaxDifficulty
+		}
+		if item.MinDifficulty < 0 || item.MaxDifficulty < item.MinDifficulty {
+			return resp, response.ErrResp(errors.New("difficulty invalid"), response.PARAM_NOT_VALID)
+		}
+	default:
+		return resp, response.ErrResp(errors.New("room type invalid"), response.PARAM_NOT_VALID)
+	}
+	if item.Aggregation == "" {
+		item.Aggregation = seriesAggregationSum
+	}
+	switch item.Aggregation {
+	case seriesAggregationSum:
+	case seriesAggregationBestN:
+		if item.BestN <= 0 {
+			return resp, response.ErrResp(errors.New("best n invalid"), response.PARAM_NOT_VALID)
+		}
+	case seriesAggregationRankPoints:
+		points := req.RankPoints
+		if len(points) == 0 {
+			points = seriesDefaultRankPoints
+		}
+		for _, p := range points {
+			if p < 0 {
+				return resp, response.ErrResp(errors.New("rank points invalid"), response.PARAM_NOT_VALID)
+			}
+		}
+		pointBytes, _ := json.Marshal(points)
+		item.RankPoints = string(pointBytes)
+	default:
+		return resp, response.ErrResp(errors.New("aggregation invalid"), response.PARAM_NOT_VALID)
+	}
+	if err := repo.NewSeriesRepo(global.DB).Create(&item); err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	return buildSeriesInfo(item, nil, nil), nil
+}
+
+func (l *SeriesLogic) GetSeries(ctx context.Context, req types.SeriesReq) (resp types.SeriesInfo, err error) {
+	_ = ctx
+	item, err := getSeries(req.ID)
+	if err != nil {
+		return resp, err
+	}
+	rounds, err := repo.NewSeriesRoundRepo(global.DB).ListBySeries(item.ID)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	rooms, err := repo.NewSeriesRoomRepo(global.DB).ListBySeries(item.ID)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	return buildSeriesInfo(item, rounds, rooms), nil
+}
+
+func (l *SeriesLogic) ListSeries(ctx context.Context, req types.SeriesListReq) (resp types.SeriesListResp, err error) {
+	_ = ctx
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	items, total, err := repo.NewSeriesRepo(global.DB).List((page-1)*limit, limit)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	resp.Total = total
+	resp.Items = make([]types.SeriesInfo, 0, len(items))
+	for _, item := range items {
+		resp.Items = append(resp.Items, buildSeriesInfo(item, nil, nil))
+	}
+	return resp, nil
+}
+
+// NextRound 开启下一轮：团队系列按保存的参数以系列创建人身份创建房间，单人系列选定本轮题目
+func (l *SeriesLogic) NextRound(ctx context.Context, userID int64, role int, req types.SeriesRoundReq) (resp types.SeriesRoundResp, err error) {
+	item, err := getSeries(req.SeriesID)
+	if err != nil {
+		return resp, err
+	}
+	if err := checkSeriesOperator(item, userID, role); err != nil {
+		return resp, err
+	}
+	round := model.SeriesRound{
+		SeriesID: item.ID,
+		Round:    item.CurrentRound + 1,
+	}
+	if item.RoomType == roomTypeSingle {
+		problem, err := repo.NewCodeforcesProblemRepo(global.DB).GetRandomByDifficulty(item.MinDifficulty, item.MaxDifficulty)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return resp, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
+			}
+			return resp, response.ErrResp(err, response.DATABASE_ERROR)
+		}
+		round.ProblemID = problem.ID
+	}
+	seriesRepo := repo.NewSeriesRepo(global.DB)
+	ok, err := seriesRepo.UpdateCurrentRound(item.ID, item.CurrentRound, round.Round)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if !ok {
+		return resp, response.ErrResp(errors.New("round already started"), response.PARAM_NOT_VALID)
+	}
+	var rooms []model.SeriesRoom
+	if item.RoomType == roomTypeTeam {
+		var config types.TeamRoomCreateReq
+		_ = json.Unmarshal([]byte(item.RoomConfig), &config)
+		config.StartAt = req.StartAt
+		roomResp, err := NewTeamRoomLogic().CreateRoom(ctx, item.CreatorID, config)
+		if err != nil {
+			_, _ = seriesRepo.UpdateCurrentRound(item.ID, round.Round, item.CurrentRound)
+			return resp, err
+		}
+		resp.TeamRoom = &roomResp
+		rooms = append(rooms, model.SeriesRoom{
+			SeriesID: item.ID,
+			Round:    round.Round,
+			RoomType: roomTypeTeam,
+			RoomID:   roomResp.Room.RoomID,
+		})
+	}
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := repo.NewSeriesRoundRepo(tx).Create(&round); err != nil {
+			return err
+		}
+		for i := range rooms {
+			if err := repo.NewSeriesRoomRepo(tx).Create(&rooms[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// 回退轮次并结束已创建的房间，避免轮次前进但没有轮次记录
+		_, _ = seriesRepo.UpdateCurrentRound(item.ID, round.Round, item.CurrentRound)
+		if resp.TeamRoom != nil {
+			_, _ = NewTeamRoomLogic().EndRoom(ctx, item.CreatorID, resp.TeamRoom.Room.RoomID)
+		}
+		return types.SeriesRoundResp{}, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	resp.Round = buildSeriesRoundInfo(round, rooms)
+	return resp, nil
+}
+
+// AddRoom 把已有房间加入系列的某一轮，房间类型需与系列一致
+func (l *SeriesLogic) AddRoom(ctx context.Context, userID int64, role int, req types.SeriesAddRoomReq) (resp types.SeriesRoundInfo, err error) {
+	_ = ctx
+	item, err := getSeries(req.SeriesID)
+	if err != nil {
+		return resp, err
+	}
+	if err := checkSeriesOperator(item, userID, role); err != nil {
+		return resp, err
+	}
+	roomID, err := strconv.ParseInt(req.RoomID, 10, 64)
+	if err != nil || roomID == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	roundNo := req.Round
+	if roundNo == 0 {
+		roundNo = item.CurrentRound
+	}
+	round, err := repo.NewSeriesRoundRepo(global.DB).Get(item.ID, roundNo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resp, response.ErrResp(errors.New("round not exist"), response.PARAM_NOT_VALID)
+		}
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	seriesRoom := model.SeriesRoom{
+		SeriesID: item.ID,
+		Round:    round.Round,
+		RoomType: item.RoomType,
+		RoomID:   roomID,
+	}
+	var roomUsers []int64
+	if item.RoomType == roomTypeTeam {
+		room, err := getTeamRoom(roomID)
+		if err != nil {
+			return resp, err
+		}
+		roomUsers = append(roomUsers, room.CreatorID)
+		for _, p := range room.Players {
+			roomUsers = append(roomUsers, p.UserID)
+		}
+	} else {
+		room, err := repo.NewSinglePlayerRoomRepo(global.DB).GetByID(roomID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return resp, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
+			}
+			return resp, response.ErrResp(err, response.DATABASE_ERROR)
+		}
+		seriesRoom.UserID = &room.UserID
+		roomUsers = append(roomUsers, room.UserID)
+	}
+	seriesRoomRepo := repo.NewSeriesRoomRepo(global.DB)
+	existing, err := seriesRoomRepo.ListBySeries(item.ID)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if err := checkSeriesRoomUsers(existing, roomUsers, userID); err != nil {
+		return resp, err
+	}
+	if _, err := seriesRoomRepo.GetByRoom(seriesRoom.RoomType, roomID); err == nil {
+		return resp, response.ErrResp(errors.New("room already in series"), response.PARAM_NOT_VALID)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if err := seriesRoomRepo.Create(&seriesRoom); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return resp, response.ErrResp(errors.New("room already in series"), response.PARAM_NOT_VALID)
+		}
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	rooms, err := seriesRoomRepo.ListBySeries(item.ID)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	return buildSeriesRoundInfo(round, rooms), nil
+}
+
+// PlayRound 单人系列玩家进入当前轮次，每人每轮一个房间，已有房间时直接返回
+func (l *SeriesLogic) PlayRound(ctx context.Context, req types.SeriesPlayReq) (resp types.SeriesPlayResp, err error) {
+	_ = ctx
+	if req.UserID == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	item, err := getSeries(req.SeriesID)
+	if err != nil {
+		return resp, err
+	}
+	if item.RoomType != roomTypeSingle {
+		return resp, response.ErrResp(errors.New("not single series"), response.PARAM_NOT_VALID)
+	}
+	round, err := repo.NewSeriesRoundRepo(global.DB).Get(item.ID, item.CurrentRound)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resp, response.ErrResp(errors.New("round not started"), response.PARAM_NOT_VALID)
+		}
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	resp.Round = round.Round
+	problem, err := repo.NewCodeforcesProblemRepo(global.DB).GetByID(round.ProblemID)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	roomRepo := repo.NewSinglePlayerRoomRepo(global.DB)
+	seriesRoomRepo := repo.NewSeriesRoomRepo(global.DB)
+	record, err := seriesRoomRepo.GetUserRoom(item.ID, round.Round, req.UserID)
+	if err == nil {
+		room, err := roomRepo.GetByID(record.RoomID)
+		if err != nil {
+			return resp, response.ErrResp(err, response.DATABASE_ERROR)
+		}
+		if room.Status == 0 {
+			GetSinglePlayerManager().StartRoom(room, problem)
+		}
+		resp.Room = buildSingleRoomInfo(room, problem)
+		return resp, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	// 单人房间同时只能进行一个
+	if active, err := roomRepo.GetActiveByUser(req.UserID); err == nil && active.ID != 0 {
+		return resp, response.ErrResp(errors.New("active room exists"), response.PARAM_NOT_VALID)
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	user, err := repo.NewUserRepo(global.DB).GetByID(req.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resp, response.ErrResp(err, response.MEMBER_NOT_EXIST)
+		}
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	rating := user.Rating
+	if rating <= 800 {
+		rating = 800
+	}
+	extraBytes, _ := json.Marshal(RoomExtraInfo{SeriesID: item.ID})
+	room := model.SinglePlayerRoom{
+		ProblemID:    problem.ID,
+		UserID:       req.UserID,
+		RatingBefore: rating,
+		ExtraInfo:    string(extraBytes),
+	}
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := repo.NewSinglePlayerRoomRepo(tx).Create(&room); err != nil {
+			return err
+		}
+		return repo.NewSeriesRoomRepo(tx).Create(&model.SeriesRoom{
+			SeriesID: item.ID,
+			Round:    round.Round,
+			RoomType: roomTypeSingle,
+			RoomID:   room.ID,
+			UserID:   &req.UserID,
+		})
+	})
+	if err != nil {
+		// 并发请求已为该玩家创建本轮房间
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return resp, response.ErrResp(errors.New("round room already created"), response.PARAM_NOT_VALID)
+		}
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	GetSinglePlayerManager().StartRoom(room, problem)
+	resp.Room = buildSingleRoomInfo(room, problem)
+	return resp, nil
+}
+
+// Standings 只统计已结束的房间，每轮按分数和罚时排名后按系列的积分方式汇总
+func (l *SeriesLogic) Standings(ctx context.Context, req types.SeriesReq) (resp types.SeriesStandingsResp, err error) {
+	_ = ctx
+	item, err := getSeries(req.ID)
+	if err != nil {
+		return resp, err
+	}
+	rooms, err := repo.NewSeriesRoomRepo(global.DB).ListBySeries(item.ID)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	results, usernames, err := collectSeriesResults(rooms)
+	if err != nil {
+		return resp, err
+	}
+	resp.SeriesID = item.ID
+	resp.Aggregation = item.Aggregation
+	resp.Rounds = item.CurrentRound
+	resp.Items = aggregateSeriesStandings(item, results, usernames)
+	return resp, nil
+}
+
+// collectSeriesResults 返回每轮每个玩家的成绩，同一轮有多个房间时取最好的一次
+func collectSeriesResults(rooms []model.SeriesRoom) (map[int]map[int64]*seriesRoundResult, map[int64]string, error) {
+	roundOf := make(map[string]int, len(rooms))
+	var teamIDs, singleIDs []int64
+	for _, r := range rooms {
+		roundOf[r.RoomType+":"+strconv.FormatInt(r.RoomID, 10)] = r.Round
+		if r.RoomType == roomTypeTeam {
+			teamIDs = append(teamIDs, r.RoomID)
+		} else {
+			singleIDs = append(singleIDs, r.RoomID)
+		}
+	}
+	results := make(map[int]map[int64]*seriesRoundResult)
+	usernames := make(map[int64]string)
+	add := func(round int, userID int64, score int64, penalty int64) {
+		if _, ok := results[round]; !ok {
+			results[round] = make(map[int64]*seriesRoundResult)
+		}
+		old, ok := results[round][userID]
+		if ok && (old.Score > score || old.Score == score && old.Penalty <= penalty) {
+			return
+		}
+		results[round][userID] = &seriesRoundResult{Score: score, Penalty: penalty}
+	}
+	teamRooms, err := repo.NewTeamRoomRepo(global.DB).ListByIDs(teamIDs)
+	if err != nil {
+		return nil, nil, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if err := loadTeamRoomData(teamRooms); err != nil {
+		return nil, nil, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	for _, room := range teamRooms {
+		if room.Status != 1 {
+			continue
+		}
+		round := roundOf[roomTypeTeam+":"+strconv.FormatInt(room.ID, 10)]
+		extra := parseTeamRoomExtra(room.ExtraInfo)
+		difficulty := make(map[string]int64)
+		for _, p := range room.Problems {
+			difficulty[p.ProblemID] = int64(p.Difficulty)
+		}
+		points := make(map[int]int64)
+		for _, s := range room.ProblemStatus {
+			if s.Solved {
+				points[s.TeamID] += difficulty[s.ProblemID]
+			}
+		}
+		penalty := make(map[int]int64)
+		if extra.Competitive {
+			for _, team := range buildTeamRoomScoreboard(room) {
+				penalty[team.TeamID] = team.Penalty
+			}
+		} else {
+			penalty[0] = extra.Score / 60
+		}
+		for _, p := range room.Players {
+			usernames[p.UserID] = p.Username
+			teamID := 0
+			if extra.Competitive {
+				// 对抗模式未加入队伍的玩家没有成绩
+				if p.TeamID == 0 {
+					continue
+				}
+				teamID = p.TeamID
+			}
+			add(round, p.UserID, points[teamID], penalty[teamID])
+		}
+	}
+	singleRooms, err := repo.NewSinglePlayerRoomRepo(global.DB).ListByIDs(singleIDs)
+	if err != nil {
+		return nil, nil, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	var missing []int64
+	for _, room := range singleRooms {
+		if room.Status == 0 {
+			continue
+		}
+		round := roundOf[roomTypeSingle+":"+strconv.FormatInt(room.ID, 10)]
+		if room.Status == 2 {
+			add(round, room.UserID, int64(room.PerformanceScore), int64(room.Penalty))
+		} else {
+			add(round, room.UserID, 0, 0)
+		}
+		if _, ok := usernames[room.UserID]; !ok {
+			missing = append(missing, room.UserID)
+		}
+	}
+	if len(missing) > 0 {
+		users, err := repo.NewUserRepo(global.DB).ListByIDs(missing)
+		if err != nil {
+			return nil, nil, response.ErrResp(err, response.DATABASE_ERROR)
+		}
+		for _, u := range users {
+			usernames[u.ID] = u.Username
+		}
+	}
+	for _, users := range results {
+		rankSeriesRound(users)
+	}
+	return results, usernames, nil
+}
+
+func rankSeriesRound(users map[int64]*seriesRoundResult) {
+	list := make([]*seriesRoundResult, 0, len(users))
+	for _, r := range users {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score > list[j].Score
+		}
+		return list[i].Penalty < list[j].Penalty
+	})
+	for i, r := range list {
+		if i > 0 && r.Score == list[i-1].Score && r.Penalty == list[i-1].Penalty {
+			r.Rank = list[i-1].Rank
+			continue
+		}
+		r.Rank = i + 1
+	}
+}
+
+func aggregateSeriesStandings(item model.Series, results map[int]map[int64]*seriesRoundResult, usernames map[int64]string) []types.SeriesStandingItem {
+	var rankPoints []int
+	if item.Aggregation == seriesAggregationRankPoints {
+		_ = json.Unmarshal([]byte(item.RankPoints), &rankPoints)
+	}
+	rounds := make([]int, 0, len(results))
+	for round := range results {
+		rounds = append(rounds, round)
+	}
+	sort.Ints(rounds)
+	standings := make(map[int64]*types.SeriesStandingItem)
+	for _, round := range rounds {
+		for userID, r := range results[round] {
+			standing, ok := standings[userID]
+			if !ok {
+				standing = &types.SeriesStandingItem{UserID: userID, Username: usernames[userID]}
+				standings[userID] = standing
+			}
+			score := types.SeriesRoundScore{
+				Round:   round,
+				Score:   r.Score,
+				Penalty: r.Penalty,
+				Rank:    r.Rank,
+				Points:  r.Score,
+				Counted: true,
+			}
+			if item.Aggregation == seriesAggregationRankPoints {
+				score.Points = 0
+				if r.Rank <= len(rankPoints) {
+					score.Points = int64(rankPoints[r.Rank-1])
+				}
+			}
+			standing.Rounds = append(standing.Rounds, score)
+			standing.Played++
+		}
+	}
+	items := make([]types.SeriesStandingItem, 0, len(standings))
+	for _, standing := range standings {
+		if item.Aggregation == seriesAggregationBestN {
+			markSeriesBestRounds(standing.Rounds, item.BestN)
+		}
+		for _, score := range standing.Rounds {
+			if score.Counted {
+				standing.Total += score.Points
+			}
+		}
+		items = append(items, *standing)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Total != items[j].Total {
+			return items[i].Total > items[j].Total
+		}
+		if items[i].Played != items[j].Played {
+			return items[i].Played > items[j].Played
+		}
+		return items[i].UserID < items[j].UserID
+	})
+	for i := range items {
+		if i > 0 && items[i].Total == items[i-1].Total {
+			items[i].Rank = items[i-1].Rank
+			continue
+		}
+		items[i].Rank = i + 1
+	}
+	return items
+}
+
+// markSeriesBestRounds 只保留得分最高的 n 轮计入总分
+func markSeriesBestRounds(rounds []types.SeriesRoundScore, n int) {
+	order := make([]int, len(rounds))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return rounds[order[i]].Score > rounds[order[j]].Score
+	})
+	for i, index := range order {
+		rounds[index].Counted = i < n
+		if !rounds[index].Counted {
+			rounds[index].Points = 0
+		}
+	}
+}
+
+// checkSeriesRoomUsers 只能加入自己参与的房间，或已在系列中的玩家参与的房间
+func checkSeriesRoomUsers(rooms []model.SeriesRoom, roomUsers []int64, userID int64) error {
+	for _, id := range roomUsers {
+		if id == userID {
+			return nil
+		}
+	}
+	participants, err := listSeriesParticipants(rooms)
+	if err != nil {
+		return err
+	}
+	for _, id := range roomUsers {
+		if _, ok := participants[id]; ok {
+			return nil
+		}
+	}
+	return response.ErrResp(errors.New("room not related to series"), response.PERMISSION_DENIED)
+}
+
+func listSeriesParticipants(rooms []model.SeriesRoom) (map[int64]struct{}, error) {
+	participants := make(map[int64]struct{})
+	var teamIDs []int64
+	for _, r := range rooms {
+		if r.RoomType == roomTypeTeam {
+			teamIDs = append(teamIDs, r.RoomID)
+		} else if r.UserID != nil {
+			participants[*r.UserID] = struct{}{}
+		}
+	}
+	if len(teamIDs) == 0 {
+		return participants, nil
+	}
+	teamRooms, err := repo.NewTeamRoomRepo(global.DB).ListByIDs(teamIDs)
+	if err != nil {
+		return nil, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if err := loadTeamRoomData(teamRooms); err != nil {
+		return nil, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	for _, room := range teamRooms {
+		for _, p := range room.Players {
+			participants[p.UserID] = struct{}{}
+		}
+	}
+	return participants, nil
+}
+
+func getSeries(idStr string) (model.Series, error) {
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id == 0 {
+		return model.Series{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	item, err := repo.NewSeriesRepo(global.DB).GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return item, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
+		}
+		return item, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	return item, nil
+}
+
+// checkSeriesOperator 系列创建人或管理员可以开启新一轮和添加房间
+func checkSeriesOperator(item model.Series, userID int64, role int) error {
+	if role == global.ROLE_ADMIN || (userID != 0 && item.CreatorID == userID) {
+		return nil
+	}
+	return response.ErrResp(errors.New("not series creator"), response.PERMISSION_DENIED)
+}
+
+func buildSeriesInfo(item model.Series, rounds []model.SeriesRound, rooms []model.SeriesRoom) types.SeriesInfo {
+	info := types.SeriesInfo{
+		ID:            item.ID,
+		Name:          item.Name,
+		Description:   item.Description,
+		CreatorID:     item.CreatorID,
+		RoomType:      item.RoomType,
+		Aggregation:   item.Aggregation,
+		BestN:         item.BestN,
+		MinDifficulty: item.MinDifficulty,
+		MaxDifficulty: item.MaxDifficulty,
+		CurrentRound:  item.CurrentRound,
+		CreatedAt:     item.CreatedAt.Unix(),
+	}
+	if item.RankPoints != "" {
+		_ = json.Unmarshal([]byte(item.RankPoints), &info.RankPoints)
+	}
+	if item.RoomConfig != "" {
+		var config types.TeamRoomCreateReq
+		if err := json.Unmarshal([]byte(item.RoomConfig), &config); err == nil {
+			info.Mode = config.Mode
+		}
+	}
+	for _, round := range rounds {
+		info.Rounds = append(info.Rounds, buildSeriesRoundInfo(round, rooms))
+	}
+	return info
+}
+
+func buildSeriesRoundInfo(round model.SeriesRound, rooms []model.SeriesRoom) types.SeriesRoundInfo {
+	info := types.SeriesRoundInfo{
+		Round:     round.Round,
+		ProblemID: round.ProblemID,
+		RoomIDs:   []string{},
+		StartedAt: round.CreatedAt.Unix(),
+	}
+	for _, r := range rooms {
+		if r.Round == round.Round {
+			info.RoomIDs = append(info.RoomIDs, strconv.FormatInt(r.RoomID, 10))
+		}
+	}
+	return info
+}
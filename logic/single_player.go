@@ -133,8 +133,8 @@ func (l *SinglePlayerLogic) AbandonRoom(ctx context.Context, userID int64, req t
 			Type:    "single_room_finish",
 			Code:    response.SUCCESS.Code,
 			Message: response.SUCCESS.Msg,
-			Data: map[string]interface{}{
-				"room": buildSingleRoomInfo(room, problem),
+			Data: types.SingleRoomInfoData{
+				Room: buildSingleRoomInfo(room, problem),
 			},
 		})
 	}
@@ -142,6 +142,111 @@ func (l *SinglePlayerLogic) AbandonRoom(ctx context.Context, userID int64, req t
 	return resp, nil
 }
 
+func (l *SinglePlayerLogic) RerollRoom(ctx context.Context, userID int64, req types.SinglePlayerRerollReq) (resp types.SinglePlayerRerollResp, err error) {
+	_ = ctx
+	if userID == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	roomRepo := repo.NewSinglePlayerRoomRepo(global.DB)
+	var room model.SinglePlayerRoom
+	if req.RoomID != "" {
+		roomID, err := parseRoomID(req.RoomID)
+		if err != nil {
+			return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+		}
+		room, err = roomRepo.GetByID(roomID)
+	} else {
+		room, err = roomRepo.GetActiveByUser(userID)
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resp, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
+		}
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if room.UserID != userID {
+		return resp, response.ErrResp(errors.New("permission denied"), response.PERMISSION_DENIED)
+	}
+	if room.Status != 0 {
+		return resp, response.ErrResp(errors.New("room finished"), response.PARAM_NOT_VALID)
+	}
+	if room.ChallengeDate != "" {
+		return resp, response.ErrResp(errors.New("daily challenge can not reroll"), response.PARAM_NOT_VALID)
+	}
+	extraInfo := parseSingleRoomExtra(room.ExtraInfo)
+	if extraInfo.SeriesID != 0 {
+		return resp, response.ErrResp(errors.New("series room can not reroll"), response.PARAM_NOT_VALID)
+	}
+	if len(extraInfo.Rerolls) >= getRerollLimit() {
+		return resp, response.ErrResp(errors.New("reroll limit reached"), response.PARAM_NOT_VALID)
+	}
+	used := map[string]struct{}{room.ProblemID: {}}
+	for _, item := range extraInfo.Rerolls {
+		used[item.FromProblemID] = struct{}{}
+	}
+	rating := room.RatingBefore
+	if rating <= 800 {
+		rating = 800
+	}
+	minDifficulty := rating - 150
+	if minDifficulty < 0 {
+		minDifficulty = 0
+	}
+	maxDifficulty := rating + 150
+	problemRepo := repo.NewCodeforcesProblemRepo(global.DB)
+	var problem model.CodeforcesProblem
+	for attempt := 0; attempt < 10; attempt++ {
+		problem, err = problemRepo.GetRandomByDifficulty(minDifficulty, maxDifficulty)
+		if err != nil {
+			break
+		}
+		if _, ok := used[problem.ID]; !ok {
+			break
+		}
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resp, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
+		}
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if _, ok := used[problem.ID]; ok {
+		return resp, response.ErrResp(errors.New("problem empty"), response.MESSAGE_NOT_EXIST)
+	}
+	record := types.RoomRerollRecord{
+		FromProblemID: room.ProblemID,
+		ToProblemID:   problem.ID,
+		Cost:          getRerollCost(),
+		RerollTime:    time.Now().Unix(),
+	}
+	oldProblem, err := problemRepo.GetByID(room.ProblemID)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	manager := GetSinglePlayerManager()
+	manager.StartRoom(room, oldProblem)
+	room, ok, err := manager.RerollRoom(room.ID, problem, record)
+	if errors.Is(err, errRerollLimitReached) || errors.Is(err, errRerollConflict) {
+		return resp, response.ErrResp(err, response.PARAM_NOT_VALID)
+	}
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if !ok {
+		return resp, response.ErrResp(errors.New("room not running"), response.PARAM_NOT_VALID)
+	}
+	resp.Room = buildSingleRoomInfo(room, problem)
+	GetWsHub().SendToUser(room.UserID, types.WsResponse{
+		Type:    "single_room_reroll",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.SingleRoomInfoData{
+			Room: resp.Room,
+		},
+	})
+	return resp, nil
+}
+
 func (l *SinglePlayerLogic) getRoomAndProblem(roomID int64) (model.SinglePlayerRoom, model.CodeforcesProblem, error) {
 	roomRepo := repo.NewSinglePlayerRoomRepo(global.DB)
 	room, err := roomRepo.GetByID(roomID)
@@ -182,19 +287,42 @@ func buildSingleRoomInfo(room model.SinglePlayerRoom, problem model.CodeforcesPr
 		RatingAfter:       room.RatingAfter,
 		CreatedAt:         room.CreatedAt,
 		EndTime:           room.EndTime,
+		ChallengeDate:     room.ChallengeDate,
 	}
 
 	if room.ExtraInfo != "" {
-		var extra struct {
-			Submissions []types.RoomSubmissionRecord `json:"submissions"`
-		}
-		if err := json.Unmarshal([]byte(room.ExtraInfo), &extra); err == nil {
-			info.Submissions = extra.Submissions
-		}
+		extra := parseSingleRoomExtra(room.ExtraInfo)
+		info.Submissions = extra.Submissions
+		info.Rerolls = extra.Rerolls
 	}
 	return info
 }
 
+func parseSingleRoomExtra(value string) RoomExtraInfo {
+	var extra RoomExtraInfo
+	if value == "" {
+		return extra
+	}
+	if err := json.Unmarshal([]byte(value), &extra); err != nil {
+		return RoomExtraInfo{}
+	}
+	return extra
+}
+
+func getRerollLimit() int {
+	if global.Config != nil && global.Config.Game.RerollLimit != nil && *global.Config.Game.RerollLimit >= 0 {
+		return *global.Config.Game.RerollLimit
+	}
+	return singlePlayerDefaultRerollLimit
+}
+
+func getRerollCost() int {
+	if global.Config != nil && global.Config.Game.RerollCost != nil && *global.Config.Game.RerollCost >= 0 {
+		return *global.Config.Game.RerollCost
+	}
+	return singlePlayerDefaultRerollCost
+}
+
 func calcPerformance(minutes int, penalty int, solved bool) int {
 	if !solved {
 		return -50
@@ -212,6 +340,9 @@ func finishSingleRoom(ctx context.Context, room model.SinglePlayerRoom, difficul
 	solved := status == 2
 	minutes := int(time.Since(room.CreatedAt).Minutes())
 	performance := calcPerformance(minutes, penalty, solved)
+	for _, item := range parseSingleRoomExtra(room.ExtraInfo).Rerolls {
+		performance -= item.Cost
+	}
 	ratingBefore := room.RatingBefore
 	if ratingBefore == 0 {
 		user, err := repo.NewUserRepo(global.DB).GetByID(room.UserID)
@@ -226,13 +357,20 @@ func finishSingleRoom(ctx context.Context, room model.SinglePlayerRoom, difficul
 	if ratingAfter < 0 {
 		ratingAfter = 0
 	}
+	// 每日挑战和系列的题目与玩家rating无关，不计入rating
+	rated := room.ChallengeDate == "" && parseSingleRoomExtra(room.ExtraInfo).SeriesID == 0
+	if !rated {
+		ratingAfter = ratingBefore
+	}
 	endTime := time.Now().Unix()
 	roomRepo := repo.NewSinglePlayerRoomRepo(global.DB)
 	if err := roomRepo.FinishRoom(room.ID, status, endTime, performance, ratingBefore, ratingAfter, penalty); err != nil {
 		return room, response.ErrResp(err, response.DATABASE_ERROR)
 	}
-	if err := repo.NewUserRepo(global.DB).UpdateRating(room.UserID, ratingAfter); err != nil {
-		return room, response.ErrResp(err, response.DATABASE_ERROR)
+	if rated {
+		if err := repo.NewUserRepo(global.DB).UpdateRating(room.UserID, ratingAfter); err != nil {
+			return room, response.ErrResp(err, response.DATABASE_ERROR)
+		}
 	}
 	room.Status = status
 	room.EndTime = endTime
@@ -240,5 +378,7 @@ func finishSingleRoom(ctx context.Context, room model.SinglePlayerRoom, difficul
 	room.RatingBefore = ratingBefore
 	room.RatingAfter = ratingAfter
 	room.Penalty = penalty
+	recordDailyChallengeResult(room)
+	updateSingleLeaderboards(room.UserID, ratingAfter, rated, solved, endTime)
 	return room, nil
 }
@@ -3,6 +3,7 @@ package logic
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"sync"
 	"time"
 
@@ -16,8 +17,11 @@ import (
 	"tgwp/types"
 )
 
+// RoomExtraInfo SeriesID 不为0时房间属于单人系列，题目按系列难度区间选出
 type RoomExtraInfo struct {
 	Submissions []types.RoomSubmissionRecord `json:"submissions"`
+	Rerolls     []types.RoomRerollRecord     `json:"rerolls,omitempty"`
+	SeriesID    int64                        `json:"series_id,string,omitempty"`
 }
 
 type SinglePlayerManager struct {
@@ -26,6 +30,7 @@ type SinglePlayerManager struct {
 }
 
 type singlePlayerWorker struct {
+	mu        sync.Mutex
 	manager   *SinglePlayerManager
 	room      model.SinglePlayerRoom
 	problem   model.CodeforcesProblem
@@ -38,8 +43,10 @@ var singlePlayerManagerOnce sync.Once
 var singlePlayerManager *SinglePlayerManager
 
 const (
-	singlePlayerRoomTimeout       = 5 * time.Hour
-	singlePlayerRoomCheckInterval = 5 * time.Minute
+	singlePlayerRoomTimeout        = 5 * time.Hour
+	singlePlayerRoomCheckInterval  = 5 * time.Minute
+	singlePlayerDefaultRerollLimit = 1
+	singlePlayerDefaultRerollCost  = 10
 )
 
 var singlePlayerCronMu sync.Mutex
@@ -94,20 +101,87 @@ func (m *SinglePlayerManager) StopRoom(roomID int64) {
 	m.mu.Unlock()
 }
 
+var (
+	errRerollLimitReached = errors.New("reroll limit reached")
+	errRerollConflict     = errors.New("room problem changed")
+)
+
+// RerollRoom 在运行中的房间内替换题目并记录换题信息，调用方的次数检查基于锁外的快照，这里需要再次检查
+func (m *SinglePlayerManager) RerollRoom(roomID int64, problem model.CodeforcesProblem, record types.RoomRerollRecord) (model.SinglePlayerRoom, bool, error) {
+	m.mu.Lock()
+	worker, ok := m.workers[roomID]
+	m.mu.Unlock()
+	if !ok {
+		return model.SinglePlayerRoom{}, false, nil
+	}
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+	if worker.room.Status != 0 {
+		return worker.room, true, errors.New("room finished")
+	}
+	var extraInfo RoomExtraInfo
+	if worker.room.ExtraInfo != "" {
+		_ = json.Unmarshal([]byte(worker.room.ExtraInfo), &extraInfo)
+	}
+	if len(extraInfo.Rerolls) >= getRerollLimit() {
+		return worker.room, true, errRerollLimitReached
+	}
+	if worker.room.ProblemID != record.FromProblemID {
+		return worker.room, true, errRerollConflict
+	}
+	extraInfo.Rerolls = append(extraInfo.Rerolls, record)
+	bytes, _ := json.Marshal(extraInfo)
+	if err := repo.NewSinglePlayerRoomRepo(global.DB).UpdateProblem(roomID, problem.ID, string(bytes)); err != nil {
+		return worker.room, true, err
+	}
+	worker.room.ProblemID = problem.ID
+	worker.room.ExtraInfo = string(bytes)
+	worker.problem = problem
+	return worker.room, true, nil
+}
+
 func (w *singlePlayerWorker) run() {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
+	clock := newRoomClock(w.room.CreatedAt.Add(singlePlayerRoomTimeout))
+	defer clock.stop()
+	w.pushTimer("room_timer", clock.endAt)
 	for {
 		select {
 		case <-ticker.C:
 			w.tick()
+		case <-clock.ticker.C:
+			w.pushTimer("room_timer", clock.endAt)
+		case <-clock.warning.C:
+			w.pushTimer("room_timer_warning", clock.endAt)
+		case <-clock.deadline.C:
+			w.timeout()
 		case <-w.stopCh:
 			return
 		}
 	}
 }
 
+func (w *singlePlayerWorker) pushTimer(msgType string, endAt time.Time) {
+	GetWsHub().SendToUser(w.room.UserID, buildRoomTimerResp(msgType, roomTypeSingle, w.room.ID, endAt))
+}
+
+// timeout 房间到点立即结算，定时任务只兜底没有worker的房间
+func (w *singlePlayerWorker) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.room.Status != 0 {
+		return
+	}
+	w.finish(false)
+}
+
 func (w *singlePlayerWorker) tick() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.room.Status != 0 {
+		return
+	}
 	submissions := GetCfQueue().GetUserSubmissions(w.room.UserID)
 	if len(submissions) == 0 {
 		return
@@ -129,9 +203,9 @@ func (w *singlePlayerWorker) tick() {
 				Type:    "single_room_update",
 				Code:    response.SUCCESS.Code,
 				Message: response.SUCCESS.Msg,
-				Data: map[string]interface{}{
-					"room":         buildSingleRoomInfo(w.room, w.problem),
-					"last_verdict": submission.Verdict,
+				Data: types.SingleRoomUpdateData{
+					Room:        buildSingleRoomInfo(w.room, w.problem),
+					LastVerdict: submission.Verdict,
 				},
 			})
 			w.finish(true)
@@ -148,9 +222,9 @@ func (w *singlePlayerWorker) tick() {
 			Type:    "single_room_update",
 			Code:    response.SUCCESS.Code,
 			Message: response.SUCCESS.Msg,
-			Data: map[string]interface{}{
-				"room":         buildSingleRoomInfo(w.room, w.problem),
-				"last_verdict": submission.Verdict,
+			Data: types.SingleRoomUpdateData{
+				Room:        buildSingleRoomInfo(w.room, w.problem),
+				LastVerdict: submission.Verdict,
 			},
 		})
 	}
@@ -189,10 +263,17 @@ func (w *singlePlayerWorker) finish(solved bool) {
 			Type:    "single_room_finish",
 			Code:    response.SUCCESS.Code,
 			Message: response.SUCCESS.Msg,
-			Data: map[string]interface{}{
-				"room": buildSingleRoomInfo(w.room, w.problem),
+			Data: types.SingleRoomInfoData{
+				Room: buildSingleRoomInfo(w.room, w.problem),
 			},
 		})
+		checkAchievements(achievementEvent{
+			UserID:     w.room.UserID,
+			RoomID:     w.room.ID,
+			Solved:     solved,
+			Difficulty: w.problem.Difficulty,
+			Single:     true,
+		})
 	}
 	w.manager.StopRoom(w.room.ID)
 }
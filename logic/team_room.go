@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"math/rand"
-	"sort"
+	"slices"
 	"strconv"
 	"time"
 
@@ -26,12 +26,21 @@ func NewTeamRoomLogic() *TeamRoomLogic {
 }
 
 func (l *TeamRoomLogic) CreateRoom(ctx context.Context, userID int64, req types.TeamRoomCreateReq) (resp types.TeamRoomCreateResp, err error) {
-	if userID == 0 || req.Mode == "" {
+	if userID == 0 {
 		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
 	}
-	modeConfig, ok := getTeamRoomModeConfig(req.Mode)
-	if !ok {
-		return resp, response.ErrResp(errors.New("mode not exist"), response.PARAM_NOT_VALID)
+	problemIDs, hideDifficulty, err := resolveTeamRoomProblemIDs(userID, req)
+	if err != nil {
+		return resp, err
+	}
+	var modeConfig teamRoomModeConfig
+	if len(problemIDs) > 0 {
+		modeConfig, err = resolveTeamRoomProblemSetConfig(req)
+	} else {
+		modeConfig, err = resolveTeamRoomModeConfig(req)
+	}
+	if err != nil {
+		return resp, err
 	}
 	user, err := repo.NewUserRepo(global.DB).GetByID(userID)
 	if err != nil {
@@ -40,44 +49,88 @@ func (l *TeamRoomLogic) CreateRoom(ctx context.Context, userID int64, req types.
 		}
 		return resp, response.ErrResp(err, response.DATABASE_ERROR)
 	}
-	problems, err := l.buildProblems(ctx, modeConfig.Problems)
+	if req.MaxPlayers < 0 || req.MaxPlayers > teamRoomMaxPlayers {
+		return resp, response.ErrResp(errors.New("max players invalid"), response.PARAM_NOT_VALID)
+	}
+	if req.SpectatorDelay < 0 || time.Duration(req.SpectatorDelay)*time.Second > teamRoomMaxSpectatorDelay {
+		return resp, response.ErrResp(errors.New("spectator delay invalid"), response.PARAM_NOT_VALID)
+	}
+	if req.StartAt != 0 {
+		startAt := time.Unix(req.StartAt, 0)
+		if startAt.Before(time.Now()) || time.Until(startAt) > teamRoomMaxScheduleAhead {
+			return resp, response.ErrResp(errors.New("start time invalid"), response.PARAM_NOT_VALID)
+		}
+	}
+	var problems []model.TeamRoomProblemItem
+	if len(problemIDs) > 0 {
+		problems, err = loadTeamRoomProblems(problemIDs)
+	} else {
+		problems, err = l.buildProblems(ctx, modeConfig.Problems, modeConfig.Tags)
+	}
 	if err != nil {
 		return resp, err
 	}
-	players := []teamRoomPlayer{{
+	players := []model.TeamRoomPlayerItem{{
 		UserID:   user.ID,
 		Username: user.Username,
 		JoinAt:   time.Now().Unix(),
 	}}
-	problemStatus := make([]teamRoomProblemStatus, 0, len(problems))
-	for _, p := range problems {
-		problemStatus = append(problemStatus, teamRoomProblemStatus{
-			ProblemID: p.ProblemID,
-		})
+	extra := teamRoomExtraInfo{
+		DurationSeconds:   int64(modeConfig.Duration.Seconds()),
+		PenaltyPerWrong:   &modeConfig.PenaltyPerWrong,
+		Tags:              modeConfig.Tags,
+		Competitive:       req.Competitive,
+		Private:           req.Private,
+		MaxPlayers:        req.MaxPlayers,
+		StartAt:           req.StartAt,
+		HideDifficulty:    hideDifficulty,
+		FreezeMinutes:     modeConfig.FreezeMinutes,
+		SpectatorDelay:    req.SpectatorDelay,
+		SpectatorHideChat: req.SpectatorHideChat,
+	}
+	if req.Private {
+		code, err := newTeamRoomInviteCode()
+		if err != nil {
+			return resp, response.ErrResp(err, response.COMMON_FAIL)
+		}
+		extra.InviteCode = code
 	}
-	problemBytes, _ := json.Marshal(problems)
-	playerBytes, _ := json.Marshal(players)
-	statusBytes, _ := json.Marshal(problemStatus)
-	submissionBytes, _ := json.Marshal([]teamRoomSubmissionRecord{})
-	duration := getTeamRoomDuration(req.Mode)
-	extraBytes, _ := json.Marshal(teamRoomExtraInfo{
-		DurationSeconds: int64(duration.Seconds()),
-	})
+	problemStatus := make([]model.TeamRoomProblemStatusItem, 0, len(problems))
+	if req.Competitive {
+		if len(req.Teams) > teamRoomMaxTeams {
+			return resp, response.ErrResp(errors.New("too many teams"), response.PARAM_NOT_VALID)
+		}
+		for _, name := range req.Teams {
+			team, err := newTeamRoomTeam(extra.Teams, name)
+			if err != nil {
+				return resp, err
+			}
+			extra.Teams = append(extra.Teams, team)
+			problemStatus = appendTeamProblemStatus(problemStatus, problems, team.TeamID)
+		}
+	} else {
+		problemStatus = appendTeamProblemStatus(problemStatus, problems, 0)
+	}
+	extraBytes, _ := json.Marshal(extra)
 	room := model.TeamRoom{
-		Mode:              req.Mode,
-		ProblemList:       string(problemBytes),
-		PlayerList:        string(playerBytes),
-		CreatorID:         userID,
-		Status:            0,
-		SubmissionRecords: string(submissionBytes),
-		ProblemStatus:     string(statusBytes),
-		ExtraInfo:         string(extraBytes),
-	}
-	if err := repo.NewTeamRoomRepo(global.DB).Create(&room); err != nil {
+		Mode:      modeConfig.Mode,
+		CreatorID: userID,
+		Status:    2,
+		ExtraInfo: string(extraBytes),
+	}
+	if err := createTeamRoom(&room, problems, problemStatus, players); err != nil {
 		return resp, response.ErrResp(err, response.DATABASE_ERROR)
 	}
+	recordTeamRoomEvent(room.ID, teamRoomEventJoin, userID, 0, nil)
 	GetTeamRoomManager().StartRoom(room)
 	resp.Room = buildTeamRoomInfo(room)
+	if extra.Private {
+		invite, err := buildTeamRoomInvite(room.ID, extra.InviteCode)
+		if err != nil {
+			return resp, err
+		}
+		resp.Invite = &invite
+	}
 	return resp, nil
 }
 
@@ -87,7 +140,12 @@ func (l *TeamRoomLogic) GetRoomInfo(ctx context.Context, req types.TeamRoomInfoR
 	if err != nil {
 		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
 	}
-	room, err := repo.NewTeamRoomRepo(global.DB).GetByID(roomID)
+	// 认领、暂停等状态只在运行 worker 的实例上，由该实例生成房间信息
+	if info, routed, err := routeTeamRoomCommand[types.TeamRoomInfo](teamRoomCommand{Op: teamRoomCmdInfo, RoomID: roomID}); routed || err != nil {
+		resp.Room = info
+		return resp, err
+	}
+	room, err := getStoredTeamRoom(roomID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return resp, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
@@ -113,44 +171,79 @@ func (l *TeamRoomLogic) ListRooms(ctx context.Context, req types.TeamRoomListReq
 	if err != nil {
 		return resp, response.ErrResp(err, response.DATABASE_ERROR)
 	}
+	if err := loadTeamRoomData(rooms); err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	resp.Total = total
+	resp.Rooms = buildTeamRoomListItems(rooms)
+	return resp, nil
+}
+
+// ListUserRooms 查询玩家参加过的房间，包括中途离开的房间
+func (l *TeamRoomLogic) ListUserRooms(ctx context.Context, req types.TeamRoomUserRoomsReq) (resp types.TeamRoomListResp, err error) {
+	_ = ctx
+	if req.UserID == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	roomIDs, total, err := repo.NewTeamRoomPlayerRepo(global.DB).ListRoomIDsByUser(req.UserID, (page-1)*limit, limit)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	rooms, err := repo.NewTeamRoomRepo(global.DB).ListByIDs(roomIDs)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if err := loadTeamRoomData(rooms); err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	resp.Total = total
+	resp.Rooms = buildTeamRoomListItems(rooms)
+	return resp, nil
+}
+
+func buildTeamRoomListItems(rooms []model.TeamRoom) []types.TeamRoomListItem {
 	items := make([]types.TeamRoomListItem, 0, len(rooms))
 	for _, room := range rooms {
-		players := parseTeamRoomPlayers(room.PlayerList)
-		problems := parseTeamRoomProblems(room.ProblemList)
+		extra := parseTeamRoomExtra(room.ExtraInfo)
 		items = append(items, types.TeamRoomListItem{
 			RoomID:       room.ID,
 			Mode:         room.Mode,
 			Status:       room.Status,
 			CreatedAt:    room.CreatedAt,
 			EndTime:      room.EndTime,
-			PlayerCount:  len(players),
-			ProblemCount: len(problems),
+			PlayerCount:  len(room.Players),
+			ProblemCount: len(room.Problems),
+			Private:      extra.Private,
+			Locked:       extra.Locked,
+			MaxPlayers:   extra.MaxPlayers,
 		})
 	}
-	resp.Total = total
-	resp.Rooms = items
-	return resp, nil
+	return items
 }
 
 func (l *TeamRoomLogic) ListModes(ctx context.Context) (resp types.TeamRoomModeListResp, err error) {
 	_ = ctx
-	resp.Modes = buildTeamRoomModeInfos()
+	resp.Modes, err = buildTeamRoomModeInfos()
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
 	return resp, nil
 }
 
-func (l *TeamRoomLogic) JoinRoom(ctx context.Context, userID int64, roomID int64) (types.TeamRoomInfo, error) {
+func (l *TeamRoomLogic) JoinRoom(ctx context.Context, userID int64, roomID int64, invite types.TeamRoomInviteCredential) (types.TeamRoomInfo, error) {
 	if userID == 0 || roomID == 0 {
 		return types.TeamRoomInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
 	}
-	room, err := repo.NewTeamRoomRepo(global.DB).GetByID(roomID)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return types.TeamRoomInfo{}, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
-		}
-		return types.TeamRoomInfo{}, response.ErrResp(err, response.DATABASE_ERROR)
-	}
-	if room.Status != 0 {
-		return types.TeamRoomInfo{}, response.ErrResp(errors.New("room finished"), response.PARAM_NOT_VALID)
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomInfo](teamRoomCommand{Op: teamRoomCmdJoin, RoomID: roomID, UserID: userID, Invite: invite}); routed || err != nil {
+		return resp, err
 	}
 	user, err := repo.NewUserRepo(global.DB).GetByID(userID)
 	if err != nil {
@@ -159,33 +252,46 @@ func (l *TeamRoomLogic) JoinRoom(ctx context.Context, userID int64, roomID int64
 		}
 		return types.TeamRoomInfo{}, response.ErrResp(err, response.DATABASE_ERROR)
 	}
-	players := parseTeamRoomPlayers(room.PlayerList)
-	updated := false
-	found := false
-	for i := range players {
-		if players[i].UserID == userID {
-			found = true
-			if players[i].Username != user.Username {
-				players[i].Username = user.Username
-				updated = true
+	joined := false
+	room, err := updateTeamRoom(roomID, func(room *model.TeamRoom) error {
+		if room.Status == 1 {
+			return response.ErrResp(errors.New("room finished"), response.PARAM_NOT_VALID)
+		}
+		players := slices.Clone(room.Players)
+		if err := checkTeamRoomJoinable(*room, players, userID, invite); err != nil {
+			return err
+		}
+		updated := false
+		found := false
+		for i := range players {
+			if players[i].UserID == userID {
+				found = true
+				if players[i].Username != user.Username {
+					players[i].Username = user.Username
+					updated = true
+				}
+				break
 			}
-			break
 		}
-	}
-	if !found {
-		players = append(players, teamRoomPlayer{
-			UserID:   userID,
-			Username: user.Username,
-			JoinAt:   time.Now().Unix(),
-		})
-		updated = true
-	}
-	if updated {
-		bytes, _ := json.Marshal(players)
-		room.PlayerList = string(bytes)
-		if err := repo.NewTeamRoomRepo(global.DB).UpdatePlayerList(room.ID, room.PlayerList); err != nil {
-			return types.TeamRoomInfo{}, response.ErrResp(err, response.DATABASE_ERROR)
+		if !found {
+			players = append(players, model.TeamRoomPlayerItem{
+				UserID:   userID,
+				Username: user.Username,
+				JoinAt:   time.Now().Unix(),
+			})
+			updated = true
+			joined = true
 		}
+		if updated {
+			return saveTeamRoomPlayers(room, players)
+		}
+		return nil
+	})
+	if err != nil {
+		return types.TeamRoomInfo{}, err
+	}
+	if joined {
+		recordTeamRoomEvent(roomID, teamRoomEventJoin, userID, 0, nil)
 	}
 	return buildTeamRoomInfo(room), nil
 }
@@ -194,50 +300,89 @@ func (l *TeamRoomLogic) LeaveRoom(ctx context.Context, userID int64, roomID int6
 	if userID == 0 || roomID == 0 {
 		return types.TeamRoomInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
 	}
-	room, err := repo.NewTeamRoomRepo(global.DB).GetByID(roomID)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return types.TeamRoomInfo{}, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
-		}
-		return types.TeamRoomInfo{}, response.ErrResp(err, response.DATABASE_ERROR)
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomInfo](teamRoomCommand{Op: teamRoomCmdLeave, RoomID: roomID, UserID: userID}); routed || err != nil {
+		return resp, err
 	}
-	players := parseTeamRoomPlayers(room.PlayerList)
-	updated := false
-	if len(players) > 0 {
-		next := players[:0]
-		for _, p := range players {
-			if p.UserID == userID {
-				updated = true
-				continue
+	teamID := 0
+	left := false
+	room, err := updateTeamRoom(roomID, func(room *model.TeamRoom) error {
+		players := slices.Clone(room.Players)
+		updated := false
+		if len(players) > 0 {
+			next := players[:0]
+			for _, p := range players {
+				if p.UserID == userID {
+					teamID = p.TeamID
+					updated = true
+					continue
+				}
+				next = append(next, p)
 			}
-			next = append(next, p)
+			players = next
 		}
-		players = next
-	}
-	if updated {
-		bytes, _ := json.Marshal(players)
-		room.PlayerList = string(bytes)
-		if err := repo.NewTeamRoomRepo(global.DB).UpdatePlayerList(room.ID, room.PlayerList); err != nil {
-			return types.TeamRoomInfo{}, response.ErrResp(err, response.DATABASE_ERROR)
+		if updated {
+			left = true
+			return saveTeamRoomPlayers(room, players)
 		}
+		return nil
+	})
+	if err != nil {
+		return types.TeamRoomInfo{}, err
+	}
+	if left {
+		getTeamRoomPresence().remove(roomID, userID)
+		releaseTeamRoomUserClaims(roomID, userID)
+		recordTeamRoomEvent(roomID, teamRoomEventLeave, userID, teamID, nil)
 	}
 	return buildTeamRoomInfo(room), nil
 }
 
-func (l *TeamRoomLogic) buildProblems(ctx context.Context, preset []int) ([]teamRoomProblem, error) {
+// updateTeamRoom 修改房间：运行中的房间在 worker 锁内修改其持有的状态，已结束的房间直接读取数据库
+func updateTeamRoom(roomID int64, fn func(room *model.TeamRoom) error) (model.TeamRoom, error) {
+	if worker := GetTeamRoomManager().getWorker(roomID); worker != nil {
+		worker.mu.Lock()
+		defer worker.mu.Unlock()
+		err := fn(&worker.room)
+		return worker.room, err
+	}
+	room, err := getStoredTeamRoom(roomID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return room, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
+		}
+		return room, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	// 未结束的房间只能由运行 worker 的实例修改，直接写库会和 worker 的状态不一致
+	if room.Status != 1 {
+		return room, response.ErrResp(errors.New("room owner unavailable"), response.COMMON_FAIL)
+	}
+	err = fn(&room)
+	return room, err
+}
+
+func saveTeamRoomExtra(room *model.TeamRoom, extra teamRoomExtraInfo) error {
+	bytes, _ := json.Marshal(extra)
+	if err := repo.NewTeamRoomRepo(global.DB).UpdateExtraInfo(room.ID, string(bytes)); err != nil {
+		return response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	room.ExtraInfo = string(bytes)
+	return nil
+}
+
+func (l *TeamRoomLogic) buildProblems(ctx context.Context, preset []int, tags []string) ([]model.TeamRoomProblemItem, error) {
 	problemRepo := repo.NewCodeforcesProblemRepo(global.DB)
-	problems := make([]teamRoomProblem, 0, len(preset))
+	problems := make([]model.TeamRoomProblemItem, 0, len(preset))
 	used := make(map[string]struct{})
 	for _, target := range preset {
-		minDifficulty := target - 100
+		minDifficulty := target - teamRoomProblemRange
 		if minDifficulty < 0 {
 			minDifficulty = 0
 		}
-		maxDifficulty := target + 100
+		maxDifficulty := target + teamRoomProblemRange
 		var picked model.CodeforcesProblem
 		var err error
 		for attempt := 0; attempt < 10; attempt++ {
-			picked, err = problemRepo.GetRandomByDifficulty(minDifficulty, maxDifficulty)
+			picked, err = problemRepo.GetRandomByDifficultyAndTags(minDifficulty, maxDifficulty, tags)
 			if err != nil {
 				break
 			}
@@ -256,7 +401,7 @@ func (l *TeamRoomLogic) buildProblems(ctx context.Context, preset []int) ([]team
 			return nil, response.ErrResp(errors.New("problem empty"), response.MESSAGE_NOT_EXIST)
 		}
 		used[picked.ID] = struct{}{}
-		problems = append(problems, teamRoomProblem{
+		problems = append(problems, model.TeamRoomProblemItem{
 			ProblemID:  picked.ID,
 			ProblemURL: picked.Url,
 			Difficulty: picked.Difficulty,
@@ -276,22 +421,41 @@ func parseTeamRoomID(roomID string) (int64, error) {
 }
 
 func buildTeamRoomInfo(room model.TeamRoom) types.TeamRoomInfo {
-	problems := parseTeamRoomProblems(room.ProblemList)
-	status := parseTeamRoomProblemStatus(room.ProblemStatus)
-	players := parseTeamRoomPlayers(room.PlayerList)
-	submissions := parseTeamRoomSubmissions(room.SubmissionRecords)
+	return buildTeamRoomViewInfo(room, 0)
+}
+
+// buildTeamRoomViewInfo 封榜期间只对 teamID 对应的队伍显示完整结果
+func buildTeamRoomViewInfo(room model.TeamRoom, teamID int) types.TeamRoomInfo {
+	frozen := isTeamRoomFrozen(room)
+	room = maskFrozenTeamRoom(room, teamID)
+	problems := room.Problems
+	status := room.ProblemStatus
+	players := room.Players
+	submissions := room.Submissions
 	extra := parseTeamRoomExtra(room.ExtraInfo)
-	problemMap := make(map[string]teamRoomProblemStatus, len(status))
+	problemMap := make(map[string]model.TeamRoomProblemStatusItem, len(status))
 	for _, item := range status {
+		if item.TeamID != 0 {
+			continue
+		}
 		problemMap[item.ProblemID] = item
 	}
+	// 等待开始时不公开题目
+	if room.Status == 2 {
+		problems = nil
+	}
 	problemInfos := make([]types.TeamRoomProblemInfo, 0, len(problems))
 	for _, p := range problems {
 		stat := problemMap[p.ProblemID]
+		difficulty := p.Difficulty
+		// 隐藏难度的房间结束后才公开
+		if extra.HideDifficulty && room.Status != 1 {
+			difficulty = 0
+		}
 		problemInfos = append(problemInfos, types.TeamRoomProblemInfo{
 			ProblemID:  p.ProblemID,
 			ProblemURL: p.ProblemURL,
-			Difficulty: p.Difficulty,
+			Difficulty: difficulty,
 			Solved:     stat.Solved,
 			SolvedBy:   stat.SolvedBy,
 			Penalty:    stat.Penalty,
@@ -300,10 +464,15 @@ func buildTeamRoomInfo(room model.TeamRoom) types.TeamRoomInfo {
 	}
 	playerInfos := make([]types.TeamRoomPlayerInfo, 0, len(players))
 	for _, p := range players {
+		presence, since := getTeamRoomPresence().get(room.ID, p.UserID)
 		playerInfos = append(playerInfos, types.TeamRoomPlayerInfo{
-			UserID:   p.UserID,
-			Username: p.Username,
-			JoinAt:   p.JoinAt,
+			UserID:        p.UserID,
+			Username:      p.Username,
+			JoinAt:        p.JoinAt,
+			TeamID:        p.TeamID,
+			Ready:         p.Ready,
+			Presence:      presence,
+			PresenceSince: since,
 		})
 	}
 	submissionInfos := make([]types.TeamRoomSubmissionInfo, 0, len(submissions))
@@ -312,62 +481,98 @@ func buildTeamRoomInfo(room model.TeamRoom) types.TeamRoomInfo {
 			SubmissionID: s.SubmissionID,
 			ProblemID:    s.ProblemID,
 			UserID:       s.UserID,
+			TeamID:       s.TeamID,
 			Verdict:      s.Verdict,
 			SubmitTime:   s.SubmitTime,
 		})
 	}
+	var teamInfos []types.TeamRoomTeamInfo
+	var scoreboard []types.TeamRoomScoreboardItem
+	if extra.Competitive {
+		teamInfos = make([]types.TeamRoomTeamInfo, 0, len(extra.Teams))
+		for _, team := range extra.Teams {
+			teamInfos = append(teamInfos, types.TeamRoomTeamInfo{
+				TeamID: team.TeamID,
+				Name:   team.Name,
+			})
+		}
+		scoreboard = buildTeamRoomScoreboard(room)
+	}
 	return types.TeamRoomInfo{
-		RoomID:      room.ID,
-		Mode:        room.Mode,
-		Status:      room.Status,
-		CreatedAt:   room.CreatedAt,
-		EndTime:     room.EndTime,
-		Players:     playerInfos,
-		Problems:    problemInfos,
-		Submissions: submissionInfos,
-		Score:       extra.Score,
-		Duration:    extra.DurationSeconds,
+		RoomID:            room.ID,
+		Mode:              room.Mode,
+		Status:            room.Status,
+		CreatedAt:         room.CreatedAt,
+		StartTime:         room.StartTime,
+		StartAt:           extra.StartAt,
+		EndTime:           room.EndTime,
+		Players:           playerInfos,
+		Problems:          problemInfos,
+		Submissions:       submissionInfos,
+		Score:             extra.Score,
+		Duration:          extra.DurationSeconds,
+		Tags:              extra.Tags,
+		PenaltyPerWrong:   getTeamRoomPenaltyPerWrong(extra),
+		HideDifficulty:    extra.HideDifficulty,
+		Competitive:       extra.Competitive,
+		CreatorID:         room.CreatorID,
+		Private:           extra.Private,
+		Locked:            extra.Locked,
+		MaxPlayers:        extra.MaxPlayers,
+		Spectators:        GetWsHub().RoomSpectatorCount(room.ID),
+		SpectatorDelay:    extra.SpectatorDelay,
+		SpectatorHideChat: extra.SpectatorHideChat,
+		Paused:            extra.PausedAt > 0,
+		PausedSeconds:     int64(getTeamRoomPausedDuration(extra, time.Now()).Seconds()),
+		EndAt:             getTeamRoomViewEndAt(room, extra),
+		Claims:            buildTeamRoomClaimInfos(room, teamID),
+		Frozen:            frozen,
+		FreezeAt:          getTeamRoomFreezeAt(room, extra),
+		Teams:             teamInfos,
+		Scoreboard:        scoreboard,
+		PlayerStats:       buildTeamRoomPlayerStats(room),
 	}
 }
 
-func parseTeamRoomProblems(value string) []teamRoomProblem {
+// parseLegacyTeamRoomProblems 等函数只用于迁移旧版本保存在 team_room 表中的JSON列
+func parseLegacyTeamRoomProblems(value string) []model.TeamRoomProblemItem {
 	if value == "" {
 		return nil
 	}
-	var items []teamRoomProblem
+	var items []model.TeamRoomProblemItem
 	if err := json.Unmarshal([]byte(value), &items); err != nil {
 		return nil
 	}
 	return items
 }
 
-func parseTeamRoomProblemStatus(value string) []teamRoomProblemStatus {
+func parseLegacyTeamRoomProblemStatus(value string) []model.TeamRoomProblemStatusItem {
 	if value == "" {
 		return nil
 	}
-	var items []teamRoomProblemStatus
+	var items []model.TeamRoomProblemStatusItem
 	if err := json.Unmarshal([]byte(value), &items); err != nil {
 		return nil
 	}
 	return items
 }
 
-func parseTeamRoomPlayers(value string) []teamRoomPlayer {
+func parseLegacyTeamRoomPlayers(value string) []model.TeamRoomPlayerItem {
 	if value == "" {
 		return nil
 	}
-	var items []teamRoomPlayer
+	var items []model.TeamRoomPlayerItem
 	if err := json.Unmarshal([]byte(value), &items); err != nil {
 		return nil
 	}
 	return items
 }
 
-func parseTeamRoomSubmissions(value string) []teamRoomSubmissionRecord {
+func parseLegacyTeamRoomSubmissions(value string) []model.TeamRoomSubmissionItem {
 	if value == "" {
 		return nil
 	}
-	var items []teamRoomSubmissionRecord
+	var items []model.TeamRoomSubmissionItem
 	if err := json.Unmarshal([]byte(value), &items); err != nil {
 		return nil
 	}
@@ -385,91 +590,39 @@ func parseTeamRoomExtra(value string) teamRoomExtraInfo {
 	return extra
 }
 
-type teamRoomProblem struct {
-	ProblemID  string `json:"problem_id"`
-	ProblemURL string `json:"problem_url"`
-	Difficulty int    `json:"difficulty"`
-}
-
-type teamRoomPlayer struct {
-	UserID   int64  `json:"user_id"`
-	Username string `json:"username"`
-	JoinAt   int64  `json:"join_at"`
-}
-
-type teamRoomSubmissionRecord struct {
-	SubmissionID int64  `json:"submission_id"`
-	ProblemID    string `json:"problem_id"`
-	UserID       int64  `json:"user_id"`
-	Verdict      string `json:"verdict"`
-	SubmitTime   int64  `json:"submit_time"`
-}
-
-type teamRoomProblemStatus struct {
-	ProblemID string `json:"problem_id"`
-	Solved    bool   `json:"solved"`
-	SolvedBy  int64  `json:"solved_by"`
-	Penalty   int    `json:"penalty"`
-	SolvedAt  int64  `json:"solved_at"`
+type teamRoomTeam struct {
+	TeamID int    `json:"team_id"`
+	Name   string `json:"name"`
 }
 
 type teamRoomExtraInfo struct {
-	Score           int64 `json:"score"`
-	DurationSeconds int64 `json:"duration_seconds"`
-}
-
-const teamRoomDefaultDuration = 5 * time.Hour
-
-type teamRoomModeConfig struct {
-	Mode     string
-	Duration time.Duration
-	Problems []int
-}
-
-var teamRoomModeConfigs = map[string]teamRoomModeConfig{
-	"div3": {
-		Mode:     "div3",
-		Duration: 2 * time.Hour,
-		Problems: []int{800, 800, 900, 1000, 1200, 1400, 1600, 1800, 2000},
-	},
-	"div3-plus": {
-		Mode:     "div3-plus",
-		Duration: 2*time.Hour + 30*time.Minute,
-		Problems: []int{800, 800, 900, 900, 1000, 1100, 1100, 1200, 1300, 1400, 1500, 1600, 1800, 1900, 2100},
-	},
-}
-
-func getTeamRoomModeConfig(mode string) (teamRoomModeConfig, bool) {
-	config, ok := teamRoomModeConfigs[mode]
-	return config, ok
+	Score             int64                          `json:"score"`
+	DurationSeconds   int64                          `json:"duration_seconds"`
+	AllSolved         bool                           `json:"all_solved,omitempty"`
+	Competitive       bool                           `json:"competitive,omitempty"`
+	Teams             []teamRoomTeam                 `json:"teams,omitempty"`
+	TeamResults       []types.TeamRoomScoreboardItem `json:"team_results,omitempty"`
+	Private           bool                           `json:"private,omitempty"`
+	InviteCode        string                         `json:"invite_code,omitempty"`
+	MaxPlayers        int                            `json:"max_players,omitempty"`
+	Locked            bool                           `json:"locked,omitempty"`
+	Kicked            []int64                        `json:"kicked,omitempty"`
+	StartAt           int64                          `json:"start_at,omitempty"`
+	Tags              []string                       `json:"tags,omitempty"`
+	PenaltyPerWrong   *int                           `json:"penalty_per_wrong,omitempty"`
+	HideDifficulty    bool                           `json:"hide_difficulty,omitempty"`
+	FreezeMinutes     int                            `json:"freeze_minutes,omitempty"`
+	SpectatorDelay    int64                          `json:"spectator_delay,omitempty"`
+	SpectatorHideChat bool                           `json:"spectator_hide_chat,omitempty"`
+	PausedAt          int64                          `json:"paused_at,omitempty"`
+	PausedSeconds     int64                          `json:"paused_seconds,omitempty"`
+	PauseIntervals    []teamRoomPauseInterval        `json:"pause_intervals,omitempty"`
 }
 
-func getTeamRoomDuration(mode string) time.Duration {
-	if config, ok := teamRoomModeConfigs[mode]; ok && config.Duration > 0 {
-		return config.Duration
+// getTeamRoomPenaltyPerWrong 旧房间未记录罚时配置时使用默认值
+func getTeamRoomPenaltyPerWrong(extra teamRoomExtraInfo) int {
+	if extra.PenaltyPerWrong == nil {
+		return teamRoomPenaltyPerWrong
 	}
-	return teamRoomDefaultDuration
-}
-
-func buildTeamRoomModeInfos() []types.TeamRoomModeInfo {
-	if len(teamRoomModeConfigs) == 0 {
-		return nil
-	}
-	keys := make([]string, 0, len(teamRoomModeConfigs))
-	for key := range teamRoomModeConfigs {
-		keys = append(keys, key)
-	}
-	sort.Strings(keys)
-	items := make([]types.TeamRoomModeInfo, 0, len(keys))
-	for _, key := range keys {
-		config := teamRoomModeConfigs[key]
-		problems := make([]int, len(config.Problems))
-		copy(problems, config.Problems)
-		items = append(items, types.TeamRoomModeInfo{
-			Mode:     config.Mode,
-			Duration: int64(getTeamRoomDuration(config.Mode).Seconds()),
-			Problems: problems,
-		})
-	}
-	return items
+	return *extra.PenaltyPerWrong
 }
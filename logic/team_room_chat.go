@@ -0,0 +1,314 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+
+	"tgwp/global"
+	"tgwp/log/zlog"
+	"tgwp/model"
+	"tgwp/repo"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	teamRoomDefaultChatMaxLength   = 200
+	teamRoomDefaultChatRateLimit   = 20
+	teamRoomDefaultChatHistorySize = 50
+	teamRoomChatMaxPageSize        = 100
+	teamRoomChatBurst              = 5
+)
+
+// teamRoomChatLimiterIdle 闲置超过该时长的限流器令牌已经补满，可以直接丢弃
+const teamRoomChatLimiterIdle = 10 * time.Minute
+
+// teamRoomChatLimiter 按玩家限制发送频率，不区分房间
+type teamRoomChatLimiter struct {
+	mu        sync.Mutex
+	limiters  map[int64]*teamRoomChatLimiterEntry
+	lastPrune time.Time
+}
+
+type teamRoomChatLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+var teamRoomChatLimiterOnce sync.Once
+var teamRoomChatLimiterInst *teamRoomChatLimiter
+
+func getTeamRoomChatLimiter() *teamRoomChatLimiter {
+	teamRoomChatLimiterOnce.Do(func() {
+		teamRoomChatLimiterInst = &teamRoomChatLimiter{
+			limiters:  make(map[int64]*teamRoomChatLimiterEntry),
+			lastPrune: time.Now(),
+		}
+	})
+	return teamRoomChatLimiterInst
+}
+
+func (l *teamRoomChatLimiter) allow(userID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Sub(l.lastPrune) >= teamRoomChatLimiterIdle {
+		l.prune(now)
+	}
+	entry, ok := l.limiters[userID]
+	if !ok {
+		perMinute := teamRoomDefaultChatRateLimit
+		if global.Config != nil && global.Config.Game.ChatRateLimit > 0 {
+			perMinute = global.Config.Game.ChatRateLimit
+		}
+		entry = &teamRoomChatLimiterEntry{
+			limiter: rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), teamRoomChatBurst),
+		}
+		l.limiters[userID] = entry
+	}
+	entry.lastSeen = now
+	return entry.limiter.AllowN(now, 1)
+}
+
+func (l *teamRoomChatLimiter) prune(now time.Time) {
+	for userID, entry := range l.limiters {
+		if now.Sub(entry.lastSeen) >= teamRoomChatLimiterIdle {
+			delete(l.limiters, userID)
+		}
+	}
+	l.lastPrune = now
+}
+
+// SendChat 校验长度和频率，过滤屏蔽词后保存消息
+func (l *TeamRoomLogic) SendChat(ctx context.Context, userID int64, roomID int64, content string) (types.TeamRoomChatInfo, error) {
+	_ = ctx
+	content = strings.TrimSpace(content)
+	if userID == 0 || roomID == 0 || content == "" {
+		return types.TeamRoomChatInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if utf8.RuneCountInString(content) > getTeamRoomChatMaxLength() {
+		return types.TeamRoomChatInfo{}, response.ErrResp(errors.New("message too long"), response.PARAM_NOT_VALID)
+	}
+	if err := checkTeamRoomAccess(roomID, userID); err != nil {
+		return types.TeamRoomChatInfo{}, err
+	}
+	if !getTeamRoomChatLimiter().allow(userID) {
+		return types.TeamRoomChatInfo{}, response.ErrResp(errors.New("send too frequently"), response.REQUEST_FREQUENTLY)
+	}
+	user, err := repo.NewUserRepo(global.DB).GetByID(userID)
+	if err != nil {
+		return types.TeamRoomChatInfo{}, response.ErrResp(err, response.MEMBER_NOT_EXIST)
+	}
+	item := model.TeamRoomChatMessage{
+		RoomID:   roomID,
+		UserID:   user.ID,
+		Username: user.Username,
+		Content:  filterTeamRoomChat(content),
+	}
+	if err := repo.NewTeamRoomChatRepo(global.DB).Create(&item); err != nil {
+		return types.TeamRoomChatInfo{}, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	recordTeamRoomEvent(roomID, teamRoomEventChat, user.ID, 0, map[string]interface{}{
+		"message_id": item.ID,
+		"username":   item.Username,
+		"content":    item.Content,
+	})
+	return buildTeamRoomChatInfo(item), nil
+}
+
+// ListChat 按 before_id 向前翻页，返回的消息按时间正序
+func (l *TeamRoomLogic) ListChat(ctx context.Context, req types.TeamRoomChatHistoryReq) (resp types.TeamRoomChatHistoryResp, err error) {
+	_ = ctx
+	roomID, err := parseTeamRoomID(req.RoomID)
+	if err != nil || req.UserID == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	var beforeID int64
+	if req.BeforeID != "" {
+		if beforeID, err = parseTeamRoomID(req.BeforeID); err != nil {
+			return resp, response.ErrResp(err, response.PARAM_NOT_VALID)
+		}
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > teamRoomChatMaxPageSize {
+		limit = 20
+	}
+	if err := checkTeamRoomAccess(roomID, req.UserID); err != nil {
+		return resp, err
+	}
+	messages, hasMore, err := listTeamRoomChat(roomID, beforeID, limit)
+	if err != nil {
+		return resp, err
+	}
+	resp.Messages = messages
+	resp.HasMore = hasMore
+	return resp, nil
+}
+
+// DeleteChat 房主或管理员删除消息，并通知房间内所有连接
+func (l *TeamRoomLogic) DeleteChat(ctx context.Context, userID int64, role int, roomID int64, messageID int64) error {
+	_ = ctx
+	if userID == 0 || roomID == 0 || messageID == 0 {
+		return response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	room, err := getTeamRoom(roomID)
+	if err != nil {
+		return err
+	}
+	if err := checkTeamRoomOperator(room, userID, role); err != nil {
+		return err
+	}
+	chatRepo := repo.NewTeamRoomChatRepo(global.DB)
+	if _, err := chatRepo.GetByID(roomID, messageID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrResp(err, response.MESSAGE_NOT_EXIST)
+		}
+		return response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	// 事件回放中保存了消息内容，需要一并删除
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := repo.NewTeamRoomChatRepo(tx).Delete(messageID); err != nil {
+			return err
+		}
+		return deleteTeamRoomChatEvent(tx, roomID, messageID)
+	})
+	if err != nil {
+		return response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	GetWsHub().SendToRoom(roomID, types.WsResponse{
+		Type:    "team_room_chat_delete",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomChatDeleteInfo{
+			RoomID:    roomID,
+			MessageID: messageID,
+			DeletedBy: userID,
+		},
+	})
+	return nil
+}
+
+func listTeamRoomChat(roomID int64, beforeID int64, limit int) ([]types.TeamRoomChatInfo, bool, error) {
+	items, err := repo.NewTeamRoomChatRepo(global.DB).ListBefore(roomID, beforeID, limit+1)
+	if err != nil {
+		return nil, false, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+	messages := make([]types.TeamRoomChatInfo, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		messages = append(messages, buildTeamRoomChatInfo(items[i]))
+	}
+	return messages, hasMore, nil
+}
+
+func buildTeamRoomChatInfo(item model.TeamRoomChatMessage) types.TeamRoomChatInfo {
+	return types.TeamRoomChatInfo{
+		ID:       item.ID,
+		RoomID:   item.RoomID,
+		UserID:   item.UserID,
+		Username: item.Username,
+		Content:  item.Content,
+		Ts:       item.CreatedAt.Unix(),
+	}
+}
+
+func getTeamRoomChatMaxLength() int {
+	if global.Config != nil && global.Config.Game.ChatMaxLength > 0 {
+		return global.Config.Game.ChatMaxLength
+	}
+	return teamRoomDefaultChatMaxLength
+}
+
+func getTeamRoomChatHistorySize() int {
+	if global.Config != nil && global.Config.Game.ChatHistorySize > 0 {
+		return global.Config.Game.ChatHistorySize
+	}
+	return teamRoomDefaultChatHistorySize
+}
+
+// teamRoomChatFilter 缓存由屏蔽词列表编译出的正则，配置热更新后列表变化时重新编译
+type teamRoomChatFilter struct {
+	mu    sync.Mutex
+	words []string
+	re    *regexp.Regexp
+}
+
+var teamRoomChatFilterInst = &teamRoomChatFilter{}
+
+func (f *teamRoomChatFilter) get(words []string) *regexp.Regexp {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if slices.Equal(f.words, words) {
+		return f.re
+	}
+	var parts []string
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		if word != "" {
+			parts = append(parts, regexp.QuoteMeta(word))
+		}
+	}
+	// 较长的词优先匹配，避免被其前缀截断
+	sort.SliceStable(parts, func(i, j int) bool {
+		return len(parts[i]) > len(parts[j])
+	})
+	f.words = slices.Clone(words)
+	f.re = nil
+	if len(parts) > 0 {
+		re, err := regexp.Compile("(?i)(?:" + strings.Join(parts, "|") + ")")
+		if err != nil {
+			zlog.Warnf("聊天屏蔽词无效：%v", err)
+		} else {
+			f.re = re
+		}
+	}
+	return f.re
+}
+
+// filterTeamRoomChat 屏蔽词不区分大小写，按字数替换为*
+func filterTeamRoomChat(content string) string {
+	if global.Config == nil {
+		return content
+	}
+	re := teamRoomChatFilterInst.get(global.Config.Game.ChatFilterWords)
+	if re == nil {
+		return content
+	}
+	return re.ReplaceAllStringFunc(content, func(s string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(s))
+	})
+}
+
+func deleteTeamRoomChatEvent(tx *gorm.DB, roomID int64, messageID int64) error {
+	eventRepo := repo.NewTeamRoomEventRepo(tx)
+	events, err := eventRepo.ListByRoomType(roomID, teamRoomEventChat)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		var data struct {
+			MessageID int64 `json:"message_id"`
+		}
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil || data.MessageID != messageID {
+			continue
+		}
+		if err := eventRepo.Delete(event.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"tgwp/model"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	teamRoomClaimReading = "reading"
+	teamRoomClaimCoding  = "coding"
+	teamRoomClaimStuck   = "stuck"
+)
+
+// teamRoomClaim 队友之间的分工标记，只保存在运行中的 worker 内，房间结束即丢弃
+type teamRoomClaim struct {
+	ProblemID string
+	TeamID    int
+	UserID    int64
+	State     string
+	ClaimedAt int64
+	UpdatedAt int64
+}
+
+// ClaimProblem 认领题目或更新认领状态，同一队伍的一道题同时只能由一人认领
+func (l *TeamRoomLogic) ClaimProblem(ctx context.Context, userID int64, roomID int64, problemID string, state string) error {
+	_ = ctx
+	if state == "" {
+		state = teamRoomClaimReading
+	}
+	if state != teamRoomClaimReading && state != teamRoomClaimCoding && state != teamRoomClaimStuck {
+		return response.ErrResp(errors.New("invalid claim state"), response.PARAM_NOT_VALID)
+	}
+	if _, routed, err := routeTeamRoomCommand[struct{}](teamRoomCommand{Op: teamRoomCmdClaim, RoomID: roomID, UserID: userID, ProblemID: problemID, State: state}); routed || err != nil {
+		return err
+	}
+	worker, err := getRunningTeamRoomWorker(userID, roomID, problemID)
+	if err != nil {
+		return err
+	}
+	worker.mu.Lock()
+	teamID, err := worker.getClaimTeam(userID, problemID)
+	if err != nil {
+		worker.mu.Unlock()
+		return err
+	}
+	action, err := worker.setClaim(teamRoomClaim{
+		ProblemID: problemID,
+		TeamID:    teamID,
+		UserID:    userID,
+		State:     state,
+	})
+	room := worker.room
+	worker.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	broadcastTeamRoomClaims(room, action, userID, problemID)
+	return nil
+}
+
+func (l *TeamRoomLogic) UnclaimProblem(ctx context.Context, userID int64, roomID int64, problemID string) error {
+	_ = ctx
+	if _, routed, err := routeTeamRoomCommand[struct{}](teamRoomCommand{Op: teamRoomCmdUnclaim, RoomID: roomID, UserID: userID, ProblemID: problemID}); routed || err != nil {
+		return err
+	}
+	worker, err := getRunningTeamRoomWorker(userID, roomID, problemID)
+	if err != nil {
+		return err
+	}
+	worker.mu.Lock()
+	players := worker.room.Players
+	teamID := 0
+	if parseTeamRoomExtra(worker.room.ExtraInfo).Competitive {
+		teamID = getTeamRoomPlayerTeam(players, userID)
+	}
+	released := worker.releaseClaim(teamRoomStatusKey{TeamID: teamID, ProblemID: problemID}, userID)
+	room := worker.room
+	worker.mu.Unlock()
+	if !released {
+		return response.ErrResp(errors.New("problem not claimed"), response.PARAM_NOT_VALID)
+	}
+	broadcastTeamRoomClaims(room, "unclaim", userID, problemID)
+	return nil
+}
+
+func getRunningTeamRoomWorker(userID int64, roomID int64, problemID string) (*teamRoomWorker, error) {
+	if userID == 0 || roomID == 0 || problemID == "" {
+		return nil, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	worker := GetTeamRoomManager().getWorker(roomID)
+	if worker == nil {
+		if _, err := getTeamRoom(roomID); err != nil {
+			return nil, err
+		}
+		return nil, response.ErrResp(errors.New("room not running"), response.PARAM_NOT_VALID)
+	}
+	return worker, nil
+}
+
+// getClaimTeam 合作模式所有人属于同一组，对抗模式按队伍区分
+func (w *teamRoomWorker) getClaimTeam(userID int64, problemID string) (int, error) {
+	if w.room.Status != 0 {
+		return 0, response.ErrResp(errors.New("room not running"), response.PARAM_NOT_VALID)
+	}
+	players := w.room.Players
+	if findTeamRoomPlayer(players, userID) < 0 {
+		return 0, response.ErrResp(errors.New("not in room"), response.PERMISSION_DENIED)
+	}
+	teamID := 0
+	if parseTeamRoomExtra(w.room.ExtraInfo).Competitive {
+		teamID = getTeamRoomPlayerTeam(players, userID)
+		if teamID == 0 {
+			return 0, response.ErrResp(errors.New("not in team"), response.PARAM_NOT_VALID)
+		}
+	}
+	if _, ok := w.problems[problemID]; !ok {
+		return 0, response.ErrResp(errors.New("problem not in room"), response.PARAM_NOT_VALID)
+	}
+	if w.getProblemStatus(teamID, problemID).Solved {
+		return 0, response.ErrResp(errors.New("problem solved"), response.PARAM_NOT_VALID)
+	}
+	return teamID, nil
+}
+
+// setClaim 返回 claim 或 update，题目已被队友认领时报错
+func (w *teamRoomWorker) setClaim(claim teamRoomClaim) (string, error) {
+	w.claimMu.Lock()
+	defer w.claimMu.Unlock()
+	key := teamRoomStatusKey{TeamID: claim.TeamID, ProblemID: claim.ProblemID}
+	now := time.Now().Unix()
+	old, ok := w.claims[key]
+	if ok && old.UserID != claim.UserID {
+		return "", response.ErrResp(errors.New("problem claimed by teammate"), response.PARAM_NOT_VALID)
+	}
+	claim.UpdatedAt = now
+	if ok {
+		claim.ClaimedAt = old.ClaimedAt
+		w.claims[key] = claim
+		return "update", nil
+	}
+	claim.ClaimedAt = now
+	w.claims[key] = claim
+	return "claim", nil
+}
+
+func (w *teamRoomWorker) releaseClaim(key teamRoomStatusKey, userID int64) bool {
+	w.claimMu.Lock()
+	defer w.claimMu.Unlock()
+	claim, ok := w.claims[key]
+	if !ok || claim.UserID != userID {
+		return false
+	}
+	delete(w.claims, key)
+	return true
+}
+
+// releaseSolvedClaim 题目通过后释放该队伍对这道题的认领
+func (w *teamRoomWorker) releaseSolvedClaim(teamID int, problemID string) bool {
+	w.claimMu.Lock()
+	defer w.claimMu.Unlock()
+	key := teamRoomStatusKey{TeamID: teamID, ProblemID: problemID}
+	if _, ok := w.claims[key]; !ok {
+		return false
+	}
+	delete(w.claims, key)
+	return true
+}
+
+func (w *teamRoomWorker) releaseUserClaims(userID int64) bool {
+	w.claimMu.Lock()
+	defer w.claimMu.Unlock()
+	released := false
+	for key, claim := range w.claims {
+		if claim.UserID == userID {
+			delete(w.claims, key)
+			released = true
+		}
+	}
+	return released
+}
+
+func (w *teamRoomWorker) listClaims() []teamRoomClaim {
+	w.claimMu.Lock()
+	defer w.claimMu.Unlock()
+	claims := make([]teamRoomClaim, 0, len(w.claims))
+	for _, claim := range w.claims {
+		claims = append(claims, claim)
+	}
+	sort.Slice(claims, func(i, j int) bool {
+		if claims[i].ClaimedAt != claims[j].ClaimedAt {
+			return claims[i].ClaimedAt < claims[j].ClaimedAt
+		}
+		return claims[i].ProblemID < claims[j].ProblemID
+	})
+	return claims
+}
+
+// releaseTeamRoomUserClaims 玩家离开、被踢或断线超过宽限期后释放其全部认领
+func releaseTeamRoomUserClaims(roomID int64, userID int64) {
+	worker := GetTeamRoomManager().getWorker(roomID)
+	if worker == nil || !worker.releaseUserClaims(userID) {
+		return
+	}
+	room, err := getTeamRoom(roomID)
+	if err != nil {
+		return
+	}
+	broadcastTeamRoomClaims(room, "release", userID, "")
+}
+
+// buildTeamRoomClaimInfos 对抗模式只能看到本队的认领，teamID 为0时不返回
+func buildTeamRoomClaimInfos(room model.TeamRoom, teamID int) []types.TeamRoomClaimInfo {
+	if room.Status != 0 {
+		return nil
+	}
+	worker := GetTeamRoomManager().getWorker(room.ID)
+	if worker == nil {
+		return nil
+	}
+	competitive := parseTeamRoomExtra(room.ExtraInfo).Competitive
+	if competitive && teamID == 0 {
+		return nil
+	}
+	var infos []types.TeamRoomClaimInfo
+	for _, claim := range worker.listClaims() {
+		if competitive && claim.TeamID != teamID {
+			continue
+		}
+		infos = append(infos, types.TeamRoomClaimInfo{
+			ProblemID: claim.ProblemID,
+			TeamID:    claim.TeamID,
+			UserID:    claim.UserID,
+			State:     claim.State,
+			ClaimedAt: claim.ClaimedAt,
+			UpdatedAt: claim.UpdatedAt,
+		})
+	}
+	return infos
+}
+
+// broadcastTeamRoomClaims 对抗模式下按观看者所在队伍分别生成，本次变化只推送给操作者所在队伍，
+// 其他队伍仍收到一条只含自己认领列表的推送，保持房间序号连续
+func broadcastTeamRoomClaims(room model.TeamRoom, action string, userID int64, problemID string) {
+	competitive := parseTeamRoomExtra(room.ExtraInfo).Competitive
+	players := room.Players
+	actorTeamID := getTeamRoomPlayerTeam(players, userID)
+	build := func(teamID int) types.WsResponse {
+		data := types.TeamRoomClaimUpdateData{
+			RoomID: room.ID,
+			Claims: buildTeamRoomClaimInfos(room, teamID),
+		}
+		if !competitive || (actorTeamID != 0 && teamID == actorTeamID) {
+			data.Action = action
+			data.UserID = userID
+			data.ProblemID = problemID
+		}
+		return types.WsResponse{
+			Type:    "team_room_claim_update",
+			Code:    response.SUCCESS.Code,
+			Message: response.SUCCESS.Msg,
+			Data:    data,
+		}
+	}
+	if !competitive {
+		GetWsHub().SendToRoom(room.ID, build(0))
+		return
+	}
+	cache := make(map[int]types.WsResponse)
+	GetWsHub().SendToRoomUsers(room.ID, func(viewerID int64) types.WsResponse {
+		teamID := getTeamRoomPlayerTeam(players, viewerID)
+		if resp, ok := cache[teamID]; ok {
+			return resp
+		}
+		resp := build(teamID)
+		cache[teamID] = resp
+		return resp
+	})
+}
@@ -0,0 +1,92 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"tgwp/global"
+	"tgwp/log/zlog"
+	"tgwp/model"
+	"tgwp/repo"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	teamRoomEventStart      = "start"
+	teamRoomEventJoin       = "join"
+	teamRoomEventLeave      = "leave"
+	teamRoomEventSubmission = "submission"
+	teamRoomEventSolve      = "solve"
+	teamRoomEventChat       = "chat"
+	teamRoomEventFinish     = "finish"
+)
+
+// ListEvents 只返回已结束房间的事件，进行中的房间不公开以免泄露提交结果
+func (l *TeamRoomLogic) ListEvents(ctx context.Context, req types.TeamRoomEventReq) (resp types.TeamRoomEventResp, err error) {
+	_ = ctx
+	roomID, err := parseTeamRoomID(req.RoomID)
+	if err != nil {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	room, err := getTeamRoom(roomID)
+	if err != nil {
+		return resp, err
+	}
+	if room.Status != 1 {
+		return resp, response.ErrResp(errors.New("room not finished"), response.PARAM_NOT_VALID)
+	}
+	if err := checkTeamRoomPrivateAccess(room, req.UserID); err != nil {
+		return resp, err
+	}
+	items, err := repo.NewTeamRoomEventRepo(global.DB).ListByRoom(roomID)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	startTime := room.StartTime
+	if startTime == 0 {
+		startTime = room.CreatedAt.Unix()
+	}
+	resp.RoomID = room.ID
+	resp.StartTime = startTime
+	resp.EndTime = room.EndTime
+	resp.Events = make([]types.TeamRoomEventInfo, 0, len(items))
+	for _, item := range items {
+		info := types.TeamRoomEventInfo{
+			Type:    item.Type,
+			UserID:  item.UserID,
+			TeamID:  item.TeamID,
+			EventAt: item.EventAt,
+			Offset:  item.EventAt - startTime,
+		}
+		if item.Data != "" {
+			info.Data = json.RawMessage(item.Data)
+		}
+		resp.Events = append(resp.Events, info)
+	}
+	return resp, nil
+}
+
+// recordTeamRoomEvent 追加一条房间事件，写入失败只记录日志不影响房间流程
+func recordTeamRoomEvent(roomID int64, eventType string, userID int64, teamID int, data interface{}) {
+	item := model.TeamRoomEvent{
+		RoomID:  roomID,
+		Type:    eventType,
+		UserID:  userID,
+		TeamID:  teamID,
+		EventAt: time.Now().Unix(),
+	}
+	if data != nil {
+		bytes, err := json.Marshal(data)
+		if err != nil {
+			zlog.Warnf("团队房间事件序列化失败：%v", err)
+			return
+		}
+		item.Data = string(bytes)
+	}
+	if err := repo.NewTeamRoomEventRepo(global.DB).Create(&item); err != nil {
+		zlog.Warnf("团队房间事件记录失败：%v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tgwp/model"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	teamRoomExportCSV  = "csv"
+	teamRoomExportJSON = "json"
+	teamRoomExportFeed = "feed"
+)
+
+// teamRoomFeedJudgements CF 结果到 CCS 判题类型的映射，未列出的按 WA 处理
+var teamRoomFeedJudgements = map[string]string{
+	"OK":                    "AC",
+	"WRONG_ANSWER":          "WA",
+	"TIME_LIMIT_EXCEEDED":   "TLE",
+	"MEMORY_LIMIT_EXCEEDED": "MLE",
+	"RUNTIME_ERROR":         "RTE",
+	"COMPILATION_ERROR":     "CE",
+}
+
+var teamRoomFeedJudgementNames = []struct {
+	ID   string
+	Name string
+}{
+	{"AC", "correct"},
+	{"WA", "wrong answer"},
+	{"TLE", "time limit exceeded"},
+	{"MLE", "memory limit exceeded"},
+	{"RTE", "run-time error"},
+	{"CE", "compiler error"},
+}
+
+// ExportRoom 导出已结束房间的榜单，format 为 csv、json 或 feed（CCS event feed，每行一个事件）
+func (l *TeamRoomLogic) ExportRoom(ctx context.Context, req types.TeamRoomExportReq) (resp types.TeamRoomExportResp, err error) {
+	_ = ctx
+	roomID, err := parseTeamRoomID(req.RoomID)
+	if err != nil {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	format := strings.ToLower(req.Format)
+	if format == "" {
+		format = teamRoomExportCSV
+	}
+	room, err := getTeamRoom(roomID)
+	if err != nil {
+		return resp, err
+	}
+	if room.Status != 1 {
+		return resp, response.ErrResp(errors.New("room not finished"), response.PARAM_NOT_VALID)
+	}
+	if err := checkTeamRoomPrivateAccess(room, req.UserID); err != nil {
+		return resp, err
+	}
+	name := fmt.Sprintf("team-room-%d", room.ID)
+	switch format {
+	case teamRoomExportCSV:
+		resp.Content, err = buildTeamRoomCSV(room)
+		resp.FileName = name + ".csv"
+		resp.ContentType = "text/csv; charset=utf-8"
+	case teamRoomExportJSON:
+		resp.Content, err = json.Marshal(types.TeamRoomExportData{
+			Room:       buildTeamRoomInfo(room),
+			Standings:  buildTeamRoomStandings(room),
+			ExportedAt: time.Now().Unix(),
+		})
+		resp.FileName = name + ".json"
+		resp.ContentType = "application/json"
+	case teamRoomExportFeed:
+		resp.Content, err = buildTeamRoomEventFeed(room)
+		resp.FileName = name + "-event-feed.ndjson"
+		resp.ContentType = "application/x-ndjson"
+	default:
+		return resp, response.ErrResp(errors.New("unsupported format"), response.PARAM_NOT_VALID)
+	}
+	if err != nil {
+		return resp, response.ErrResp(err, response.INTERNAL_ERROR)
+	}
+	return resp, nil
+}
+
+// buildTeamRoomStandings 合作模式把全体玩家作为一支队伍
+func buildTeamRoomStandings(room model.TeamRoom) []types.TeamRoomScoreboardItem {
+	extra := parseTeamRoomExtra(room.ExtraInfo)
+	if !extra.Competitive {
+		extra.Teams = []teamRoomTeam{{TeamID: 0, Name: "合作队伍"}}
+		bytes, _ := json.Marshal(extra)
+		room.ExtraInfo = string(bytes)
+	}
+	return buildTeamRoomScoreboard(room)
+}
+
+// buildTeamRoomCSV 每道题一列，通过记为“尝试次数/通过分钟”，未通过记为“-尝试次数”，带 BOM 方便表格软件识别中文
+func buildTeamRoomCSV(room model.TeamRoom) ([]byte, error) {
+	extra := parseTeamRoomExtra(room.ExtraInfo)
+	problems := room.Problems
+	members := make(map[int][]string)
+	for _, p := range room.Players {
+		teamID := p.TeamID
+		if !extra.Competitive {
+			teamID = 0
+		}
+		members[teamID] = append(members[teamID], p.Username)
+	}
+	var buf bytes.Buffer
+	buf.WriteString("\xEF\xBB\xBF")
+	writer := csv.NewWriter(&buf)
+	header := []string{"Rank", "Team", "Members", "Solved", "Penalty"}
+	for i, p := range problems {
+		header = append(header, fmt.Sprintf("%s (%s)", getTeamRoomProblemLabel(i), p.ProblemID))
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	for _, item := range buildTeamRoomStandings(room) {
+		row := []string{
+			strconv.Itoa(item.Rank),
+			item.Name,
+			strings.Join(members[item.TeamID], " "),
+			strconv.Itoa(item.Solved),
+			strconv.FormatInt(item.Penalty, 10),
+		}
+		for _, p := range item.Problems {
+			switch {
+			case p.Solved:
+				row = append(row, fmt.Sprintf("%d/%d", p.WrongCount+1, p.SolvedAt/60))
+			case p.WrongCount > 0:
+				row = append(row, fmt.Sprintf("-%d", p.WrongCount))
+			default:
+				row = append(row, "")
+			}
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// buildTeamRoomEventFeed 按 CCS Contest API 的 event feed 格式输出，提交和判题由 SubmissionRecords 生成
+func buildTeamRoomEventFeed(room model.TeamRoom) ([]byte, error) {
+	extra := parseTeamRoomExtra(room.ExtraInfo)
+	startTime := getTeamRoomStartTime(room)
+	contestID := strconv.FormatInt(room.ID, 10)
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	write := func(eventType string, id string, data interface{}) error {
+		return encoder.Encode(map[string]interface{}{
+			"type": eventType,
+			"id":   id,
+			"data": data,
+		})
+	}
+	contest := map[string]interface{}{
+		"id":           contestID,
+		"name":         fmt.Sprintf("%s %s", room.Mode, contestID),
+		"formal_name":  fmt.Sprintf("Team Room %s", contestID),
+		"start_time":   formatTeamRoomFeedTime(startTime),
+		"duration":     formatTeamRoomFeedDuration(time.Duration(extra.DurationSeconds) * time.Second),
+		"penalty_time": getTeamRoomPenaltyPerWrong(extra),
+	}
+	if extra.FreezeMinutes > 0 {
+		contest["scoreboard_freeze_duration"] = formatTeamRoomFeedDuration(time.Duration(extra.FreezeMinutes) * time.Minute)
+	}
+	if err := write("contests", contestID, contest); err != nil {
+		return nil, err
+	}
+	for _, j := range teamRoomFeedJudgementNames {
+		err := write("judgement-types", j.ID, map[string]interface{}{
+			"id":      j.ID,
+			"name":    j.Name,
+			"penalty": j.ID != "AC",
+			"solved":  j.ID == "AC",
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, p := range room.Problems {
+		err := write("problems", p.ProblemID, map[string]interface{}{
+			"id":      p.ProblemID,
+			"label":   getTeamRoomProblemLabel(i),
+			"name":    p.ProblemID,
+			"ordinal": i,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, item := range buildTeamRoomStandings(room) {
+		teamID := strconv.Itoa(item.TeamID)
+		err := write("teams", teamID, map[string]interface{}{
+			"id":    teamID,
+			"label": teamID,
+			"name":  item.Name,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range room.Submissions {
+		id := strconv.FormatInt(s.SubmissionID, 10)
+		at := time.Unix(s.SubmitTime, 0)
+		teamID := s.TeamID
+		if !extra.Competitive {
+			teamID = 0
+		}
+		err := write("submissions", id, map[string]interface{}{
+			"id":           id,
+			"problem_id":   s.ProblemID,
+			"team_id":      strconv.Itoa(teamID),
+			"time":         formatTeamRoomFeedTime(at),
+			"contest_time": formatTeamRoomFeedDuration(at.Sub(startTime)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		judgement, ok := teamRoomFeedJudgements[s.Verdict]
+		if !ok {
+			judgement = "WA"
+		}
+		err = write("judgements", id, map[string]interface{}{
+			"id":                 id,
+			"submission_id":      id,
+			"judgement_type_id":  judgement,
+			"start_time":         formatTeamRoomFeedTime(at),
+			"start_contest_time": formatTeamRoomFeedDuration(at.Sub(startTime)),
+			"end_time":           formatTeamRoomFeedTime(at),
+			"end_contest_time":   formatTeamRoomFeedDuration(at.Sub(startTime)),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	endTime := formatTeamRoomFeedTime(time.Unix(room.EndTime, 0))
+	err := write("state", "", map[string]interface{}{
+		"started":        formatTeamRoomFeedTime(startTime),
+		"ended":          endTime,
+		"finalized":      endTime,
+		"end_of_updates": endTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// getTeamRoomProblemLabel 题目按顺序编号为 A、B、C……，超过26题时使用 AA、AB
+func getTeamRoomProblemLabel(index int) string {
+	label := ""
+	for index >= 0 {
+		label = string(rune('A'+index%26)) + label
+		index = index/26 - 1
+	}
+	return label
+}
+
+func formatTeamRoomFeedTime(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+// formatTeamRoomFeedDuration CCS 的相对时间格式 h:mm:ss.uuu
+func formatTeamRoomFeedDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%s%d:%02d:%02d.%03d", sign, ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,178 @@
+package logic
+
+import (
+	"slices"
+	"time"
+
+	"tgwp/model"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+// teamRoomFrozenVerdict 封榜后其他队伍的提交结果对外显示为待定
+const teamRoomFrozenVerdict = "FROZEN"
+
+type teamRoomStatusKey struct {
+	TeamID    int
+	ProblemID string
+}
+
+// getTeamRoomFreezeAt 对抗模式开始后才有封榜时间，未配置封榜返回0
+func getTeamRoomFreezeAt(room model.TeamRoom, extra teamRoomExtraInfo) int64 {
+	if !extra.Competitive || extra.FreezeMinutes <= 0 || room.Status == 2 {
+		return 0
+	}
+	endAt := getTeamRoomEndAt(room, extra, time.Now())
+	return endAt.Unix() - int64(extra.FreezeMinutes*60)
+}
+
+func isTeamRoomFrozen(room model.TeamRoom) bool {
+	if room.Status != 0 {
+		return false
+	}
+	freezeAt := getTeamRoomFreezeAt(room, parseTeamRoomExtra(room.ExtraInfo))
+	return freezeAt > 0 && time.Now().Unix() >= freezeAt
+}
+
+// maskFrozenTeamRoom 封榜期间按观看队伍生成视图：本队结果照常显示，其他队伍封榜后的提交只显示为待定，
+// 题目状态回退到封榜前，viewerTeamID 为0时所有队伍都被遮挡
+func maskFrozenTeamRoom(room model.TeamRoom, viewerTeamID int) model.TeamRoom {
+	if !isTeamRoomFrozen(room) {
+		return room
+	}
+	extra := parseTeamRoomExtra(room.ExtraInfo)
+	freezeAt := getTeamRoomFreezeAt(room, extra)
+	freezeOffset := extra.DurationSeconds - int64(extra.FreezeMinutes*60)
+	penalty := getTeamRoomPenaltyPerWrong(extra)
+	wrongCount := make(map[teamRoomStatusKey]int)
+	submissions := slices.Clone(room.Submissions)
+	for i := range submissions {
+		s := &submissions[i]
+		if viewerTeamID != 0 && s.TeamID == viewerTeamID {
+			continue
+		}
+		if s.SubmitTime >= freezeAt {
+			s.Verdict = teamRoomFrozenVerdict
+			continue
+		}
+		if s.Verdict != "OK" {
+			wrongCount[teamRoomStatusKey{TeamID: s.TeamID, ProblemID: s.ProblemID}]++
+		}
+	}
+	status := slices.Clone(room.ProblemStatus)
+	for i := range status {
+		item := &status[i]
+		if viewerTeamID != 0 && item.TeamID == viewerTeamID {
+			continue
+		}
+		if item.Solved && item.SolvedAt < freezeOffset {
+			continue
+		}
+		item.Solved = false
+		item.SolvedBy = 0
+		item.SolvedAt = 0
+		item.WrongCount = wrongCount[teamRoomStatusKey{TeamID: item.TeamID, ProblemID: item.ProblemID}]
+		item.Penalty = item.WrongCount * penalty
+	}
+	room.Submissions = submissions
+	room.ProblemStatus = status
+	return room
+}
+
+// sendTeamRoomView 封榜期间按队伍分别生成推送内容，同一队伍只生成一次
+func sendTeamRoomView(room model.TeamRoom, build func(teamID int) types.WsResponse) {
+	if !isTeamRoomFrozen(room) {
+		GetWsHub().SendToRoom(room.ID, build(0))
+		return
+	}
+	players := room.Players
+	cache := make(map[int]types.WsResponse)
+	GetWsHub().SendToRoomUsers(room.ID, func(userID int64) types.WsResponse {
+		teamID := getTeamRoomPlayerTeam(players, userID)
+		if resp, ok := cache[teamID]; ok {
+			return resp
+		}
+		resp := build(teamID)
+		cache[teamID] = resp
+		return resp
+	})
+}
+
+// checkFreeze 到达封榜时间时通知一次并推送封榜后的榜单
+func (w *teamRoomWorker) checkFreeze() {
+	if w.frozen || !isTeamRoomFrozen(w.room) {
+		return
+	}
+	w.frozen = true
+	extra := parseTeamRoomExtra(w.room.ExtraInfo)
+	GetWsHub().SendToRoom(w.room.ID, types.WsResponse{
+		Type:    "team_room_freeze",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomFreezeData{
+			RoomID:   w.room.ID,
+			FreezeAt: getTeamRoomFreezeAt(w.room, extra),
+			EndAt:    getTeamRoomEndAt(w.room, extra, time.Now()).Unix(),
+		},
+	})
+	broadcastTeamRoomScoreboard(w.room)
+}
+
+// buildTeamRoomRevealSteps 按 ICPC 滚榜顺序揭晓：每次揭晓当前排名最靠后且有待定题目的队伍的第一道待定题
+func buildTeamRoomRevealSteps(frozen []types.TeamRoomScoreboardItem, final []types.TeamRoomScoreboardItem, penaltyPerWrong int) []types.TeamRoomRevealStep {
+	finalMap := make(map[teamRoomStatusKey]types.TeamRoomScoreboardProblem)
+	for _, item := range final {
+		for _, p := range item.Problems {
+			finalMap[teamRoomStatusKey{TeamID: item.TeamID, ProblemID: p.ProblemID}] = p
+		}
+	}
+	board := make([]types.TeamRoomScoreboardItem, len(frozen))
+	for i, item := range frozen {
+		board[i] = item
+		board[i].Problems = append([]types.TeamRoomScoreboardProblem(nil), item.Problems...)
+	}
+	var steps []types.TeamRoomRevealStep
+	for {
+		rankTeamRoomScoreboard(board)
+		teamIndex, problemIndex := -1, -1
+		for i := len(board) - 1; i >= 0 && teamIndex < 0; i-- {
+			for j, p := range board[i].Problems {
+				if p.Pending > 0 {
+					teamIndex, problemIndex = i, j
+					break
+				}
+			}
+		}
+		if teamIndex < 0 {
+			return steps
+		}
+		item := &board[teamIndex]
+		revealed := finalMap[teamRoomStatusKey{TeamID: item.TeamID, ProblemID: item.Problems[problemIndex].ProblemID}]
+		revealed.Pending = 0
+		item.Problems[problemIndex] = revealed
+		item.Solved = 0
+		item.Penalty = 0
+		for _, p := range item.Problems {
+			if p.Solved {
+				item.Solved++
+				item.Penalty += p.SolvedAt/60 + int64(p.WrongCount*penaltyPerWrong)
+			}
+		}
+		teamID := item.TeamID
+		rankTeamRoomScoreboard(board)
+		step := types.TeamRoomRevealStep{
+			TeamID:     teamID,
+			ProblemID:  revealed.ProblemID,
+			Solved:     revealed.Solved,
+			SolvedAt:   revealed.SolvedAt,
+			WrongCount: revealed.WrongCount,
+		}
+		for _, item := range board {
+			if item.TeamID == teamID {
+				step.Rank = item.Rank
+				break
+			}
+		}
+		steps = append(steps, step)
+	}
+}
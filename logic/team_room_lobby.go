@@ -0,0 +1,191 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"tgwp/global"
+	"tgwp/log/zlog"
+	"tgwp/model"
+	"tgwp/repo"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	teamRoomStartCountdown   = 10 * time.Second
+	teamRoomLobbyInterval    = time.Second
+	teamRoomMaxScheduleAhead = 24 * time.Hour
+	teamRoomLobbyTimeout     = 2 * time.Hour
+)
+
+func (l *TeamRoomLogic) SetReady(ctx context.Context, userID int64, roomID int64, ready bool) (types.TeamRoomInfo, error) {
+	_ = ctx
+	if userID == 0 || roomID == 0 {
+		return types.TeamRoomInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomInfo](teamRoomCommand{Op: teamRoomCmdReady, RoomID: roomID, UserID: userID, Flag: ready}); routed || err != nil {
+		return resp, err
+	}
+	room, err := updateTeamRoom(roomID, func(room *model.TeamRoom) error {
+		if room.Status != 2 {
+			return response.ErrResp(errors.New("room started"), response.PARAM_NOT_VALID)
+		}
+		players := slices.Clone(room.Players)
+		index := findTeamRoomPlayer(players, userID)
+		if index < 0 {
+			return response.ErrResp(errors.New("not in room"), response.PERMISSION_DENIED)
+		}
+		if players[index].Ready == ready {
+			return nil
+		}
+		players[index].Ready = ready
+		return saveTeamRoomPlayers(room, players)
+	})
+	if err != nil {
+		return types.TeamRoomInfo{}, err
+	}
+	return buildTeamRoomInfo(room), nil
+}
+
+// StartNow 房主跳过准备和预约时间，倒计时结束后直接开始
+func (l *TeamRoomLogic) StartNow(ctx context.Context, userID int64, roomID int64) (types.TeamRoomInfo, error) {
+	_ = ctx
+	if userID == 0 || roomID == 0 {
+		return types.TeamRoomInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomInfo](teamRoomCommand{Op: teamRoomCmdStart, RoomID: roomID, UserID: userID}); routed || err != nil {
+		return resp, err
+	}
+	room, err := updateTeamRoom(roomID, func(room *model.TeamRoom) error {
+		if room.Status != 2 {
+			return response.ErrResp(errors.New("room started"), response.PARAM_NOT_VALID)
+		}
+		if err := checkTeamRoomCreator(*room, userID); err != nil {
+			return err
+		}
+		extra := parseTeamRoomExtra(room.ExtraInfo)
+		startAt := time.Now().Add(teamRoomStartCountdown).Unix()
+		if extra.StartAt != 0 && extra.StartAt <= startAt {
+			return nil
+		}
+		extra.StartAt = startAt
+		return saveTeamRoomExtra(room, extra)
+	})
+	if err != nil {
+		return types.TeamRoomInfo{}, err
+	}
+	return buildTeamRoomInfo(room), nil
+}
+
+// waitStart 等待阶段每秒检查一次：到达预约时间或全员准备后倒计时开始，房间开始返回 true
+// 未预约的房间创建后超过 teamRoomLobbyTimeout 仍未开始则直接关闭，避免一直轮询提交
+func (w *teamRoomWorker) waitStart() bool {
+	ticker := time.NewTicker(teamRoomLobbyInterval)
+	defer ticker.Stop()
+	var readyAt time.Time
+	for {
+		w.mu.Lock()
+		if w.room.Status != 2 {
+			started := w.room.Status == 0
+			w.mu.Unlock()
+			return started
+		}
+		// 等待阶段就开始轮询，开始时才能准确排除之前的提交
+		w.syncTrackedPlayers()
+		if allTeamRoomPlayersReady(w.room.Players) {
+			if readyAt.IsZero() {
+				readyAt = time.Now().Add(teamRoomStartCountdown)
+			}
+		} else {
+			readyAt = time.Time{}
+		}
+		startAt := readyAt
+		extra := parseTeamRoomExtra(w.room.ExtraInfo)
+		if extra.StartAt > 0 {
+			if scheduled := time.Unix(extra.StartAt, 0); startAt.IsZero() || scheduled.Before(startAt) {
+				startAt = scheduled
+			}
+		}
+		if startAt.IsZero() && time.Since(w.room.CreatedAt) > teamRoomLobbyTimeout {
+			w.finish(false)
+			w.mu.Unlock()
+			return false
+		}
+		if !startAt.IsZero() {
+			if !time.Now().Before(startAt) {
+				w.start()
+				w.mu.Unlock()
+				return true
+			}
+			if time.Until(startAt) <= teamRoomStartCountdown {
+				w.pushCountdown(startAt)
+			}
+		}
+		w.mu.Unlock()
+		select {
+		case <-ticker.C:
+		case <-w.stopCh:
+			return false
+		}
+	}
+}
+
+// start 开始计时并公开题目，开始前已有的提交不计入
+func (w *teamRoomWorker) start() {
+	now := time.Now()
+	players := w.room.Players
+	for _, player := range players {
+		for _, submission := range GetCfQueue().GetUserSubmissions(player.UserID) {
+			w.processed[submission.SubmissionID] = struct{}{}
+		}
+	}
+	w.room.Status = 0
+	w.room.StartTime = now.Unix()
+	w.startTime = now
+	if err := repo.NewTeamRoomRepo(global.DB).UpdateStart(w.room.ID, w.room.StartTime); err != nil {
+		zlog.Warnf("团队房间开始状态保存失败：%v", err)
+	}
+	recordTeamRoomEvent(w.room.ID, teamRoomEventStart, 0, 0, nil)
+	GetWsHub().SendToRoom(w.room.ID, types.WsResponse{
+		Type:    "team_room_start",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomInfoData{
+			Room: buildTeamRoomInfo(w.room),
+		},
+	})
+	broadcastTeamRoomScoreboard(w.room)
+}
+
+func (w *teamRoomWorker) pushCountdown(startAt time.Time) {
+	remaining := time.Until(startAt)
+	if remaining < 0 {
+		remaining = 0
+	}
+	GetWsHub().SendToRoom(w.room.ID, types.WsResponse{
+		Type:    "team_room_countdown",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomCountdownData{
+			RoomID:     w.room.ID,
+			StartAt:    startAt.Unix(),
+			Remaining:  int64(remaining.Round(time.Second).Seconds()),
+			ServerTime: time.Now().Unix(),
+		},
+	})
+}
+
+func allTeamRoomPlayersReady(players []model.TeamRoomPlayerItem) bool {
+	if len(players) == 0 {
+		return false
+	}
+	for _, player := range players {
+		if !player.Ready {
+			return false
+		}
+	}
+	return true
+}
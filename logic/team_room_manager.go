@@ -3,7 +3,7 @@ package logic
 import (
 	"context"
 	"encoding/json"
-	"strconv"
+	"slices"
 	"sync"
 	"time"
 
@@ -25,16 +25,22 @@ type TeamRoomManager struct {
 	workers map[int64]*teamRoomWorker
 }
 
+// teamRoomWorker 持有运行中房间的最新状态，外部修改房间需通过 updateTeamRoom 在 mu 内进行
 type teamRoomWorker struct {
-	manager     *TeamRoomManager
-	room        model.TeamRoom
-	problems    map[string]teamRoomProblem
-	statusList  []teamRoomProblemStatus
-	submissions []teamRoomSubmissionRecord
-	processed   map[int64]struct{}
-	startTime   time.Time
-	duration    time.Duration
-	stopCh      chan struct{}
+	mu        sync.Mutex
+	manager   *TeamRoomManager
+	room      model.TeamRoom
+	problems  map[string]model.TeamRoomProblemItem
+	processed map[int64]struct{}
+	startTime time.Time
+	duration  time.Duration
+	penalty   int
+	frozen    bool
+	tracked   map[int64]struct{}
+	claimMu   sync.Mutex
+	claims    map[teamRoomStatusKey]teamRoomClaim
+	resetCh   chan struct{}
+	stopCh    chan struct{}
 }
 
 var teamRoomManagerOnce sync.Once
@@ -49,42 +55,56 @@ func GetTeamRoomManager() *TeamRoomManager {
 	return teamRoomManager
 }
 
+// StartRoom 只有持有房间租约的实例运行 worker，其他实例的命令会转发到该实例
 func (m *TeamRoomManager) StartRoom(room model.TeamRoom) {
+	if !acquireTeamRoomLease(room.ID) {
+		return
+	}
 	m.mu.Lock()
 	if _, ok := m.workers[room.ID]; ok {
 		m.mu.Unlock()
 		return
 	}
-	problems := parseTeamRoomProblems(room.ProblemList)
-	problemMap := make(map[string]teamRoomProblem, len(problems))
-	for _, p := range problems {
+	problemMap := make(map[string]model.TeamRoomProblemItem, len(room.Problems))
+	for _, p := range room.Problems {
 		problemMap[p.ProblemID] = p
 	}
-	statusList := parseTeamRoomProblemStatus(room.ProblemStatus)
-	submissions := parseTeamRoomSubmissions(room.SubmissionRecords)
 	processed := make(map[int64]struct{})
-	for _, s := range submissions {
+	for _, s := range room.Submissions {
 		processed[s.SubmissionID] = struct{}{}
 	}
 	worker := &teamRoomWorker{
-		manager:     m,
-		room:        room,
-		problems:    problemMap,
-		statusList:  statusList,
-		submissions: submissions,
-		processed:   processed,
-		startTime:   room.CreatedAt,
-		duration:    getTeamRoomDuration(room.Mode),
-		stopCh:      make(chan struct{}),
-	}
-	if extra := parseTeamRoomExtra(room.ExtraInfo); extra.DurationSeconds > 0 {
+		manager:   m,
+		room:      room,
+		problems:  problemMap,
+		processed: processed,
+		tracked:   make(map[int64]struct{}),
+		claims:    make(map[teamRoomStatusKey]teamRoomClaim),
+		startTime: time.Unix(room.StartTime, 0),
+		duration:  getTeamRoomDuration(room.Mode),
+		resetCh:   make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+	// 旧数据没有记录开始时间，以创建时间为准
+	if room.StartTime == 0 {
+		worker.startTime = room.CreatedAt
+	}
+	extra := parseTeamRoomExtra(room.ExtraInfo)
+	if extra.DurationSeconds > 0 {
 		worker.duration = time.Duration(extra.DurationSeconds) * time.Second
 	}
+	worker.penalty = getTeamRoomPenaltyPerWrong(extra)
 	m.workers[room.ID] = worker
 	m.mu.Unlock()
 	go worker.run()
 }
 
+func (m *TeamRoomManager) getWorker(roomID int64) *teamRoomWorker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.workers[roomID]
+}
+
 func (m *TeamRoomManager) StopRoom(roomID int64) {
 	m.mu.Lock()
 	worker, ok := m.workers[roomID]
@@ -93,22 +113,75 @@ func (m *TeamRoomManager) StopRoom(roomID int64) {
 		delete(m.workers, roomID)
 	}
 	m.mu.Unlock()
+	if ok {
+		releaseTeamRoomLease(roomID)
+	}
+	getTeamRoomPresence().removeRoom(roomID)
+}
+
+func (m *TeamRoomManager) roomIDs() []int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := make([]int64, 0, len(m.workers))
+	for id := range m.workers {
+		ids = append(ids, id)
+	}
+	return ids
 }
 
 func (w *teamRoomWorker) run() {
+	defer w.untrackPlayers()
+	if !w.waitStart() {
+		return
+	}
 	ticker := time.NewTicker(teamRoomCheckInterval)
 	defer ticker.Stop()
+	endAt, paused := w.clockState()
+	clock := newRoomClock(endAt)
+	defer clock.stop()
+	if paused {
+		clock.pause()
+	}
+	w.pushTimer("room_timer", clock.endAt)
 	for {
 		select {
 		case <-ticker.C:
 			w.tick()
+		case <-clock.ticker.C:
+			w.pushTimer("room_timer", clock.endAt)
+		case <-clock.warning.C:
+			w.pushTimer("room_timer_warning", clock.endAt)
+		case <-w.resetCh:
+			endAt, paused := w.clockState()
+			clock.reset(endAt)
+			if paused {
+				clock.pause()
+			}
+		case <-clock.deadline.C:
+			w.mu.Lock()
+			// 延长后旧计时器可能先到期，以实际进行时间为准
+			if w.isTimeout() {
+				w.finish(false)
+			}
+			w.mu.Unlock()
 		case <-w.stopCh:
 			return
 		}
 	}
 }
 
+func (w *teamRoomWorker) pushTimer(msgType string, endAt time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.room.Status != 0 || w.isPaused() {
+		return
+	}
+	GetWsHub().SendToRoom(w.room.ID, buildRoomTimerResp(msgType, roomTypeTeam, w.room.ID, endAt))
+}
+
 func (w *teamRoomWorker) tick() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	if w.room.Status != 0 {
 		return
 	}
@@ -116,11 +189,13 @@ func (w *teamRoomWorker) tick() {
 		w.finish(false)
 		return
 	}
-	userIDs := GetWsHub().ActiveRoomUserIDs(w.room.ID)
-	if len(userIDs) == 0 {
-		return
-	}
-	for _, userID := range userIDs {
+	w.checkFreeze()
+	w.syncTrackedPlayers()
+	extra := parseTeamRoomExtra(w.room.ExtraInfo)
+	now := time.Now().Unix()
+	// 房间内所有成员的提交都计入，不要求在线
+	for _, player := range w.room.Players {
+		userID := player.UserID
 		submissions := GetCfQueue().GetUserSubmissions(userID)
 		if len(submissions) == 0 {
 			continue
@@ -129,6 +204,10 @@ func (w *teamRoomWorker) tick() {
 			if submission.ProblemID == "" {
 				continue
 			}
+			// 加入时缓存中还没有的旧提交按提交时间排除
+			if submission.CreatedAt > 0 && submission.CreatedAt < w.startTime.Unix() {
+				continue
+			}
 			if _, ok := w.problems[submission.ProblemID]; !ok {
 				continue
 			}
@@ -138,6 +217,15 @@ func (w *teamRoomWorker) tick() {
 			if isPendingVerdict(submission.Verdict) {
 				continue
 			}
+			// 按提交时间排除暂停期间的提交，缺少提交时间时按当前时间判断
+			submitAt := submission.CreatedAt
+			if submitAt == 0 {
+				submitAt = now
+			}
+			if isTeamRoomPausedAt(extra, submitAt) {
+				w.processed[submission.SubmissionID] = struct{}{}
+				continue
+			}
 			w.handleSubmission(userID, submission)
 			if w.allSolved() {
 				w.finish(true)
@@ -149,135 +237,283 @@ func (w *teamRoomWorker) tick() {
 
 func (w *teamRoomWorker) handleSubmission(userID int64, submission CfSubmission) {
 	w.processed[submission.SubmissionID] = struct{}{}
-	submitTime := time.Now().Unix()
-	w.submissions = append(w.submissions, teamRoomSubmissionRecord{
+	extra := parseTeamRoomExtra(w.room.ExtraInfo)
+	teamID := 0
+	if extra.Competitive {
+		teamID = getTeamRoomPlayerTeam(w.room.Players, userID)
+		// 对抗模式下未加入队伍的提交不计入
+		if teamID == 0 {
+			return
+		}
+	}
+	record := model.TeamRoomSubmissionItem{
 		SubmissionID: submission.SubmissionID,
 		ProblemID:    submission.ProblemID,
 		UserID:       userID,
+		TeamID:       teamID,
 		Verdict:      submission.Verdict,
-		SubmitTime:   submitTime,
-	})
-	status := w.getProblemStatus(submission.ProblemID)
+		SubmitTime:   time.Now().Unix(),
+	}
+	w.room.Submissions = append(w.room.Submissions, record)
+	status := w.getProblemStatus(teamID, submission.ProblemID)
 	changed := false
 	if submission.Verdict == "OK" {
 		if !status.Solved {
 			status.Solved = true
 			status.SolvedBy = userID
-			status.SolvedAt = int64(time.Since(w.startTime).Seconds())
+			status.SolvedAt = int64(w.elapsed().Seconds())
 			changed = true
 		}
 	} else {
 		if !status.Solved {
-			status.Penalty += teamRoomPenaltyPerWrong
+			status.Penalty += w.penalty
+			status.WrongCount++
 			changed = true
 		}
 	}
 	if changed {
 		w.setProblemStatus(status)
 	}
-	w.flushSubmissions()
+	recordTeamRoomEvent(w.room.ID, teamRoomEventSubmission, userID, teamID, map[string]interface{}{
+		"submission_id": submission.SubmissionID,
+		"problem_id":    submission.ProblemID,
+		"verdict":       submission.Verdict,
+	})
+	if changed && status.Solved && w.releaseSolvedClaim(teamID, status.ProblemID) {
+		broadcastTeamRoomClaims(w.room, "solved", userID, status.ProblemID)
+	}
+	if changed && status.Solved {
+		recordTeamRoomEvent(w.room.ID, teamRoomEventSolve, userID, teamID, map[string]interface{}{
+			"problem_id":  status.ProblemID,
+			"solved_at":   status.SolvedAt,
+			"wrong_count": status.WrongCount,
+		})
+	}
+	w.saveSubmission(record)
 	if changed {
-		w.flushProblemStatus()
+		w.saveProblemStatus(status)
 	}
-	GetWsHub().SendToRoom(w.room.ID, types.WsResponse{
-		Type:    "team_room_update",
-		Code:    response.SUCCESS.Code,
-		Message: response.SUCCESS.Msg,
-		Data: map[string]interface{}{
-			"room":         buildTeamRoomInfo(w.room),
-			"user_id":      strconv.FormatInt(userID, 10),
-			"problem_id":   submission.ProblemID,
-			"last_verdict": submission.Verdict,
-		},
+	sendTeamRoomView(w.room, func(viewTeamID int) types.WsResponse {
+		verdict := submission.Verdict
+		if isTeamRoomFrozen(w.room) && viewTeamID != teamID {
+			verdict = teamRoomFrozenVerdict
+		}
+		return types.WsResponse{
+			Type:    "team_room_update",
+			Code:    response.SUCCESS.Code,
+			Message: response.SUCCESS.Msg,
+			Data: types.TeamRoomUpdateData{
+				Room:        buildTeamRoomViewInfo(w.room, viewTeamID),
+				UserID:      userID,
+				ProblemID:   submission.ProblemID,
+				LastVerdict: verdict,
+			},
+		}
 	})
+	if extra.Competitive && changed {
+		broadcastTeamRoomScoreboard(w.room)
+	}
 }
 
-func (w *teamRoomWorker) getProblemStatus(problemID string) teamRoomProblemStatus {
-	for _, item := range w.statusList {
-		if item.ProblemID == problemID {
+// syncTrackedPlayers 让 CfQueue 持续轮询房间成员，成员离开后取消登记
+func (w *teamRoomWorker) syncTrackedPlayers() {
+	current := make(map[int64]struct{})
+	for _, player := range w.room.Players {
+		current[player.UserID] = struct{}{}
+		if _, ok := w.tracked[player.UserID]; !ok {
+			GetCfQueue().TrackUser(player.UserID)
+			w.tracked[player.UserID] = struct{}{}
+		}
+	}
+	for userID := range w.tracked {
+		if _, ok := current[userID]; !ok {
+			GetCfQueue().UntrackUser(userID)
+			delete(w.tracked, userID)
+		}
+	}
+}
+
+func (w *teamRoomWorker) untrackPlayers() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for userID := range w.tracked {
+		GetCfQueue().UntrackUser(userID)
+		delete(w.tracked, userID)
+	}
+}
+
+func (w *teamRoomWorker) getProblemStatus(teamID int, problemID string) model.TeamRoomProblemStatusItem {
+	for _, item := range w.room.ProblemStatus {
+		if item.TeamID == teamID && item.ProblemID == problemID {
 			return item
 		}
 	}
-	return teamRoomProblemStatus{ProblemID: problemID}
+	return model.TeamRoomProblemStatusItem{ProblemID: problemID, TeamID: teamID}
 }
 
-func (w *teamRoomWorker) setProblemStatus(status teamRoomProblemStatus) {
-	for i := range w.statusList {
-		if w.statusList[i].ProblemID == status.ProblemID {
-			w.statusList[i] = status
+// setProblemStatus 房间快照与 worker 共享底层数组，修改已有条目前先复制
+func (w *teamRoomWorker) setProblemStatus(status model.TeamRoomProblemStatusItem) {
+	for i := range w.room.ProblemStatus {
+		if w.room.ProblemStatus[i].TeamID == status.TeamID && w.room.ProblemStatus[i].ProblemID == status.ProblemID {
+			statusList := slices.Clone(w.room.ProblemStatus)
+			statusList[i] = status
+			w.room.ProblemStatus = statusList
 			return
 		}
 	}
-	w.statusList = append(w.statusList, status)
+	w.room.ProblemStatus = append(w.room.ProblemStatus, status)
 }
 
+// allSolved 对抗模式下需所有队伍都通过全部题目
 func (w *teamRoomWorker) allSolved() bool {
-	for _, item := range w.statusList {
+	for _, item := range w.room.ProblemStatus {
 		if !item.Solved {
 			return false
 		}
 	}
-	return len(w.statusList) > 0
+	return len(w.room.ProblemStatus) > 0
 }
 
 func (w *teamRoomWorker) isTimeout() bool {
 	if w.duration <= 0 {
 		return false
 	}
-	return time.Since(w.startTime) >= w.duration
+	if w.isPaused() {
+		return false
+	}
+	return w.elapsed() >= w.duration
 }
 
-func (w *teamRoomWorker) flushSubmissions() {
-	bytes, _ := json.Marshal(w.submissions)
-	w.room.SubmissionRecords = string(bytes)
-	_ = repo.NewTeamRoomRepo(global.DB).UpdateSubmissionRecords(w.room.ID, w.room.SubmissionRecords)
+// saveSubmission 每条提交单独写入，内存中的提交记录由调用方追加
+func (w *teamRoomWorker) saveSubmission(record model.TeamRoomSubmissionItem) {
+	item := toTeamRoomSubmissionModel(w.room.ID, record)
+	if err := repo.NewTeamRoomSubmissionRepo(global.DB).Create(&item); err != nil {
+		zlog.Warnf("团队房间提交记录保存失败：%v", err)
+	}
 }
 
-func (w *teamRoomWorker) flushProblemStatus() {
-	bytes, _ := json.Marshal(w.statusList)
-	w.room.ProblemStatus = string(bytes)
-	_ = repo.NewTeamRoomRepo(global.DB).UpdateProblemStatus(w.room.ID, w.room.ProblemStatus)
+func (w *teamRoomWorker) saveProblemStatus(status model.TeamRoomProblemStatusItem) {
+	item := toTeamRoomProblemStatusModel(w.room.ID, status)
+	if err := repo.NewTeamRoomProblemStatusRepo(global.DB).Save(&item); err != nil {
+		zlog.Warnf("团队房间题目情况保存失败：%v", err)
+	}
 }
 
 func (w *teamRoomWorker) finish(allSolved bool) {
-	if w.room.Status != 0 {
+	if w.room.Status == 1 {
 		return
 	}
 	score := int64(0)
 	solvedCount := 0
-	for _, item := range w.statusList {
-		if !item.Solved {
+	for _, item := range w.room.ProblemStatus {
+		if !item.Solved || item.TeamID != 0 {
 			continue
 		}
 		score += item.SolvedAt + int64(item.Penalty*60)
 		solvedCount++
 	}
+	// 封榜中结束时先记下封榜榜单，结束后按滚榜顺序揭晓
+	var frozenBoard []types.TeamRoomScoreboardItem
+	if isTeamRoomFrozen(w.room) {
+		frozenBoard = buildTeamRoomScoreboard(maskFrozenTeamRoom(w.room, 0))
+	}
 	extra := parseTeamRoomExtra(w.room.ExtraInfo)
 	extra.Score = score
+	extra.AllSolved = allSolved
+	if extra.Competitive {
+		extra.TeamResults = buildTeamRoomScoreboard(w.room)
+	}
 	if extra.DurationSeconds == 0 {
 		extra.DurationSeconds = int64(w.duration.Seconds())
 	}
 	extraBytes, _ := json.Marshal(extra)
 	w.room.ExtraInfo = string(extraBytes)
+	started := w.room.Status == 0
 	w.room.Status = 1
 	w.room.EndTime = time.Now().Unix()
-	w.flushSubmissions()
-	w.flushProblemStatus()
 	_ = repo.NewTeamRoomRepo(global.DB).UpdateExtraInfo(w.room.ID, w.room.ExtraInfo)
 	_ = repo.NewTeamRoomRepo(global.DB).UpdateStatus(w.room.ID, w.room.Status, w.room.EndTime)
+	if frozenBoard != nil {
+		GetWsHub().SendToRoom(w.room.ID, types.WsResponse{
+			Type:    "team_room_reveal",
+			Code:    response.SUCCESS.Code,
+			Message: response.SUCCESS.Msg,
+			Data: types.TeamRoomRevealData{
+				RoomID: w.room.ID,
+				Frozen: frozenBoard,
+				Steps:  buildTeamRoomRevealSteps(frozenBoard, extra.TeamResults, w.penalty),
+				Final:  extra.TeamResults,
+			},
+		})
+	}
+	playerStats := buildTeamRoomPlayerStats(w.room)
+	recordTeamRoomEvent(w.room.ID, teamRoomEventFinish, 0, 0, map[string]interface{}{
+		"all_solved":   allSolved,
+		"solved_count": solvedCount,
+		"teams":        extra.TeamResults,
+	})
 	GetWsHub().SendToRoom(w.room.ID, types.WsResponse{
 		Type:    "team_room_finish",
 		Code:    response.SUCCESS.Code,
 		Message: response.SUCCESS.Msg,
-		Data: map[string]interface{}{
-			"room":         buildTeamRoomInfo(w.room),
-			"all_solved":   allSolved,
-			"solved_count": solvedCount,
+		Data: types.TeamRoomFinishData{
+			Room:        buildTeamRoomInfo(w.room),
+			AllSolved:   allSolved,
+			SolvedCount: solvedCount,
+			Teams:       extra.TeamResults,
+			Players:     playerStats,
 		},
 	})
+	w.checkAchievements()
+	winners := getTeamRoomWinners(w.room)
+	addTeamWins(winners)
+	// 等待阶段就结束的房间不计入生涯统计
+	if started {
+		accumulateUserTeamStats(w.room, playerStats, winners)
+	}
 	w.manager.StopRoom(w.room.ID)
 }
 
+func (w *teamRoomWorker) checkAchievements() {
+	var firstBlood model.TeamRoomProblemStatusItem
+	maxDifficulty := make(map[int64]int)
+	unsolvedTeams := make(map[int]struct{})
+	for _, item := range w.room.ProblemStatus {
+		if !item.Solved {
+			unsolvedTeams[item.TeamID] = struct{}{}
+		}
+	}
+	extra := parseTeamRoomExtra(w.room.ExtraInfo)
+	teamSolvedAll := func(teamID int) bool {
+		if extra.Competitive && teamID == 0 {
+			return false
+		}
+		_, ok := unsolvedTeams[teamID]
+		return !ok && len(w.room.ProblemStatus) > 0
+	}
+	for _, item := range w.room.ProblemStatus {
+		if !item.Solved {
+			continue
+		}
+		if firstBlood.SolvedBy == 0 || item.SolvedAt < firstBlood.SolvedAt {
+			firstBlood = item
+		}
+		if difficulty := w.problems[item.ProblemID].Difficulty; difficulty > maxDifficulty[item.SolvedBy] {
+			maxDifficulty[item.SolvedBy] = difficulty
+		}
+	}
+	for _, player := range w.room.Players {
+		checkAchievements(achievementEvent{
+			UserID:     player.UserID,
+			RoomID:     w.room.ID,
+			Solved:     maxDifficulty[player.UserID] > 0,
+			Difficulty: maxDifficulty[player.UserID],
+			FirstBlood: firstBlood.SolvedBy != 0 && firstBlood.SolvedBy == player.UserID,
+			AllSolved:  teamSolvedAll(player.TeamID),
+		})
+	}
+}
+
 func StartAllActiveTeamRooms() error {
 	roomRepo := repo.NewTeamRoomRepo(global.DB)
 	rooms, err := roomRepo.ListActive()
@@ -288,6 +524,10 @@ func StartAllActiveTeamRooms() error {
 	if len(rooms) == 0 {
 		return nil
 	}
+	if err := loadTeamRoomData(rooms); err != nil {
+		zlog.Errorf("初始化团队房间失败：%v", err)
+		return err
+	}
 	for _, room := range rooms {
 		GetTeamRoomManager().StartRoom(room)
 	}
@@ -303,11 +543,11 @@ func FinishAllActiveTeamRooms(ctx context.Context) {
 	}
 	manager := GetTeamRoomManager()
 	for _, room := range rooms {
-		manager.mu.Lock()
-		worker := manager.workers[room.ID]
-		manager.mu.Unlock()
+		worker := manager.getWorker(room.ID)
 		if worker != nil {
+			worker.mu.Lock()
 			worker.finish(false)
+			worker.mu.Unlock()
 			continue
 		}
 		room.Status = 1
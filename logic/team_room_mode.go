@@ -0,0 +1,329 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"tgwp/global"
+	"tgwp/model"
+	"tgwp/repo"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	teamRoomDefaultDuration = 5 * time.Hour
+	teamRoomCustomMode      = "custom"
+
+	teamRoomModeMaxProblems   = 26
+	teamRoomModeMinDifficulty = 800
+	teamRoomModeMaxDifficulty = 3500
+	teamRoomModeMinDuration   = 10 * time.Minute
+	teamRoomModeMaxDuration   = 12 * time.Hour
+	teamRoomModeMaxPenalty    = 120
+	teamRoomModeMaxTags       = 10
+	teamRoomModeTagMaxLen     = 32
+	// teamRoomProblemRange 每道题按目标难度上下浮动选题
+	teamRoomProblemRange = 100
+)
+
+var defaultTeamRoomModes = []teamRoomModeConfig{
+	{
+		Mode:            "div3",
+		Description:     "Div.3 难度，9题2小时",
+		Duration:        2 * time.Hour,
+		Problems:        []int{800, 800, 900, 1000, 1200, 1400, 1600, 1800, 2000},
+		PenaltyPerWrong: teamRoomPenaltyPerWrong,
+		Sort:            10,
+	},
+	{
+		Mode:            "div3-plus",
+		Description:     "Div.3 加长版，15题2.5小时",
+		Duration:        2*time.Hour + 30*time.Minute,
+		Problems:        []int{800, 800, 900, 900, 1000, 1100, 1100, 1200, 1300, 1400, 1500, 1600, 1800, 1900, 2100},
+		PenaltyPerWrong: teamRoomPenaltyPerWrong,
+		Sort:            20,
+	},
+}
+
+type teamRoomModeConfig struct {
+	Mode            string
+	Description     string
+	Duration        time.Duration
+	Problems        []int
+	Tags            []string
+	PenaltyPerWrong int
+	FreezeMinutes   int
+	Sort            int
+}
+
+// InitTeamRoomModes 写入默认模式，已存在的模式以数据库为准
+func InitTeamRoomModes() error {
+	items := make([]model.TeamRoomMode, 0, len(defaultTeamRoomModes))
+	for _, config := range defaultTeamRoomModes {
+		items = append(items, buildTeamRoomModeModel(config))
+	}
+	return repo.NewTeamRoomModeRepo(global.DB).CreateIfAbsent(items)
+}
+
+func (l *TeamRoomLogic) CreateMode(ctx context.Context, req types.TeamRoomModeReq) (resp types.TeamRoomModeInfo, err error) {
+	_ = ctx
+	config, err := buildTeamRoomModeConfig(req)
+	if err != nil {
+		return resp, err
+	}
+	if config.Mode == teamRoomCustomMode {
+		return resp, response.ErrResp(errors.New("mode reserved"), response.PARAM_NOT_VALID)
+	}
+	modeRepo := repo.NewTeamRoomModeRepo(global.DB)
+	if _, err := modeRepo.GetByMode(config.Mode); err == nil {
+		return resp, response.ErrResp(errors.New("mode exists"), response.PARAM_NOT_VALID)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if err := validateTeamRoomModeConfig(config); err != nil {
+		return resp, err
+	}
+	item := buildTeamRoomModeModel(config)
+	if err := modeRepo.Create(&item); err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	return buildTeamRoomModeInfo(config), nil
+}
+
+func (l *TeamRoomLogic) UpdateMode(ctx context.Context, req types.TeamRoomModeReq) (resp types.TeamRoomModeInfo, err error) {
+	_ = ctx
+	config, err := buildTeamRoomModeConfig(req)
+	if err != nil {
+		return resp, err
+	}
+	modeRepo := repo.NewTeamRoomModeRepo(global.DB)
+	if _, err := modeRepo.GetByMode(config.Mode); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return resp, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
+		}
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if err := validateTeamRoomModeConfig(config); err != nil {
+		return resp, err
+	}
+	if err := modeRepo.Update(buildTeamRoomModeModel(config)); err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	return buildTeamRoomModeInfo(config), nil
+}
+
+func (l *TeamRoomLogic) DeleteMode(ctx context.Context, req types.TeamRoomModeDeleteReq) (resp types.TeamRoomModeDeleteResp, err error) {
+	_ = ctx
+	if req.Mode == "" {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	deleted, err := repo.NewTeamRoomModeRepo(global.DB).Delete(req.Mode)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if !deleted {
+		return resp, response.ErrResp(errors.New("mode not exist"), response.MESSAGE_NOT_EXIST)
+	}
+	resp.Mode = req.Mode
+	return resp, nil
+}
+
+// resolveTeamRoomModeConfig 传入自定义配置时使用 custom 模式，否则读取数据库中的模式
+func resolveTeamRoomModeConfig(req types.TeamRoomCreateReq) (teamRoomModeConfig, error) {
+	if req.Custom != nil {
+		config, err := buildTeamRoomModeConfig(types.TeamRoomModeReq{
+			Mode:            teamRoomCustomMode,
+			Duration:        req.Custom.Duration,
+			Difficulties:    req.Custom.Difficulties,
+			Tags:            req.Custom.Tags,
+			PenaltyPerWrong: req.Custom.PenaltyPerWrong,
+			FreezeMinutes:   req.Custom.FreezeMinutes,
+		})
+		if err != nil {
+			return config, err
+		}
+		return config, validateTeamRoomModeConfig(config)
+	}
+	if req.Mode == "" {
+		return teamRoomModeConfig{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	return getTeamRoomModeConfig(req.Mode)
+}
+
+func getTeamRoomModeConfig(mode string) (teamRoomModeConfig, error) {
+	item, err := repo.NewTeamRoomModeRepo(global.DB).GetByMode(mode)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return teamRoomModeConfig{}, response.ErrResp(errors.New("mode not exist"), response.PARAM_NOT_VALID)
+		}
+		return teamRoomModeConfig{}, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	return parseTeamRoomModeModel(item), nil
+}
+
+// getTeamRoomDuration 兼容未在房间中记录时长的旧数据
+func getTeamRoomDuration(mode string) time.Duration {
+	item, err := repo.NewTeamRoomModeRepo(global.DB).GetByMode(mode)
+	if err == nil && item.DurationSeconds > 0 {
+		return time.Duration(item.DurationSeconds) * time.Second
+	}
+	return teamRoomDefaultDuration
+}
+
+func buildTeamRoomModeConfig(req types.TeamRoomModeReq) (teamRoomModeConfig, error) {
+	mode := strings.TrimSpace(req.Mode)
+	if mode == "" || len(req.Difficulties) == 0 {
+		return teamRoomModeConfig{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	config := teamRoomModeConfig{
+		Mode:            mode,
+		Description:     strings.TrimSpace(req.Description),
+		Duration:        time.Duration(req.Duration) * time.Second,
+		Problems:        req.Difficulties,
+		PenaltyPerWrong: teamRoomPenaltyPerWrong,
+		FreezeMinutes:   req.FreezeMinutes,
+		Sort:            req.Sort,
+	}
+	if req.PenaltyPerWrong != nil {
+		config.PenaltyPerWrong = *req.PenaltyPerWrong
+	}
+	seen := make(map[string]struct{}, len(req.Tags))
+	for _, tag := range req.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		config.Tags = append(config.Tags, tag)
+	}
+	return config, nil
+}
+
+// validateTeamRoomModeConfig 校验配置范围，并确认题库中每个难度区间有足够的题目
+func validateTeamRoomModeConfig(config teamRoomModeConfig) error {
+	if err := validateTeamRoomModeLimits(config); err != nil {
+		return err
+	}
+	required := make(map[int]int64, len(config.Problems))
+	for _, difficulty := range config.Problems {
+		if difficulty < teamRoomModeMinDifficulty || difficulty > teamRoomModeMaxDifficulty {
+			return response.ErrResp(errors.New("difficulty invalid"), response.PARAM_NOT_VALID)
+		}
+		required[difficulty]++
+	}
+	problemRepo := repo.NewCodeforcesProblemRepo(global.DB)
+	for difficulty, need := range required {
+		count, err := problemRepo.CountByDifficultyAndTags(difficulty-teamRoomProblemRange, difficulty+teamRoomProblemRange, config.Tags)
+		if err != nil {
+			return response.ErrResp(err, response.DATABASE_ERROR)
+		}
+		if count < need {
+			return response.ErrResp(errors.New("problem pool not enough"), response.PARAM_NOT_VALID)
+		}
+	}
+	return nil
+}
+
+func validateTeamRoomModeLimits(config teamRoomModeConfig) error {
+	if utf8.RuneCountInString(config.Mode) > 32 || utf8.RuneCountInString(config.Description) > 255 {
+		return response.ErrResp(errors.New("mode too long"), response.PARAM_NOT_VALID)
+	}
+	if len(config.Problems) > teamRoomModeMaxProblems {
+		return response.ErrResp(errors.New("too many problems"), response.PARAM_NOT_VALID)
+	}
+	if config.Duration < teamRoomModeMinDuration || config.Duration > teamRoomModeMaxDuration {
+		return response.ErrResp(errors.New("duration invalid"), response.PARAM_NOT_VALID)
+	}
+	if config.PenaltyPerWrong < 0 || config.PenaltyPerWrong > teamRoomModeMaxPenalty {
+		return response.ErrResp(errors.New("penalty invalid"), response.PARAM_NOT_VALID)
+	}
+	if config.FreezeMinutes < 0 || time.Duration(config.FreezeMinutes)*time.Minute >= config.Duration {
+		return response.ErrResp(errors.New("freeze minutes invalid"), response.PARAM_NOT_VALID)
+	}
+	if len(config.Tags) > teamRoomModeMaxTags {
+		return response.ErrResp(errors.New("too many tags"), response.PARAM_NOT_VALID)
+	}
+	for _, tag := range config.Tags {
+		if len(tag) > teamRoomModeTagMaxLen || strings.Contains(tag, ",") {
+			return response.ErrResp(errors.New("tag invalid"), response.PARAM_NOT_VALID)
+		}
+	}
+	return nil
+}
+
+func buildTeamRoomModeModel(config teamRoomModeConfig) model.TeamRoomMode {
+	difficulties, _ := json.Marshal(config.Problems)
+	tags := config.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	tagBytes, _ := json.Marshal(tags)
+	return model.TeamRoomMode{
+		Mode:            config.Mode,
+		Description:     config.Description,
+		DurationSeconds: int64(config.Duration.Seconds()),
+		Difficulties:    string(difficulties),
+		Tags:            string(tagBytes),
+		PenaltyPerWrong: config.PenaltyPerWrong,
+		FreezeMinutes:   config.FreezeMinutes,
+		Sort:            config.Sort,
+	}
+}
+
+func parseTeamRoomModeModel(item model.TeamRoomMode) teamRoomModeConfig {
+	config := teamRoomModeConfig{
+		Mode:            item.Mode,
+		Description:     item.Description,
+		Duration:        time.Duration(item.DurationSeconds) * time.Second,
+		PenaltyPerWrong: item.PenaltyPerWrong,
+		FreezeMinutes:   item.FreezeMinutes,
+		Sort:            item.Sort,
+	}
+	if config.Duration <= 0 {
+		config.Duration = teamRoomDefaultDuration
+	}
+	if item.Difficulties != "" {
+		_ = json.Unmarshal([]byte(item.Difficulties), &config.Problems)
+	}
+	if item.Tags != "" {
+		_ = json.Unmarshal([]byte(item.Tags), &config.Tags)
+	}
+	return config
+}
+
+func buildTeamRoomModeInfo(config teamRoomModeConfig) types.TeamRoomModeInfo {
+	problems := make([]int, len(config.Problems))
+	copy(problems, config.Problems)
+	return types.TeamRoomModeInfo{
+		Mode:            config.Mode,
+		Description:     config.Description,
+		Duration:        int64(config.Duration.Seconds()),
+		Problems:        problems,
+		Tags:            config.Tags,
+		PenaltyPerWrong: config.PenaltyPerWrong,
+		FreezeMinutes:   config.FreezeMinutes,
+		Sort:            config.Sort,
+	}
+}
+
+func buildTeamRoomModeInfos() ([]types.TeamRoomModeInfo, error) {
+	items, err := repo.NewTeamRoomModeRepo(global.DB).List()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]types.TeamRoomModeInfo, 0, len(items))
+	for _, item := range items {
+		infos = append(infos, buildTeamRoomModeInfo(parseTeamRoomModeModel(item)))
+	}
+	return infos, nil
+}
@@ -0,0 +1,316 @@
+package logic
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+
+	"tgwp/global"
+	"tgwp/model"
+	"tgwp/repo"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	teamRoomMaxPlayers        = 64
+	teamRoomInviteCodeLen     = 8
+	teamRoomInviteCodeCharset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	teamRoomInviteTokenExpire = 7 * 24 * time.Hour
+)
+
+func (l *TeamRoomLogic) GetInvite(ctx context.Context, req types.TeamRoomInviteReq) (resp types.TeamRoomInviteInfo, err error) {
+	_ = ctx
+	roomID, err := parseTeamRoomID(req.RoomID)
+	if err != nil || req.UserID == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	room, err := getTeamRoom(roomID)
+	if err != nil {
+		return resp, err
+	}
+	if err := checkTeamRoomCreator(room, req.UserID); err != nil {
+		return resp, err
+	}
+	extra := parseTeamRoomExtra(room.ExtraInfo)
+	if !extra.Private {
+		return resp, response.ErrResp(errors.New("room not private"), response.PARAM_NOT_VALID)
+	}
+	return buildTeamRoomInvite(room.ID, extra.InviteCode)
+}
+
+func (l *TeamRoomLogic) KickPlayer(ctx context.Context, userID int64, roomID int64, targetID int64) (types.TeamRoomInfo, error) {
+	_ = ctx
+	if userID == 0 || roomID == 0 || targetID == 0 {
+		return types.TeamRoomInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if userID == targetID {
+		return types.TeamRoomInfo{}, response.ErrResp(errors.New("cannot kick yourself"), response.PARAM_NOT_VALID)
+	}
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomInfo](teamRoomCommand{Op: teamRoomCmdKick, RoomID: roomID, UserID: userID, TargetID: targetID}); routed || err != nil {
+		return resp, err
+	}
+	teamID := 0
+	room, err := updateTeamRoom(roomID, func(room *model.TeamRoom) error {
+		if room.Status == 1 {
+			return response.ErrResp(errors.New("room finished"), response.PARAM_NOT_VALID)
+		}
+		if err := checkTeamRoomCreator(*room, userID); err != nil {
+			return err
+		}
+		players := slices.Clone(room.Players)
+		index := findTeamRoomPlayer(players, targetID)
+		if index < 0 {
+			return response.ErrResp(errors.New("player not in room"), response.MESSAGE_NOT_EXIST)
+		}
+		teamID = players[index].TeamID
+		players = append(players[:index], players[index+1:]...)
+		extra := parseTeamRoomExtra(room.ExtraInfo)
+		extra.Kicked = append(extra.Kicked, targetID)
+		if err := saveTeamRoomExtra(room, extra); err != nil {
+			return err
+		}
+		return saveTeamRoomPlayers(room, players)
+	})
+	if err != nil {
+		return types.TeamRoomInfo{}, err
+	}
+	getTeamRoomPresence().remove(roomID, targetID)
+	releaseTeamRoomUserClaims(roomID, targetID)
+	recordTeamRoomEvent(roomID, teamRoomEventLeave, targetID, teamID, map[string]interface{}{
+		"reason":      "kick",
+		"operator_id": userID,
+	})
+	return buildTeamRoomInfo(room), nil
+}
+
+func (l *TeamRoomLogic) TransferOwner(ctx context.Context, userID int64, roomID int64, targetID int64) (types.TeamRoomInfo, error) {
+	_ = ctx
+	if userID == 0 || roomID == 0 || targetID == 0 {
+		return types.TeamRoomInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomInfo](teamRoomCommand{Op: teamRoomCmdTransfer, RoomID: roomID, UserID: userID, TargetID: targetID}); routed || err != nil {
+		return resp, err
+	}
+	room, err := updateTeamRoom(roomID, func(room *model.TeamRoom) error {
+		if room.Status == 1 {
+			return response.ErrResp(errors.New("room finished"), response.PARAM_NOT_VALID)
+		}
+		if err := checkTeamRoomCreator(*room, userID); err != nil {
+			return err
+		}
+		if findTeamRoomPlayer(room.Players, targetID) < 0 {
+			return response.ErrResp(errors.New("player not in room"), response.MESSAGE_NOT_EXIST)
+		}
+		if err := repo.NewTeamRoomRepo(global.DB).UpdateCreator(room.ID, targetID); err != nil {
+			return response.ErrResp(err, response.DATABASE_ERROR)
+		}
+		room.CreatorID = targetID
+		return nil
+	})
+	if err != nil {
+		return types.TeamRoomInfo{}, err
+	}
+	return buildTeamRoomInfo(room), nil
+}
+
+func (l *TeamRoomLogic) LockRoom(ctx context.Context, userID int64, roomID int64, locked bool) (types.TeamRoomInfo, error) {
+	_ = ctx
+	if userID == 0 || roomID == 0 {
+		return types.TeamRoomInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomInfo](teamRoomCommand{Op: teamRoomCmdLock, RoomID: roomID, UserID: userID, Flag: locked}); routed || err != nil {
+		return resp, err
+	}
+	room, err := updateTeamRoom(roomID, func(room *model.TeamRoom) error {
+		if room.Status == 1 {
+			return response.ErrResp(errors.New("room finished"), response.PARAM_NOT_VALID)
+		}
+		if err := checkTeamRoomCreator(*room, userID); err != nil {
+			return err
+		}
+		extra := parseTeamRoomExtra(room.ExtraInfo)
+		if extra.Locked == locked {
+			return nil
+		}
+		extra.Locked = locked
+		return saveTeamRoomExtra(room, extra)
+	})
+	if err != nil {
+		return types.TeamRoomInfo{}, err
+	}
+	return buildTeamRoomInfo(room), nil
+}
+
+// EndRoom 房主提前结束比赛，按超时结算
+func (l *TeamRoomLogic) EndRoom(ctx context.Context, userID int64, roomID int64) (types.TeamRoomInfo, error) {
+	_ = ctx
+	if userID == 0 || roomID == 0 {
+		return types.TeamRoomInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomInfo](teamRoomCommand{Op: teamRoomCmdEnd, RoomID: roomID, UserID: userID}); routed || err != nil {
+		return resp, err
+	}
+	worker := GetTeamRoomManager().getWorker(roomID)
+	if worker == nil {
+		room, err := getTeamRoom(roomID)
+		if err != nil {
+			return types.TeamRoomInfo{}, err
+		}
+		if err := checkTeamRoomCreator(room, userID); err != nil {
+			return types.TeamRoomInfo{}, err
+		}
+		return types.TeamRoomInfo{}, response.ErrResp(errors.New("room finished"), response.PARAM_NOT_VALID)
+	}
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+	if err := checkTeamRoomCreator(worker.room, userID); err != nil {
+		return types.TeamRoomInfo{}, err
+	}
+	if worker.room.Status == 1 {
+		return types.TeamRoomInfo{}, response.ErrResp(errors.New("room finished"), response.PARAM_NOT_VALID)
+	}
+	worker.finish(false)
+	return buildTeamRoomInfo(worker.room), nil
+}
+
+// checkTeamRoomJoinable 已在房间的成员和房主可直接进入，其他人需满足踢出、锁定、人数与邀请限制
+func checkTeamRoomJoinable(room model.TeamRoom, players []model.TeamRoomPlayerItem, userID int64, invite types.TeamRoomInviteCredential) error {
+	if findTeamRoomPlayer(players, userID) >= 0 || room.CreatorID == userID {
+		return nil
+	}
+	extra := parseTeamRoomExtra(room.ExtraInfo)
+	for _, id := range extra.Kicked {
+		if id == userID {
+			return response.ErrResp(errors.New("kicked from room"), response.PERMISSION_DENIED)
+		}
+	}
+	if extra.Locked {
+		return response.ErrResp(errors.New("room locked"), response.PERMISSION_DENIED)
+	}
+	if extra.MaxPlayers > 0 && len(players) >= extra.MaxPlayers {
+		return response.ErrResp(errors.New("room full"), response.PARAM_NOT_VALID)
+	}
+	if extra.Private && !checkTeamRoomInvite(room.ID, extra.InviteCode, invite) {
+		return response.ErrResp(errors.New("invite required"), response.PERMISSION_DENIED)
+	}
+	return nil
+}
+
+// checkTeamRoomAccess 私密房间及被踢出的玩家只有成员身份才能收发房间消息
+func checkTeamRoomAccess(roomID int64, userID int64) error {
+	room, err := getTeamRoom(roomID)
+	if err != nil {
+		return err
+	}
+	if findTeamRoomPlayer(room.Players, userID) >= 0 || room.CreatorID == userID {
+		return nil
+	}
+	extra := parseTeamRoomExtra(room.ExtraInfo)
+	if extra.Private {
+		return response.ErrResp(errors.New("not in room"), response.PERMISSION_DENIED)
+	}
+	for _, id := range extra.Kicked {
+		if id == userID {
+			return response.ErrResp(errors.New("kicked from room"), response.PERMISSION_DENIED)
+		}
+	}
+	return nil
+}
+
+// checkTeamRoomPrivateAccess 私有房间的回放和导出只允许成员、房主查看
+func checkTeamRoomPrivateAccess(room model.TeamRoom, userID int64) error {
+	if !parseTeamRoomExtra(room.ExtraInfo).Private {
+		return nil
+	}
+	return checkTeamRoomAccess(room.ID, userID)
+}
+
+func checkTeamRoomCreator(room model.TeamRoom, userID int64) error {
+	if room.CreatorID != userID {
+		return response.ErrResp(errors.New("not room creator"), response.PERMISSION_DENIED)
+	}
+	return nil
+}
+
+// getTeamRoom 运行中的房间读取 worker 持有的状态，否则读取数据库
+func getTeamRoom(roomID int64) (model.TeamRoom, error) {
+	if worker := GetTeamRoomManager().getWorker(roomID); worker != nil {
+		worker.mu.Lock()
+		defer worker.mu.Unlock()
+		return worker.room, nil
+	}
+	room, err := getStoredTeamRoom(roomID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return room, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
+		}
+		return room, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	return room, nil
+}
+
+func newTeamRoomInviteCode() (string, error) {
+	buf := make([]byte, teamRoomInviteCodeLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i := range buf {
+		buf[i] = teamRoomInviteCodeCharset[int(buf[i])%len(teamRoomInviteCodeCharset)]
+	}
+	return string(buf), nil
+}
+
+// buildTeamRoomInvite 邀请链接token中带上房间ID和邀请码，使用JWT密钥签名
+func buildTeamRoomInvite(roomID int64, code string) (types.TeamRoomInviteInfo, error) {
+	expireAt := time.Now().Add(teamRoomInviteTokenExpire).Unix()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"room_id":     strconv.FormatInt(roomID, 10),
+		"invite_code": code,
+		"exp":         expireAt,
+	})
+	tokenString, err := token.SignedString([]byte(global.Config.JWT.Secret))
+	if err != nil {
+		return types.TeamRoomInviteInfo{}, response.ErrResp(err, response.COMMON_FAIL)
+	}
+	return types.TeamRoomInviteInfo{
+		RoomID:      roomID,
+		InviteCode:  code,
+		InviteToken: tokenString,
+		ExpireAt:    expireAt,
+	}, nil
+}
+
+func checkTeamRoomInvite(roomID int64, code string, invite types.TeamRoomInviteCredential) bool {
+	if code == "" {
+		return false
+	}
+	if invite.InviteCode != "" {
+		return invite.InviteCode == code
+	}
+	if invite.InviteToken == "" {
+		return false
+	}
+	token, err := jwt.Parse(invite.InviteToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("不支持的签名方法")
+		}
+		return []byte(global.Config.JWT.Secret), nil
+	})
+	if err != nil || !token.Valid {
+		return false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	tokenRoomID, _ := claims["room_id"].(string)
+	tokenCode, _ := claims["invite_code"].(string)
+	return tokenRoomID == strconv.FormatInt(roomID, 10) && tokenCode == code
+}
@@ -0,0 +1,204 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tgwp/global"
+	"tgwp/model"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+// PauseRoom 暂停计时，暂停期间的提交不计入
+func (l *TeamRoomLogic) PauseRoom(ctx context.Context, userID int64, role int, roomID int64) (types.TeamRoomTimerInfo, error) {
+	_ = ctx
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomTimerInfo](teamRoomCommand{Op: teamRoomCmdPause, RoomID: roomID, UserID: userID, Role: role}); routed || err != nil {
+		return resp, err
+	}
+	return controlTeamRoomClock(userID, role, roomID, func(extra *teamRoomExtraInfo, now time.Time) error {
+		if extra.PausedAt > 0 {
+			return response.ErrResp(errors.New("room paused"), response.PARAM_NOT_VALID)
+		}
+		extra.PausedAt = now.Unix()
+		return nil
+	})
+}
+
+func (l *TeamRoomLogic) ResumeRoom(ctx context.Context, userID int64, role int, roomID int64) (types.TeamRoomTimerInfo, error) {
+	_ = ctx
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomTimerInfo](teamRoomCommand{Op: teamRoomCmdResume, RoomID: roomID, UserID: userID, Role: role}); routed || err != nil {
+		return resp, err
+	}
+	return controlTeamRoomClock(userID, role, roomID, func(extra *teamRoomExtraInfo, now time.Time) error {
+		if extra.PausedAt == 0 {
+			return response.ErrResp(errors.New("room not paused"), response.PARAM_NOT_VALID)
+		}
+		extra.PausedSeconds += now.Unix() - extra.PausedAt
+		extra.PauseIntervals = append(extra.PauseIntervals, teamRoomPauseInterval{Start: extra.PausedAt, End: now.Unix()})
+		extra.PausedAt = 0
+		return nil
+	})
+}
+
+// ExtendRoom 延长比赛时长，延长后的总时长不超过模式允许的最大时长
+func (l *TeamRoomLogic) ExtendRoom(ctx context.Context, userID int64, role int, roomID int64, seconds int64) (types.TeamRoomTimerInfo, error) {
+	_ = ctx
+	if seconds <= 0 {
+		return types.TeamRoomTimerInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomTimerInfo](teamRoomCommand{Op: teamRoomCmdExtend, RoomID: roomID, UserID: userID, Role: role, Seconds: seconds}); routed || err != nil {
+		return resp, err
+	}
+	return controlTeamRoomClock(userID, role, roomID, func(extra *teamRoomExtraInfo, now time.Time) error {
+		duration := time.Duration(extra.DurationSeconds+seconds) * time.Second
+		if duration > teamRoomModeMaxDuration {
+			return response.ErrResp(errors.New("duration too long"), response.PARAM_NOT_VALID)
+		}
+		extra.DurationSeconds += seconds
+		return nil
+	})
+}
+
+// controlTeamRoomClock 房主或管理员修改运行中房间的计时，修改后重置 worker 的计时器并广播 team_room_timer
+func controlTeamRoomClock(userID int64, role int, roomID int64, fn func(extra *teamRoomExtraInfo, now time.Time) error) (types.TeamRoomTimerInfo, error) {
+	if userID == 0 || roomID == 0 {
+		return types.TeamRoomTimerInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	worker := GetTeamRoomManager().getWorker(roomID)
+	if worker == nil {
+		if _, err := getTeamRoom(roomID); err != nil {
+			return types.TeamRoomTimerInfo{}, err
+		}
+		return types.TeamRoomTimerInfo{}, response.ErrResp(errors.New("room finished"), response.PARAM_NOT_VALID)
+	}
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+	if err := checkTeamRoomOperator(worker.room, userID, role); err != nil {
+		return types.TeamRoomTimerInfo{}, err
+	}
+	if worker.room.Status != 0 {
+		return types.TeamRoomTimerInfo{}, response.ErrResp(errors.New("room not running"), response.PARAM_NOT_VALID)
+	}
+	now := time.Now()
+	extra := parseTeamRoomExtra(worker.room.ExtraInfo)
+	if err := fn(&extra, now); err != nil {
+		return types.TeamRoomTimerInfo{}, err
+	}
+	if err := saveTeamRoomExtra(&worker.room, extra); err != nil {
+		return types.TeamRoomTimerInfo{}, err
+	}
+	worker.duration = time.Duration(extra.DurationSeconds) * time.Second
+	worker.resetClock()
+	info := buildTeamRoomTimerInfo(worker.room, now)
+	GetWsHub().SendToRoom(roomID, types.WsResponse{
+		Type:    "team_room_timer",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data:    info,
+	})
+	return info, nil
+}
+
+// checkTeamRoomOperator 管理员可以操作任意房间
+func checkTeamRoomOperator(room model.TeamRoom, userID int64, role int) error {
+	if role == global.ROLE_ADMIN {
+		return nil
+	}
+	return checkTeamRoomCreator(room, userID)
+}
+
+// teamRoomPauseInterval 已结束的暂停区间，左闭右开
+type teamRoomPauseInterval struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// isTeamRoomPausedAt 判断某一时刻是否处于暂停中，用于按提交时间排除暂停期间的提交
+func isTeamRoomPausedAt(extra teamRoomExtraInfo, at int64) bool {
+	if extra.PausedAt > 0 && at >= extra.PausedAt {
+		return true
+	}
+	for _, interval := range extra.PauseIntervals {
+		if at >= interval.Start && at < interval.End {
+			return true
+		}
+	}
+	return false
+}
+
+// getTeamRoomPausedDuration 累计暂停时间，暂停中时包含本次暂停已经过的时间
+func getTeamRoomPausedDuration(extra teamRoomExtraInfo, now time.Time) time.Duration {
+	paused := time.Duration(extra.PausedSeconds) * time.Second
+	if extra.PausedAt > 0 {
+		paused += now.Sub(time.Unix(extra.PausedAt, 0))
+	}
+	return paused
+}
+
+func getTeamRoomStartTime(room model.TeamRoom) time.Time {
+	if room.StartTime == 0 {
+		return room.CreatedAt
+	}
+	return time.Unix(room.StartTime, 0)
+}
+
+// getTeamRoomEndAt 结束时间随暂停顺延，暂停中会随时间推后
+func getTeamRoomEndAt(room model.TeamRoom, extra teamRoomExtraInfo, now time.Time) time.Time {
+	duration := time.Duration(extra.DurationSeconds) * time.Second
+	return getTeamRoomStartTime(room).Add(duration + getTeamRoomPausedDuration(extra, now))
+}
+
+// getTeamRoomViewEndAt 只有进行中的房间才有预计结束时间
+func getTeamRoomViewEndAt(room model.TeamRoom, extra teamRoomExtraInfo) int64 {
+	if room.Status != 0 {
+		return 0
+	}
+	return getTeamRoomEndAt(room, extra, time.Now()).Unix()
+}
+
+func buildTeamRoomTimerInfo(room model.TeamRoom, now time.Time) types.TeamRoomTimerInfo {
+	extra := parseTeamRoomExtra(room.ExtraInfo)
+	endAt := getTeamRoomEndAt(room, extra, now)
+	remaining := endAt.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return types.TeamRoomTimerInfo{
+		RoomID:        room.ID,
+		Paused:        extra.PausedAt > 0,
+		PausedAt:      extra.PausedAt,
+		PausedSeconds: int64(getTeamRoomPausedDuration(extra, now).Seconds()),
+		Duration:      extra.DurationSeconds,
+		StartTime:     getTeamRoomStartTime(room).Unix(),
+		EndAt:         endAt.Unix(),
+		Remaining:     int64(remaining.Seconds()),
+		ServerTime:    now.Unix(),
+	}
+}
+
+// elapsed 房间已进行的时间，不含暂停时间
+func (w *teamRoomWorker) elapsed() time.Duration {
+	now := time.Now()
+	return now.Sub(w.startTime) - getTeamRoomPausedDuration(parseTeamRoomExtra(w.room.ExtraInfo), now)
+}
+
+func (w *teamRoomWorker) isPaused() bool {
+	return parseTeamRoomExtra(w.room.ExtraInfo).PausedAt > 0
+}
+
+// clockState 供 run 循环在锁外重置计时器
+func (w *teamRoomWorker) clockState() (time.Time, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	extra := parseTeamRoomExtra(w.room.ExtraInfo)
+	return w.startTime.Add(w.duration + getTeamRoomPausedDuration(extra, time.Now())), extra.PausedAt > 0
+}
+
+func (w *teamRoomWorker) resetClock() {
+	select {
+	case w.resetCh <- struct{}{}:
+	default:
+	}
+}
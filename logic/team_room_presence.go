@@ -0,0 +1,193 @@
+package logic
+
+import (
+	"sync"
+	"time"
+
+	"tgwp/global"
+	"tgwp/log/zlog"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	teamRoomPresenceOnline        = "online"
+	teamRoomPresenceAway          = "away"
+	teamRoomPresenceOffline       = "offline"
+	teamRoomDefaultReconnectGrace = 60 * time.Second
+)
+
+// teamRoomPresenceTracker 记录玩家在线状态，与房间成员身份无关：断线后进入 away，超过重连宽限期变为 offline。
+// 状态和宽限计时只保存在持有房间租约的实例上，其他实例的连接变化通过命令转发过来
+type teamRoomPresenceTracker struct {
+	mu    sync.Mutex
+	rooms map[int64]map[int64]*teamRoomPresenceEntry
+}
+
+type teamRoomPresenceEntry struct {
+	State string
+	Since int64
+	timer *time.Timer
+}
+
+var teamRoomPresenceOnce sync.Once
+var teamRoomPresence *teamRoomPresenceTracker
+
+func getTeamRoomPresence() *teamRoomPresenceTracker {
+	teamRoomPresenceOnce.Do(func() {
+		teamRoomPresence = &teamRoomPresenceTracker{
+			rooms: make(map[int64]map[int64]*teamRoomPresenceEntry),
+		}
+	})
+	return teamRoomPresence
+}
+
+// set 状态变化时返回 true，替换状态会停止之前的宽限计时
+func (t *teamRoomPresenceTracker) set(roomID int64, userID int64, state string, timer *time.Timer) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	users, ok := t.rooms[roomID]
+	if !ok {
+		users = make(map[int64]*teamRoomPresenceEntry)
+		t.rooms[roomID] = users
+	}
+	entry, ok := users[userID]
+	if ok {
+		if entry.timer != nil {
+			entry.timer.Stop()
+		}
+		if entry.State == state {
+			entry.timer = timer
+			return false
+		}
+	}
+	users[userID] = &teamRoomPresenceEntry{State: state, Since: time.Now().Unix(), timer: timer}
+	return true
+}
+
+// expire 宽限期结束时仍处于 away 才变为 offline
+func (t *teamRoomPresenceTracker) expire(roomID int64, userID int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.rooms[roomID][userID]
+	if !ok || entry.State != teamRoomPresenceAway {
+		return false
+	}
+	t.rooms[roomID][userID] = &teamRoomPresenceEntry{State: teamRoomPresenceOffline, Since: time.Now().Unix()}
+	return true
+}
+
+func (t *teamRoomPresenceTracker) remove(roomID int64, userID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	users, ok := t.rooms[roomID]
+	if !ok {
+		return
+	}
+	if entry, ok := users[userID]; ok && entry.timer != nil {
+		entry.timer.Stop()
+	}
+	delete(users, userID)
+	if len(users) == 0 {
+		delete(t.rooms, roomID)
+	}
+}
+
+func (t *teamRoomPresenceTracker) removeRoom(roomID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, entry := range t.rooms[roomID] {
+		if entry.timer != nil {
+			entry.timer.Stop()
+		}
+	}
+	delete(t.rooms, roomID)
+}
+
+// get 没有记录时（例如房间刚被其他实例接管）按各实例的连接快照判断是否在线
+func (t *teamRoomPresenceTracker) get(roomID int64, userID int64) (string, int64) {
+	t.mu.Lock()
+	entry, ok := t.rooms[roomID][userID]
+	t.mu.Unlock()
+	if ok {
+		return entry.State, entry.Since
+	}
+	if GetWsHub().hasUserRoomConnection(userID, roomID) {
+		return teamRoomPresenceOnline, 0
+	}
+	return teamRoomPresenceOffline, 0
+}
+
+func getTeamRoomReconnectGrace() time.Duration {
+	if global.Config != nil && global.Config.Game.ReconnectGrace > 0 {
+		return time.Duration(global.Config.Game.ReconnectGrace) * time.Second
+	}
+	return teamRoomDefaultReconnectGrace
+}
+
+func isTeamRoomMember(roomID int64, userID int64) bool {
+	room, err := getTeamRoom(roomID)
+	if err != nil {
+		return false
+	}
+	return findTeamRoomPlayer(room.Players, userID) >= 0
+}
+
+func markTeamRoomOnline(roomID int64, userID int64) {
+	if routeTeamRoomPresence(roomID, userID, teamRoomCmdOnline) {
+		return
+	}
+	if getTeamRoomPresence().set(roomID, userID, teamRoomPresenceOnline, nil) {
+		broadcastTeamRoomPresence(roomID, userID, teamRoomPresenceOnline, 0)
+	}
+}
+
+// markTeamRoomAway 玩家最后一个连接断开后调用，宽限期内重连恢复 online
+func markTeamRoomAway(roomID int64, userID int64) {
+	if routeTeamRoomPresence(roomID, userID, teamRoomCmdAway) {
+		return
+	}
+	if !isTeamRoomMember(roomID, userID) {
+		return
+	}
+	grace := getTeamRoomReconnectGrace()
+	timer := time.AfterFunc(grace, func() {
+		// 断开处理是异步的，期间重连可能已被 away 覆盖，到期时以实际连接为准
+		if GetWsHub().hasUserRoomConnection(userID, roomID) {
+			markTeamRoomOnline(roomID, userID)
+			return
+		}
+		if getTeamRoomPresence().expire(roomID, userID) {
+			broadcastTeamRoomPresence(roomID, userID, teamRoomPresenceOffline, 0)
+			releaseTeamRoomUserClaims(roomID, userID)
+		}
+	})
+	if getTeamRoomPresence().set(roomID, userID, teamRoomPresenceAway, timer) {
+		broadcastTeamRoomPresence(roomID, userID, teamRoomPresenceAway, time.Now().Add(grace).Unix())
+	}
+}
+
+// routeTeamRoomPresence 房间 worker 在其他实例上时把连接变化转发给该实例，返回 true 表示无需在本地处理
+func routeTeamRoomPresence(roomID int64, userID int64, op string) bool {
+	_, routed, err := routeTeamRoomCommand[struct{}](teamRoomCommand{Op: op, RoomID: roomID, UserID: userID})
+	if err != nil {
+		zlog.Warnf("转发团队房间在线状态失败：%v", err)
+		return true
+	}
+	return routed
+}
+
+func broadcastTeamRoomPresence(roomID int64, userID int64, state string, graceUntil int64) {
+	GetWsHub().SendToRoom(roomID, types.WsResponse{
+		Type:    "team_room_presence",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomPresenceData{
+			RoomID:     roomID,
+			UserID:     userID,
+			State:      state,
+			Ts:         time.Now().Unix(),
+			GraceUntil: graceUntil,
+		},
+	})
+}
@@ -0,0 +1,219 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"tgwp/global"
+	"tgwp/model"
+	"tgwp/repo"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	teamRoomProblemSetMode       = "problemset"
+	teamRoomProblemSetNameMaxLen = 64
+)
+
+func (l *TeamRoomLogic) CreateProblemSet(ctx context.Context, req types.TeamRoomProblemSetCreateReq) (resp types.TeamRoomProblemSetInfo, err error) {
+	_ = ctx
+	name := strings.TrimSpace(req.Name)
+	if req.UserID == 0 || name == "" || len(req.ProblemIDs) == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if utf8.RuneCountInString(name) > teamRoomProblemSetNameMaxLen || utf8.RuneCountInString(req.Description) > 255 {
+		return resp, response.ErrResp(errors.New("name too long"), response.PARAM_NOT_VALID)
+	}
+	problems, err := loadTeamRoomProblems(req.ProblemIDs)
+	if err != nil {
+		return resp, err
+	}
+	ids := make([]string, 0, len(problems))
+	for _, p := range problems {
+		ids = append(ids, p.ProblemID)
+	}
+	idBytes, _ := json.Marshal(ids)
+	item := model.TeamRoomProblemSet{
+		Name:           name,
+		Description:    strings.TrimSpace(req.Description),
+		ProblemIDs:     string(idBytes),
+		HideDifficulty: req.HideDifficulty,
+		CreatorID:      req.UserID,
+	}
+	if err := repo.NewTeamRoomProblemSetRepo(global.DB).Create(&item); err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	return buildTeamRoomProblemSetInfo(item), nil
+}
+
+func (l *TeamRoomLogic) GetProblemSet(ctx context.Context, req types.TeamRoomProblemSetReq) (resp types.TeamRoomProblemSetInfo, err error) {
+	_ = ctx
+	item, err := getTeamRoomProblemSet(req.ID, req.UserID)
+	if err != nil {
+		return resp, err
+	}
+	return buildTeamRoomProblemSetInfo(item), nil
+}
+
+func (l *TeamRoomLogic) ListProblemSets(ctx context.Context, req types.TeamRoomProblemSetListReq) (resp types.TeamRoomProblemSetListResp, err error) {
+	_ = ctx
+	if req.UserID == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	items, total, err := repo.NewTeamRoomProblemSetRepo(global.DB).ListByCreator(req.UserID, (page-1)*limit, limit)
+	if err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	resp.Total = total
+	resp.Items = make([]types.TeamRoomProblemSetInfo, 0, len(items))
+	for _, item := range items {
+		resp.Items = append(resp.Items, buildTeamRoomProblemSetInfo(item))
+	}
+	return resp, nil
+}
+
+func (l *TeamRoomLogic) DeleteProblemSet(ctx context.Context, req types.TeamRoomProblemSetReq) (resp types.TeamRoomProblemSetInfo, err error) {
+	_ = ctx
+	item, err := getTeamRoomProblemSet(req.ID, req.UserID)
+	if err != nil {
+		return resp, err
+	}
+	if err := repo.NewTeamRoomProblemSetRepo(global.DB).Delete(item.ID); err != nil {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	return buildTeamRoomProblemSetInfo(item), nil
+}
+
+// resolveTeamRoomProblemIDs 指定题单模板时使用模板中的题目，模板要求隐藏难度时房间也隐藏，只能使用自己创建的模板
+func resolveTeamRoomProblemIDs(userID int64, req types.TeamRoomCreateReq) ([]string, bool, error) {
+	if req.ProblemSetID == "" {
+		return req.ProblemIDs, req.HideDifficulty, nil
+	}
+	item, err := getTeamRoomProblemSet(req.ProblemSetID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	return parseTeamRoomProblemSetIDs(item.ProblemIDs), req.HideDifficulty || item.HideDifficulty, nil
+}
+
+// resolveTeamRoomProblemSetConfig 指定题目时只使用自定义配置中的时长和罚时
+func resolveTeamRoomProblemSetConfig(req types.TeamRoomCreateReq) (teamRoomModeConfig, error) {
+	config := teamRoomModeConfig{
+		Mode:            teamRoomProblemSetMode,
+		Duration:        teamRoomDefaultDuration,
+		PenaltyPerWrong: teamRoomPenaltyPerWrong,
+	}
+	if req.Custom == nil {
+		return config, nil
+	}
+	if req.Custom.Duration > 0 {
+		config.Duration = time.Duration(req.Custom.Duration) * time.Second
+	}
+	if req.Custom.PenaltyPerWrong != nil {
+		config.PenaltyPerWrong = *req.Custom.PenaltyPerWrong
+	}
+	config.FreezeMinutes = req.Custom.FreezeMinutes
+	return config, validateTeamRoomModeLimits(config)
+}
+
+// loadTeamRoomProblems 按传入顺序加载题目，去重并校验题目存在
+func loadTeamRoomProblems(problemIDs []string) ([]model.TeamRoomProblemItem, error) {
+	ids := make([]string, 0, len(problemIDs))
+	seen := make(map[string]struct{}, len(problemIDs))
+	for _, id := range problemIDs {
+		id = strings.ToUpper(strings.TrimSpace(id))
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if len(ids) > teamRoomModeMaxProblems {
+		return nil, response.ErrResp(errors.New("too many problems"), response.PARAM_NOT_VALID)
+	}
+	items, err := repo.NewCodeforcesProblemRepo(global.DB).ListByIDs(ids)
+	if err != nil {
+		return nil, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	problemMap := make(map[string]model.CodeforcesProblem, len(items))
+	for _, item := range items {
+		problemMap[item.ID] = item
+	}
+	problems := make([]model.TeamRoomProblemItem, 0, len(ids))
+	for _, id := range ids {
+		item, ok := problemMap[id]
+		if !ok {
+			return nil, response.ErrResp(errors.New("problem not exist: "+id), response.MESSAGE_NOT_EXIST)
+		}
+		problems = append(problems, model.TeamRoomProblemItem{
+			ProblemID:  item.ID,
+			ProblemURL: item.Url,
+			Difficulty: item.Difficulty,
+		})
+	}
+	return problems, nil
+}
+
+// getTeamRoomProblemSet 模板仅创建者可见，避免隐藏难度的题单被其他人读取
+func getTeamRoomProblemSet(idStr string, userID int64) (model.TeamRoomProblemSet, error) {
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id == 0 {
+		return model.TeamRoomProblemSet{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	item, err := repo.NewTeamRoomProblemSetRepo(global.DB).GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return item, response.ErrResp(err, response.MESSAGE_NOT_EXIST)
+		}
+		return item, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	if item.CreatorID != userID {
+		return item, response.ErrResp(errors.New("not problem set creator"), response.PERMISSION_DENIED)
+	}
+	return item, nil
+}
+
+func parseTeamRoomProblemSetIDs(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var ids []string
+	if err := json.Unmarshal([]byte(value), &ids); err != nil {
+		return nil
+	}
+	return ids
+}
+
+func buildTeamRoomProblemSetInfo(item model.TeamRoomProblemSet) types.TeamRoomProblemSetInfo {
+	return types.TeamRoomProblemSetInfo{
+		ID:             item.ID,
+		Name:           item.Name,
+		Description:    item.Description,
+		ProblemIDs:     parseTeamRoomProblemSetIDs(item.ProblemIDs),
+		HideDifficulty: item.HideDifficulty,
+		CreatorID:      item.CreatorID,
+		CreatedAt:      item.CreatedAt,
+	}
+}
@@ -0,0 +1,293 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"tgwp/global"
+	"tgwp/log/zlog"
+	"tgwp/repo"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	REDIS_TEAM_ROOM_OWNER = "team_room:owner:%d"
+
+	// 租约由运行 worker 的实例定期续期，实例宕机后租约过期，其他实例收到命令或定期检查时接管
+	teamRoomLeaseTTL       = 30 * time.Second
+	teamRoomLeaseRenew     = 10 * time.Second
+	teamRoomCommandTimeout = 5 * time.Second
+)
+
+const (
+	teamRoomCmdJoin       = "join"
+	teamRoomCmdLeave      = "leave"
+	teamRoomCmdClaim      = "claim"
+	teamRoomCmdUnclaim    = "unclaim"
+	teamRoomCmdTeamCreate = "team_create"
+	teamRoomCmdTeamJoin   = "team_join"
+	teamRoomCmdKick       = "kick"
+	teamRoomCmdTransfer   = "transfer"
+	teamRoomCmdLock       = "lock"
+	teamRoomCmdEnd        = "end"
+	teamRoomCmdPause      = "pause"
+	teamRoomCmdResume     = "resume"
+	teamRoomCmdExtend     = "extend"
+	teamRoomCmdReady      = "ready"
+	teamRoomCmdStart      = "start"
+	teamRoomCmdInfo       = "info"
+	teamRoomCmdSpectate   = "spectate"
+	teamRoomCmdOnline     = "presence_online"
+	teamRoomCmdAway       = "presence_away"
+)
+
+var teamRoomLeaseRenewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+var teamRoomLeaseReleaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// teamRoomCommand 修改房间的操作，房间 worker 在其他实例上时转发到该实例执行
+type teamRoomCommand struct {
+	Op        string                         `json:"op"`
+	RoomID    int64                          `json:"room_id"`
+	UserID    int64                          `json:"user_id"`
+	Role      int                            `json:"role,omitempty"`
+	TargetID  int64                          `json:"target_id,omitempty"`
+	TeamID    int                            `json:"team_id,omitempty"`
+	Name      string                         `json:"name,omitempty"`
+	ProblemID string                         `json:"problem_id,omitempty"`
+	State     string                         `json:"state,omitempty"`
+	Flag      bool                           `json:"flag,omitempty"`
+	Seconds   int64                          `json:"seconds,omitempty"`
+	Invite    types.TeamRoomInviteCredential `json:"invite"`
+}
+
+func teamRoomLeaseKey(roomID int64) string {
+	return fmt.Sprintf(REDIS_TEAM_ROOM_OWNER, roomID)
+}
+
+// acquireTeamRoomLease 未开启 ws-broker 时总是成功；Redis 不可用时不启动 worker，等待之后重试接管
+func acquireTeamRoomLease(roomID int64) bool {
+	broker := GetWsHub().broker.Load()
+	if broker == nil {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsBrokerTimeout)
+	defer cancel()
+	key := teamRoomLeaseKey(roomID)
+	ok, err := global.Rdb.SetNX(ctx, key, broker.instanceID, teamRoomLeaseTTL).Result()
+	if err != nil {
+		zlog.Warnf("获取团队房间租约失败：%v", err)
+		return false
+	}
+	if ok {
+		return true
+	}
+	owner, err := global.Rdb.Get(ctx, key).Result()
+	return err == nil && owner == broker.instanceID
+}
+
+func releaseTeamRoomLease(roomID int64) {
+	broker := GetWsHub().broker.Load()
+	if broker == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsBrokerTimeout)
+	defer cancel()
+	if err := teamRoomLeaseReleaseScript.Run(ctx, global.Rdb, []string{teamRoomLeaseKey(roomID)}, broker.instanceID).Err(); err != nil {
+		zlog.Warnf("释放团队房间租约失败：%v", err)
+	}
+}
+
+// resolveTeamRoomOwner 返回需要转发命令的实例。本实例运行 worker、未开启 ws-broker 或房间已结束时在本地处理；
+// 房间没有租约时由本实例接管
+func resolveTeamRoomOwner(roomID int64) (string, bool, error) {
+	broker := GetWsHub().broker.Load()
+	if broker == nil || GetTeamRoomManager().getWorker(roomID) != nil {
+		return "", false, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsBrokerTimeout)
+	defer cancel()
+	key := teamRoomLeaseKey(roomID)
+	owner, err := global.Rdb.Get(ctx, key).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", false, response.ErrResp(err, response.REDIS_ERROR)
+	}
+	if owner == "" {
+		adoptTeamRoom(roomID)
+		if GetTeamRoomManager().getWorker(roomID) != nil {
+			return "", false, nil
+		}
+		if owner, err = global.Rdb.Get(ctx, key).Result(); err != nil && !errors.Is(err, redis.Nil) {
+			return "", false, response.ErrResp(err, response.REDIS_ERROR)
+		}
+	}
+	if owner == "" || owner == broker.instanceID {
+		return "", false, nil
+	}
+	return owner, true, nil
+}
+
+// adoptTeamRoom 未结束且没有租约的房间在本实例启动 worker
+func adoptTeamRoom(roomID int64) {
+	room, err := getStoredTeamRoom(roomID)
+	if err != nil || room.Status == 1 {
+		return
+	}
+	GetTeamRoomManager().StartRoom(room)
+}
+
+// routeTeamRoomCommand 房间 worker 在其他实例上时转发命令并返回执行结果，routed 为 false 时由调用方在本地执行
+func routeTeamRoomCommand[T any](cmd teamRoomCommand) (resp T, routed bool, err error) {
+	owner, routed, err := resolveTeamRoomOwner(cmd.RoomID)
+	if err != nil || !routed {
+		return resp, routed, err
+	}
+	broker := GetWsHub().broker.Load()
+	if broker == nil {
+		return resp, false, nil
+	}
+	result, err := broker.call(owner, cmd, teamRoomCommandTimeout)
+	if err != nil {
+		return resp, true, err
+	}
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &resp); err != nil {
+			return resp, true, response.ErrResp(err, response.INTERNAL_ERROR)
+		}
+	}
+	return resp, true, nil
+}
+
+// executeTeamRoomCommand 执行其他实例转发来的命令
+func executeTeamRoomCommand(body json.RawMessage) (interface{}, error) {
+	var cmd teamRoomCommand
+	if err := json.Unmarshal(body, &cmd); err != nil {
+		return nil, response.ErrResp(err, response.PARAM_NOT_VALID)
+	}
+	ctx := context.Background()
+	l := NewTeamRoomLogic()
+	switch cmd.Op {
+	case teamRoomCmdJoin:
+		return l.JoinRoom(ctx, cmd.UserID, cmd.RoomID, cmd.Invite)
+	case teamRoomCmdLeave:
+		return l.LeaveRoom(ctx, cmd.UserID, cmd.RoomID)
+	case teamRoomCmdClaim:
+		return nil, l.ClaimProblem(ctx, cmd.UserID, cmd.RoomID, cmd.ProblemID, cmd.State)
+	case teamRoomCmdUnclaim:
+		return nil, l.UnclaimProblem(ctx, cmd.UserID, cmd.RoomID, cmd.ProblemID)
+	case teamRoomCmdTeamCreate:
+		return l.CreateTeam(ctx, cmd.UserID, cmd.RoomID, cmd.Name)
+	case teamRoomCmdTeamJoin:
+		return l.JoinTeam(ctx, cmd.UserID, cmd.RoomID, cmd.TeamID)
+	case teamRoomCmdKick:
+		return l.KickPlayer(ctx, cmd.UserID, cmd.RoomID, cmd.TargetID)
+	case teamRoomCmdTransfer:
+		return l.TransferOwner(ctx, cmd.UserID, cmd.RoomID, cmd.TargetID)
+	case teamRoomCmdLock:
+		return l.LockRoom(ctx, cmd.UserID, cmd.RoomID, cmd.Flag)
+	case teamRoomCmdEnd:
+		return l.EndRoom(ctx, cmd.UserID, cmd.RoomID)
+	case teamRoomCmdPause:
+		return l.PauseRoom(ctx, cmd.UserID, cmd.Role, cmd.RoomID)
+	case teamRoomCmdResume:
+		return l.ResumeRoom(ctx, cmd.UserID, cmd.Role, cmd.RoomID)
+	case teamRoomCmdExtend:
+		return l.ExtendRoom(ctx, cmd.UserID, cmd.Role, cmd.RoomID, cmd.Seconds)
+	case teamRoomCmdReady:
+		return l.SetReady(ctx, cmd.UserID, cmd.RoomID, cmd.Flag)
+	case teamRoomCmdStart:
+		return l.StartNow(ctx, cmd.UserID, cmd.RoomID)
+	case teamRoomCmdInfo:
+		resp, err := l.GetRoomInfo(ctx, types.TeamRoomInfoReq{RoomID: strconv.FormatInt(cmd.RoomID, 10)})
+		return resp.Room, err
+	case teamRoomCmdSpectate:
+		return l.SpectateRoom(ctx, cmd.UserID, cmd.RoomID, cmd.Invite)
+	case teamRoomCmdOnline:
+		markTeamRoomOnline(cmd.RoomID, cmd.UserID)
+		return nil, nil
+	case teamRoomCmdAway:
+		markTeamRoomAway(cmd.RoomID, cmd.UserID)
+		return nil, nil
+	default:
+		return nil, response.ErrResp(errors.New("unknown room command"), response.MESSAGE_NOT_EXIST)
+	}
+}
+
+// runTeamRoomLeaseLoop 续期本实例运行的房间租约，租约被其他实例持有时停止本地 worker；
+// 同时接管租约已过期的房间，避免原实例宕机后房间无人结算
+func runTeamRoomLeaseLoop(broker *wsBroker) {
+	ticker := time.NewTicker(teamRoomLeaseRenew)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			renewTeamRoomLeases(broker)
+			adoptOrphanTeamRooms()
+		case <-broker.stopCh:
+			return
+		}
+	}
+}
+
+func renewTeamRoomLeases(broker *wsBroker) {
+	manager := GetTeamRoomManager()
+	for _, roomID := range manager.roomIDs() {
+		ctx, cancel := context.WithTimeout(context.Background(), wsBrokerTimeout)
+		renewed, err := teamRoomLeaseRenewScript.Run(ctx, global.Rdb, []string{teamRoomLeaseKey(roomID)},
+			broker.instanceID, teamRoomLeaseTTL.Milliseconds()).Int()
+		cancel()
+		if err != nil {
+			zlog.Warnf("续期团队房间租约失败：%v", err)
+			continue
+		}
+		if renewed == 0 {
+			zlog.Warnf("团队房间租约已被其他实例持有，停止本地房间：%d", roomID)
+			manager.StopRoom(roomID)
+		}
+	}
+}
+
+func adoptOrphanTeamRooms() {
+	rooms, err := repo.NewTeamRoomRepo(global.DB).ListActive()
+	if err != nil {
+		zlog.Warnf("检查待接管团队房间失败：%v", err)
+		return
+	}
+	manager := GetTeamRoomManager()
+	for _, room := range rooms {
+		if manager.getWorker(room.ID) != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), wsBrokerTimeout)
+		exists, err := global.Rdb.Exists(ctx, teamRoomLeaseKey(room.ID)).Result()
+		cancel()
+		if err != nil || exists > 0 {
+			continue
+		}
+		zlog.Infof("接管租约已过期的团队房间：%d", room.ID)
+		adoptTeamRoom(room.ID)
+	}
+}
+
+// releaseTeamRoomLeases 实例退出前停止本地房间并释放租约，其他实例可以立即接管
+func releaseTeamRoomLeases() {
+	manager := GetTeamRoomManager()
+	for _, roomID := range manager.roomIDs() {
+		manager.StopRoom(roomID)
+	}
+}
@@ -0,0 +1,51 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const teamRoomMaxSpectatorDelay = 10 * time.Minute
+
+// SpectateRoom 观战只绑定房间推送，不加入玩家列表，也不会拉取观战者的提交
+func (l *TeamRoomLogic) SpectateRoom(ctx context.Context, userID int64, roomID int64, invite types.TeamRoomInviteCredential) (types.TeamRoomInfo, error) {
+	_ = ctx
+	if userID == 0 || roomID == 0 {
+		return types.TeamRoomInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomInfo](teamRoomCommand{Op: teamRoomCmdSpectate, RoomID: roomID, UserID: userID, Invite: invite}); routed || err != nil {
+		return resp, err
+	}
+	room, err := getTeamRoom(roomID)
+	if err != nil {
+		return types.TeamRoomInfo{}, err
+	}
+	if findTeamRoomPlayer(room.Players, userID) < 0 && room.CreatorID != userID {
+		extra := parseTeamRoomExtra(room.ExtraInfo)
+		for _, id := range extra.Kicked {
+			if id == userID {
+				return types.TeamRoomInfo{}, response.ErrResp(errors.New("kicked from room"), response.PERMISSION_DENIED)
+			}
+		}
+		if extra.Private && !checkTeamRoomInvite(room.ID, extra.InviteCode, invite) {
+			return types.TeamRoomInfo{}, response.ErrResp(errors.New("invite required"), response.PERMISSION_DENIED)
+		}
+	}
+	return buildTeamRoomInfo(room), nil
+}
+
+func broadcastTeamRoomSpectators(roomID int64) {
+	GetWsHub().SendToRoom(roomID, types.WsResponse{
+		Type:    "team_room_spectator_update",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomSpectatorUpdateData{
+			RoomID:     roomID,
+			Spectators: GetWsHub().RoomSpectatorCount(roomID),
+		},
+	})
+}
@@ -0,0 +1,128 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"gorm.io/gorm"
+
+	"tgwp/global"
+	"tgwp/log/zlog"
+	"tgwp/model"
+	"tgwp/repo"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+func (l *TeamRoomLogic) GetUserStats(ctx context.Context, req types.UserTeamStatsReq) (resp types.UserTeamStatsResp, err error) {
+	_ = ctx
+	if req.UserID == 0 {
+		return resp, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	item, err := repo.NewUserTeamStatsRepo(global.DB).GetByUser(req.UserID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return resp, response.ErrResp(err, response.DATABASE_ERROR)
+	}
+	resp.UserID = req.UserID
+	resp.Rooms = item.Rooms
+	resp.Wins = item.Wins
+	resp.Solved = item.Solved
+	resp.WrongCount = item.WrongCount
+	resp.FirstBloods = item.FirstBloods
+	resp.Points = item.Points
+	return resp, nil
+}
+
+// buildTeamRoomPlayerStats 按玩家统计通过数、错误提交、首次通过时间，得分按通过题目难度计算并给出占所在队伍的比例
+func buildTeamRoomPlayerStats(room model.TeamRoom) []types.TeamRoomPlayerStat {
+	players := room.Players
+	if len(players) == 0 {
+		return nil
+	}
+	extra := parseTeamRoomExtra(room.ExtraInfo)
+	// 隐藏难度的房间结束前不计算得分，避免通过得分推算难度
+	showPoints := !extra.HideDifficulty || room.Status == 1
+	problems := make(map[string]model.TeamRoomProblemItem)
+	for _, p := range room.Problems {
+		problems[p.ProblemID] = p
+	}
+	statsMap := make(map[int64]*types.TeamRoomPlayerStat, len(players))
+	stats := make([]types.TeamRoomPlayerStat, len(players))
+	for i, player := range players {
+		stats[i] = types.TeamRoomPlayerStat{
+			UserID:   player.UserID,
+			Username: player.Username,
+			TeamID:   player.TeamID,
+		}
+		statsMap[player.UserID] = &stats[i]
+	}
+	teamPoints := make(map[int]int64)
+	for _, item := range room.ProblemStatus {
+		if !item.Solved {
+			continue
+		}
+		points := int64(problems[item.ProblemID].Difficulty)
+		teamPoints[item.TeamID] += points
+		stat, ok := statsMap[item.SolvedBy]
+		if !ok {
+			continue
+		}
+		stat.Solved++
+		stat.Points += points
+		if stat.FirstSolveAt == 0 || item.SolvedAt < stat.FirstSolveAt {
+			stat.FirstSolveAt = item.SolvedAt
+		}
+	}
+	for _, s := range room.Submissions {
+		if s.Verdict == "OK" || s.Verdict == teamRoomFrozenVerdict {
+			continue
+		}
+		if stat, ok := statsMap[s.UserID]; ok {
+			stat.WrongCount++
+		}
+	}
+	for i := range stats {
+		if !showPoints {
+			stats[i].Points = 0
+			continue
+		}
+		if total := teamPoints[stats[i].TeamID]; total > 0 {
+			stats[i].ScoreShare = math.Round(float64(stats[i].Points)/float64(total)*10000) / 10000
+		}
+	}
+	return stats
+}
+
+// accumulateUserTeamStats 房间结束后把本场数据累加到玩家的生涯统计
+func accumulateUserTeamStats(room model.TeamRoom, stats []types.TeamRoomPlayerStat, winners []int64) {
+	winnerSet := make(map[int64]struct{}, len(winners))
+	for _, id := range winners {
+		winnerSet[id] = struct{}{}
+	}
+	var firstBlood model.TeamRoomProblemStatusItem
+	for _, item := range room.ProblemStatus {
+		if item.Solved && (firstBlood.SolvedBy == 0 || item.SolvedAt < firstBlood.SolvedAt) {
+			firstBlood = item
+		}
+	}
+	statsRepo := repo.NewUserTeamStatsRepo(global.DB)
+	for _, stat := range stats {
+		delta := model.UserTeamStats{
+			UserID:     stat.UserID,
+			Rooms:      1,
+			Solved:     stat.Solved,
+			WrongCount: stat.WrongCount,
+			Points:     stat.Points,
+		}
+		if _, ok := winnerSet[stat.UserID]; ok {
+			delta.Wins = 1
+		}
+		if firstBlood.SolvedBy != 0 && firstBlood.SolvedBy == stat.UserID {
+			delta.FirstBloods = 1
+		}
+		if err := statsRepo.Accumulate(&delta); err != nil {
+			zlog.Warnf("团队生涯统计更新失败：%v", err)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package logic
+
+import (
+	"gorm.io/gorm"
+
+	"tgwp/global"
+	"tgwp/log/zlog"
+	"tgwp/model"
+	"tgwp/repo"
+	"tgwp/response"
+)
+
+const teamRoomMigrateBatch = 100
+
+// createTeamRoom 在同一事务中写入房间及其题目、题目情况和玩家
+func createTeamRoom(room *model.TeamRoom, problems []model.TeamRoomProblemItem, status []model.TeamRoomProblemStatusItem, players []model.TeamRoomPlayerItem) error {
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := repo.NewTeamRoomRepo(tx).Create(room); err != nil {
+			return err
+		}
+		return createTeamRoomRelations(tx, room.ID, problems, status, players, nil)
+	})
+	if err != nil {
+		return err
+	}
+	room.Problems = problems
+	room.ProblemStatus = status
+	room.Players = players
+	room.Submissions = []model.TeamRoomSubmissionItem{}
+	return nil
+}
+
+func createTeamRoomRelations(tx *gorm.DB, roomID int64, problems []model.TeamRoomProblemItem, status []model.TeamRoomProblemStatusItem, players []model.TeamRoomPlayerItem, submissions []model.TeamRoomSubmissionItem) error {
+	problemItems := make([]model.TeamRoomProblem, 0, len(problems))
+	for i, p := range problems {
+		problemItems = append(problemItems, model.TeamRoomProblem{
+			RoomID:     roomID,
+			ProblemID:  p.ProblemID,
+			Sort:       i,
+			ProblemURL: p.ProblemURL,
+			Difficulty: p.Difficulty,
+		})
+	}
+	if err := repo.NewTeamRoomProblemRepo(tx).CreateBatch(problemItems); err != nil {
+		return err
+	}
+	statusItems := make([]model.TeamRoomProblemStatus, 0, len(status))
+	for _, item := range status {
+		statusItems = append(statusItems, toTeamRoomProblemStatusModel(roomID, item))
+	}
+	if err := repo.NewTeamRoomProblemStatusRepo(tx).CreateBatch(statusItems); err != nil {
+		return err
+	}
+	playerItems := make([]model.TeamRoomPlayer, 0, len(players))
+	for _, p := range players {
+		playerItems = append(playerItems, toTeamRoomPlayerModel(roomID, p))
+	}
+	if err := repo.NewTeamRoomPlayerRepo(tx).CreateBatch(playerItems); err != nil {
+		return err
+	}
+	submissionItems := make([]model.TeamRoomSubmission, 0, len(submissions))
+	for _, s := range submissions {
+		submissionItems = append(submissionItems, toTeamRoomSubmissionModel(roomID, s))
+	}
+	return repo.NewTeamRoomSubmissionRepo(tx).CreateBatch(submissionItems)
+}
+
+// getStoredTeamRoom 从数据库读取房间并加载关联数据，不经过运行中的 worker
+func getStoredTeamRoom(roomID int64) (model.TeamRoom, error) {
+	room, err := repo.NewTeamRoomRepo(global.DB).GetByID(roomID)
+	if err != nil {
+		return room, err
+	}
+	rooms := []model.TeamRoom{room}
+	if err := loadTeamRoomData(rooms); err != nil {
+		return room, err
+	}
+	return rooms[0], nil
+}
+
+// loadTeamRoomData 批量加载房间的题目、玩家、题目情况和提交记录
+func loadTeamRoomData(rooms []model.TeamRoom) error {
+	if len(rooms) == 0 {
+		return nil
+	}
+	if err := loadTeamRoomPlayers(rooms); err != nil {
+		return err
+	}
+	roomIDs := getTeamRoomIDs(rooms)
+	problemItems, err := repo.NewTeamRoomProblemRepo(global.DB).ListByRooms(roomIDs)
+	if err != nil {
+		return err
+	}
+	statusItems, err := repo.NewTeamRoomProblemStatusRepo(global.DB).ListByRooms(roomIDs)
+	if err != nil {
+		return err
+	}
+	submissionItems, err := repo.NewTeamRoomSubmissionRepo(global.DB).ListByRooms(roomIDs)
+	if err != nil {
+		return err
+	}
+	problems := make(map[int64][]model.TeamRoomProblemItem, len(rooms))
+	for _, item := range problemItems {
+		problems[item.RoomID] = append(problems[item.RoomID], model.TeamRoomProblemItem{
+			ProblemID:  item.ProblemID,
+			ProblemURL: item.ProblemURL,
+			Difficulty: item.Difficulty,
+		})
+	}
+	status := make(map[int64][]model.TeamRoomProblemStatusItem, len(rooms))
+	for _, item := range statusItems {
+		status[item.RoomID] = append(status[item.RoomID], model.TeamRoomProblemStatusItem{
+			ProblemID:  item.ProblemID,
+			TeamID:     item.TeamID,
+			Solved:     item.Solved,
+			SolvedBy:   item.SolvedBy,
+			Penalty:    item.Penalty,
+			WrongCount: item.WrongCount,
+			SolvedAt:   item.SolvedAt,
+		})
+	}
+	submissions := make(map[int64][]model.TeamRoomSubmissionItem, len(rooms))
+	for _, item := range submissionItems {
+		submissions[item.RoomID] = append(submissions[item.RoomID], model.TeamRoomSubmissionItem{
+			SubmissionID: item.SubmissionID,
+			ProblemID:    item.ProblemID,
+			UserID:       item.UserID,
+			TeamID:       item.TeamID,
+			Verdict:      item.Verdict,
+			SubmitTime:   item.SubmitTime,
+		})
+	}
+	for i := range rooms {
+		id := rooms[i].ID
+		rooms[i].Problems = problems[id]
+		rooms[i].ProblemStatus = status[id]
+		rooms[i].Submissions = submissions[id]
+	}
+	return nil
+}
+
+// loadTeamRoomPlayers 只需要玩家列表时使用，避免加载全部提交记录
+func loadTeamRoomPlayers(rooms []model.TeamRoom) error {
+	if len(rooms) == 0 {
+		return nil
+	}
+	items, err := repo.NewTeamRoomPlayerRepo(global.DB).ListByRooms(getTeamRoomIDs(rooms))
+	if err != nil {
+		return err
+	}
+	players := make(map[int64][]model.TeamRoomPlayerItem, len(rooms))
+	for _, item := range items {
+		players[item.RoomID] = append(players[item.RoomID], model.TeamRoomPlayerItem{
+			UserID:   item.UserID,
+			Username: item.Username,
+			JoinAt:   item.JoinAt,
+			TeamID:   item.TeamID,
+			Ready:    item.Ready,
+		})
+	}
+	for i := range rooms {
+		rooms[i].Players = players[rooms[i].ID]
+	}
+	return nil
+}
+
+// saveTeamRoomPlayers 与当前玩家列表比较，只写入新增、变化和离开的玩家
+func saveTeamRoomPlayers(room *model.TeamRoom, players []model.TeamRoomPlayerItem) error {
+	playerRepo := repo.NewTeamRoomPlayerRepo(global.DB)
+	previous := make(map[int64]model.TeamRoomPlayerItem)
+	for _, p := range room.Players {
+		previous[p.UserID] = p
+	}
+	for _, p := range players {
+		if old, ok := previous[p.UserID]; ok {
+			delete(previous, p.UserID)
+			if old == p {
+				continue
+			}
+		}
+		item := toTeamRoomPlayerModel(room.ID, p)
+		if err := playerRepo.Save(&item); err != nil {
+			return response.ErrResp(err, response.DATABASE_ERROR)
+		}
+	}
+	for userID := range previous {
+		if err := playerRepo.Delete(room.ID, userID); err != nil {
+			return response.ErrResp(err, response.DATABASE_ERROR)
+		}
+	}
+	room.Players = players
+	return nil
+}
+
+// saveTeamRoomProblemStatus 只写入新增或变化的题目情况
+func saveTeamRoomProblemStatus(room *model.TeamRoom, status []model.TeamRoomProblemStatusItem) error {
+	previous := make(map[teamRoomStatusKey]model.TeamRoomProblemStatusItem)
+	for _, item := range room.ProblemStatus {
+		previous[teamRoomStatusKey{TeamID: item.TeamID, ProblemID: item.ProblemID}] = item
+	}
+	statusRepo := repo.NewTeamRoomProblemStatusRepo(global.DB)
+	for _, item := range status {
+		if old, ok := previous[teamRoomStatusKey{TeamID: item.TeamID, ProblemID: item.ProblemID}]; ok && old == item {
+			continue
+		}
+		record := toTeamRoomProblemStatusModel(room.ID, item)
+		if err := statusRepo.Save(&record); err != nil {
+			return response.ErrResp(err, response.DATABASE_ERROR)
+		}
+	}
+	room.ProblemStatus = status
+	return nil
+}
+
+// MigrateTeamRoomData 把旧版本保存在 team_room 表JSON列中的数据迁移到关联表，迁移后清空JSON列
+func MigrateTeamRoomData() error {
+	roomRepo := repo.NewTeamRoomRepo(global.DB)
+	if !roomRepo.HasLegacyColumns() {
+		return nil
+	}
+	migrated := 0
+	for {
+		items, err := roomRepo.ListLegacy(teamRoomMigrateBatch)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			err := global.DB.Transaction(func(tx *gorm.DB) error {
+				err := createTeamRoomRelations(tx, item.ID,
+					parseLegacyTeamRoomProblems(item.ProblemList),
+					parseLegacyTeamRoomProblemStatus(item.ProblemStatus),
+					parseLegacyTeamRoomPlayers(item.PlayerList),
+					parseLegacyTeamRoomSubmissions(item.SubmissionRecords))
+				if err != nil {
+					return err
+				}
+				return repo.NewTeamRoomRepo(tx).ClearLegacy(item.ID)
+			})
+			if err != nil {
+				return err
+			}
+			migrated++
+		}
+	}
+	if migrated > 0 {
+		zlog.Infof("团队房间数据迁移完成，共 %d 个房间", migrated)
+	}
+	return nil
+}
+
+func getTeamRoomIDs(rooms []model.TeamRoom) []int64 {
+	ids := make([]int64, 0, len(rooms))
+	for _, room := range rooms {
+		ids = append(ids, room.ID)
+	}
+	return ids
+}
+
+func toTeamRoomPlayerModel(roomID int64, p model.TeamRoomPlayerItem) model.TeamRoomPlayer {
+	return model.TeamRoomPlayer{
+		RoomID:   roomID,
+		UserID:   p.UserID,
+		Username: p.Username,
+		TeamID:   p.TeamID,
+		Ready:    p.Ready,
+		JoinAt:   p.JoinAt,
+	}
+}
+
+func toTeamRoomProblemStatusModel(roomID int64, item model.TeamRoomProblemStatusItem) model.TeamRoomProblemStatus {
+	return model.TeamRoomProblemStatus{
+		RoomID:     roomID,
+		TeamID:     item.TeamID,
+		ProblemID:  item.ProblemID,
+		Solved:     item.Solved,
+		SolvedBy:   item.SolvedBy,
+		SolvedAt:   item.SolvedAt,
+		Penalty:    item.Penalty,
+		WrongCount: item.WrongCount,
+	}
+}
+
+func toTeamRoomSubmissionModel(roomID int64, s model.TeamRoomSubmissionItem) model.TeamRoomSubmission {
+	return model.TeamRoomSubmission{
+		RoomID:       roomID,
+		SubmissionID: s.SubmissionID,
+		ProblemID:    s.ProblemID,
+		UserID:       s.UserID,
+		TeamID:       s.TeamID,
+		Verdict:      s.Verdict,
+		SubmitTime:   s.SubmitTime,
+	}
+}
@@ -0,0 +1,287 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"tgwp/model"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	teamRoomMaxTeams       = 16
+	teamRoomTeamNameMaxLen = 32
+)
+
+func (l *TeamRoomLogic) CreateTeam(ctx context.Context, userID int64, roomID int64, name string) (types.TeamRoomInfo, error) {
+	_ = ctx
+	if userID == 0 || roomID == 0 {
+		return types.TeamRoomInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomInfo](teamRoomCommand{Op: teamRoomCmdTeamCreate, RoomID: roomID, UserID: userID, Name: name}); routed || err != nil {
+		return resp, err
+	}
+	room, err := updateTeamRoom(roomID, func(room *model.TeamRoom) error {
+		if room.Status == 1 {
+			return response.ErrResp(errors.New("room finished"), response.PARAM_NOT_VALID)
+		}
+		extra := parseTeamRoomExtra(room.ExtraInfo)
+		if !extra.Competitive {
+			return response.ErrResp(errors.New("room not competitive"), response.PARAM_NOT_VALID)
+		}
+		if len(extra.Teams) >= teamRoomMaxTeams {
+			return response.ErrResp(errors.New("too many teams"), response.PARAM_NOT_VALID)
+		}
+		players := slices.Clone(room.Players)
+		index := findTeamRoomPlayer(players, userID)
+		if index < 0 {
+			return response.ErrResp(errors.New("not in room"), response.PERMISSION_DENIED)
+		}
+		if err := checkTeamSwitchable(*room, userID); err != nil {
+			return err
+		}
+		team, err := newTeamRoomTeam(extra.Teams, name)
+		if err != nil {
+			return err
+		}
+		extra.Teams = append(extra.Teams, team)
+		status := appendTeamProblemStatus(slices.Clone(room.ProblemStatus), room.Problems, team.TeamID)
+		players[index].TeamID = team.TeamID
+		if err := saveTeamRoomExtra(room, extra); err != nil {
+			return err
+		}
+		if err := saveTeamRoomProblemStatus(room, status); err != nil {
+			return err
+		}
+		return saveTeamRoomPlayers(room, players)
+	})
+	if err != nil {
+		return types.TeamRoomInfo{}, err
+	}
+	broadcastTeamRoomScoreboard(room)
+	return buildTeamRoomInfo(room), nil
+}
+
+func (l *TeamRoomLogic) JoinTeam(ctx context.Context, userID int64, roomID int64, teamID int) (types.TeamRoomInfo, error) {
+	_ = ctx
+	if userID == 0 || roomID == 0 || teamID <= 0 {
+		return types.TeamRoomInfo{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if resp, routed, err := routeTeamRoomCommand[types.TeamRoomInfo](teamRoomCommand{Op: teamRoomCmdTeamJoin, RoomID: roomID, UserID: userID, TeamID: teamID}); routed || err != nil {
+		return resp, err
+	}
+	room, err := updateTeamRoom(roomID, func(room *model.TeamRoom) error {
+		if room.Status == 1 {
+			return response.ErrResp(errors.New("room finished"), response.PARAM_NOT_VALID)
+		}
+		extra := parseTeamRoomExtra(room.ExtraInfo)
+		if !extra.Competitive {
+			return response.ErrResp(errors.New("room not competitive"), response.PARAM_NOT_VALID)
+		}
+		exist := false
+		for _, team := range extra.Teams {
+			if team.TeamID == teamID {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			return response.ErrResp(errors.New("team not exist"), response.MESSAGE_NOT_EXIST)
+		}
+		players := slices.Clone(room.Players)
+		index := findTeamRoomPlayer(players, userID)
+		if index < 0 {
+			return response.ErrResp(errors.New("not in room"), response.PERMISSION_DENIED)
+		}
+		if players[index].TeamID == teamID {
+			return nil
+		}
+		if err := checkTeamSwitchable(*room, userID); err != nil {
+			return err
+		}
+		players[index].TeamID = teamID
+		return saveTeamRoomPlayers(room, players)
+	})
+	if err != nil {
+		return types.TeamRoomInfo{}, err
+	}
+	broadcastTeamRoomScoreboard(room)
+	return buildTeamRoomInfo(room), nil
+}
+
+// checkTeamSwitchable 已经为队伍提交过的玩家不能再更换队伍
+func checkTeamSwitchable(room model.TeamRoom, userID int64) error {
+	for _, s := range room.Submissions {
+		if s.UserID == userID && s.TeamID != 0 {
+			return response.ErrResp(errors.New("already submitted for team"), response.PARAM_NOT_VALID)
+		}
+	}
+	return nil
+}
+
+func newTeamRoomTeam(teams []teamRoomTeam, name string) (teamRoomTeam, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return teamRoomTeam{}, response.ErrResp(errors.New("param blank"), response.PARAM_NOT_COMPLETE)
+	}
+	if utf8.RuneCountInString(name) > teamRoomTeamNameMaxLen {
+		return teamRoomTeam{}, response.ErrResp(errors.New("team name too long"), response.PARAM_NOT_VALID)
+	}
+	nextID := 1
+	for _, team := range teams {
+		if team.Name == name {
+			return teamRoomTeam{}, response.ErrResp(errors.New("team name exists"), response.PARAM_NOT_VALID)
+		}
+		if team.TeamID >= nextID {
+			nextID = team.TeamID + 1
+		}
+	}
+	return teamRoomTeam{TeamID: nextID, Name: name}, nil
+}
+
+func appendTeamProblemStatus(status []model.TeamRoomProblemStatusItem, problems []model.TeamRoomProblemItem, teamID int) []model.TeamRoomProblemStatusItem {
+	for _, p := range problems {
+		status = append(status, model.TeamRoomProblemStatusItem{
+			ProblemID: p.ProblemID,
+			TeamID:    teamID,
+		})
+	}
+	return status
+}
+
+func findTeamRoomPlayer(players []model.TeamRoomPlayerItem, userID int64) int {
+	for i := range players {
+		if players[i].UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+func getTeamRoomPlayerTeam(players []model.TeamRoomPlayerItem, userID int64) int {
+	if index := findTeamRoomPlayer(players, userID); index >= 0 {
+		return players[index].TeamID
+	}
+	return 0
+}
+
+// buildTeamRoomScoreboard ICPC 排名：通过数降序，罚时升序，罚时为通过时间（分钟）加错误罚时
+func buildTeamRoomScoreboard(room model.TeamRoom) []types.TeamRoomScoreboardItem {
+	extra := parseTeamRoomExtra(room.ExtraInfo)
+	if len(extra.Teams) == 0 {
+		return nil
+	}
+	problems := room.Problems
+	if room.Status == 2 {
+		problems = nil
+	}
+	pending := make(map[teamRoomStatusKey]int)
+	for _, s := range room.Submissions {
+		if s.Verdict == teamRoomFrozenVerdict {
+			pending[teamRoomStatusKey{TeamID: s.TeamID, ProblemID: s.ProblemID}]++
+		}
+	}
+	statusMap := make(map[int]map[string]model.TeamRoomProblemStatusItem, len(extra.Teams))
+	for _, item := range room.ProblemStatus {
+		if _, ok := statusMap[item.TeamID]; !ok {
+			statusMap[item.TeamID] = make(map[string]model.TeamRoomProblemStatusItem)
+		}
+		statusMap[item.TeamID][item.ProblemID] = item
+	}
+	items := make([]types.TeamRoomScoreboardItem, 0, len(extra.Teams))
+	for _, team := range extra.Teams {
+		item := types.TeamRoomScoreboardItem{
+			TeamID:   team.TeamID,
+			Name:     team.Name,
+			Problems: make([]types.TeamRoomScoreboardProblem, 0, len(problems)),
+		}
+		for _, p := range problems {
+			stat := statusMap[team.TeamID][p.ProblemID]
+			problem := types.TeamRoomScoreboardProblem{
+				ProblemID:  p.ProblemID,
+				Solved:     stat.Solved,
+				SolvedAt:   stat.SolvedAt,
+				WrongCount: stat.WrongCount,
+			}
+			if !stat.Solved {
+				problem.Pending = pending[teamRoomStatusKey{TeamID: team.TeamID, ProblemID: p.ProblemID}]
+			}
+			item.Problems = append(item.Problems, problem)
+			if stat.Solved {
+				item.Solved++
+				item.Penalty += stat.SolvedAt/60 + int64(stat.Penalty)
+			}
+		}
+		items = append(items, item)
+	}
+	rankTeamRoomScoreboard(items)
+	return items
+}
+
+func rankTeamRoomScoreboard(items []types.TeamRoomScoreboardItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Solved != items[j].Solved {
+			return items[i].Solved > items[j].Solved
+		}
+		return items[i].Penalty < items[j].Penalty
+	})
+	for i := range items {
+		if i > 0 && items[i].Solved == items[i-1].Solved && items[i].Penalty == items[i-1].Penalty {
+			items[i].Rank = items[i-1].Rank
+			continue
+		}
+		items[i].Rank = i + 1
+	}
+}
+
+func broadcastTeamRoomScoreboard(room model.TeamRoom) {
+	if len(parseTeamRoomExtra(room.ExtraInfo).Teams) == 0 {
+		return
+	}
+	frozen := isTeamRoomFrozen(room)
+	sendTeamRoomView(room, func(teamID int) types.WsResponse {
+		return types.WsResponse{
+			Type:    "team_room_scoreboard",
+			Code:    response.SUCCESS.Code,
+			Message: response.SUCCESS.Msg,
+			Data: types.TeamRoomScoreboardData{
+				RoomID:     room.ID,
+				Frozen:     frozen,
+				Scoreboard: buildTeamRoomScoreboard(maskFrozenTeamRoom(room, teamID)),
+			},
+		}
+	})
+}
+
+// getTeamRoomWinners 合作模式全部通过即全员获胜，对抗模式下排名第一且有通过的队伍获胜
+func getTeamRoomWinners(room model.TeamRoom) []int64 {
+	extra := parseTeamRoomExtra(room.ExtraInfo)
+	players := room.Players
+	winners := make([]int64, 0, len(players))
+	if !extra.Competitive {
+		if !extra.AllSolved {
+			return nil
+		}
+		for _, player := range players {
+			winners = append(winners, player.UserID)
+		}
+		return winners
+	}
+	winTeams := make(map[int]struct{})
+	for _, item := range extra.TeamResults {
+		if item.Rank == 1 && item.Solved > 0 {
+			winTeams[item.TeamID] = struct{}{}
+		}
+	}
+	for _, player := range players {
+		if _, ok := winTeams[player.TeamID]; ok {
+			winners = append(winners, player.UserID)
+		}
+	}
+	return winners
+}
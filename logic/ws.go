@@ -8,13 +8,12 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 
-	"tgwp/global"
 	"tgwp/log/zlog"
-	"tgwp/repo"
 	"tgwp/response"
 	"tgwp/types"
 )
@@ -29,17 +28,38 @@ const (
 type WsHandler func(ctx *WsContext, data json.RawMessage) error
 
 type WsContext struct {
-	Ctx    context.Context
-	Conn   *websocket.Conn
-	UserID int64
-	RootID int64
-	Hub    *WsHub
+	Ctx       context.Context
+	Conn      *websocket.Conn
+	UserID    int64
+	Role      int
+	RootID    int64
+	Version   int
+	RequestID string
+	Hub       *WsHub
 }
 
 type wsConnInfo struct {
-	UserID  int64
-	RootID  int64
-	WriteMu sync.Mutex
+	UserID    int64
+	Role      int
+	RootID    int64
+	Version   int
+	Spectator *wsSpectator
+	WriteMu   sync.Mutex
+}
+
+const wsSpectatorQueueSize = 256
+
+// wsSpectator 观战连接的推送设置，有延迟时通过队列按顺序延后推送
+type wsSpectator struct {
+	Delay    time.Duration
+	HideChat bool
+	queue    chan wsDelayedMessage
+	done     chan struct{}
+}
+
+type wsDelayedMessage struct {
+	at   time.Time
+	resp types.WsResponse
 }
 
 type WsHub struct {
@@ -49,6 +69,8 @@ type WsHub struct {
 	userConns map[int64]map[*websocket.Conn]struct{}
 	roomConns map[int64]map[*websocket.Conn]struct{}
 	handlers  map[string]WsHandler
+	broker    atomic.Pointer[wsBroker]
+	events    *wsEventLog
 }
 
 var wsHubOnce sync.Once
@@ -74,26 +96,75 @@ func NewWsHub() *WsHub {
 		userConns: make(map[int64]map[*websocket.Conn]struct{}),
 		roomConns: make(map[int64]map[*websocket.Conn]struct{}),
 		handlers:  make(map[string]WsHandler),
+		events:    newWsEventLog(),
 	}
 	hub.RegisterHandler("ping", hub.handlePing)
+	hub.RegisterHandler("resume", hub.handleResume)
 	hub.RegisterHandler("team_room_join", hub.handleTeamRoomJoin)
 	hub.RegisterHandler("team_room_leave", hub.handleTeamRoomLeave)
 	hub.RegisterHandler("team_room_chat", hub.handleTeamRoomChat)
+	hub.RegisterHandler("team_room_chat_delete", hub.handleTeamRoomChatDelete)
+	hub.RegisterHandler("team_room_claim", hub.handleTeamRoomClaim)
+	hub.RegisterHandler("team_room_unclaim", hub.handleTeamRoomUnclaim)
+	hub.RegisterHandler("team_room_team_create", hub.handleTeamRoomTeamCreate)
+	hub.RegisterHandler("team_room_team_join", hub.handleTeamRoomTeamJoin)
+	hub.RegisterHandler("team_room_kick", hub.handleTeamRoomKick)
+	hub.RegisterHandler("team_room_transfer", hub.handleTeamRoomTransfer)
+	hub.RegisterHandler("team_room_lock", hub.handleTeamRoomLock)
+	hub.RegisterHandler("team_room_end", hub.handleTeamRoomEnd)
+	hub.RegisterHandler("team_room_pause", hub.handleTeamRoomPause)
+	hub.RegisterHandler("team_room_resume", hub.handleTeamRoomResume)
+	hub.RegisterHandler("team_room_extend", hub.handleTeamRoomExtend)
+	hub.RegisterHandler("team_room_ready", hub.handleTeamRoomReady)
+	hub.RegisterHandler("team_room_start", hub.handleTeamRoomStart)
+	hub.RegisterHandler("team_room_spectate", hub.handleTeamRoomSpectate)
 	return hub
 }
 
+// resolveRoomID 未传房间ID时使用连接绑定的房间
+func (ctx *WsContext) resolveRoomID(roomIDStr string) (int64, error) {
+	if roomIDStr == "" && ctx.RootID > 0 {
+		roomIDStr = strconv.FormatInt(ctx.RootID, 10)
+	}
+	roomID, err := parseTeamRoomID(roomIDStr)
+	if err != nil {
+		return 0, errors.New("param blank")
+	}
+	return roomID, nil
+}
+
 func (h *WsHub) RegisterHandler(msgType string, handler WsHandler) {
 	h.handlers[msgType] = handler
 }
 
-func (h *WsHub) Serve(ctx context.Context, w http.ResponseWriter, r *http.Request, userID int64, rootID int64) error {
+// Serve spectate 为 true 时以观战身份绑定 rootID 对应的房间，version 为客户端请求的协议版本；
+// 连接先不绑定房间，加入或观战校验通过后才绑定
+func (h *WsHub) Serve(ctx context.Context, w http.ResponseWriter, r *http.Request, userID int64, role int, rootID int64, version int, invite types.TeamRoomInviteCredential, spectate bool) error {
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return err
 	}
-	h.register(conn, userID, rootID)
+	version = negotiateWsVersion(version)
+	h.register(conn, userID, role, version)
+	if version >= 2 {
+		_ = h.Send(conn, types.WsResponse{
+			Type:    "hello",
+			Code:    response.SUCCESS.Code,
+			Message: response.SUCCESS.Msg,
+			Data: types.WsHelloData{
+				Version:    version,
+				MinVersion: wsProtocolMinVersion,
+				MaxVersion: wsProtocolVersion,
+				ServerTime: time.Now().Unix(),
+			},
+		})
+	}
 	if userID > 0 && rootID > 0 {
-		if err := h.autoJoinTeamRoom(ctx, conn, userID, rootID); err != nil {
+		if spectate {
+			if err := h.spectateTeamRoom(ctx, conn, userID, rootID, invite); err != nil {
+				zlog.CtxWarnf(ctx, "websocket观战团队房间失败:%v", err)
+			}
+		} else if err := h.autoJoinTeamRoom(ctx, conn, userID, rootID, invite); err != nil {
 			zlog.CtxWarnf(ctx, "websocket自动加入团队房间失败:%v", err)
 		}
 	}
@@ -102,20 +173,15 @@ func (h *WsHub) Serve(ctx context.Context, w http.ResponseWriter, r *http.Reques
 	return nil
 }
 
-func (h *WsHub) register(conn *websocket.Conn, userID int64, rootID int64) {
+func (h *WsHub) register(conn *websocket.Conn, userID int64, role int, version int) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.connInfo[conn] = &wsConnInfo{UserID: userID, RootID: rootID}
+	h.connInfo[conn] = &wsConnInfo{UserID: userID, Role: role, Version: version}
 	if _, ok := h.userConns[userID]; !ok {
 		h.userConns[userID] = make(map[*websocket.Conn]struct{})
 	}
 	h.userConns[userID][conn] = struct{}{}
-	if rootID > 0 {
-		if _, ok := h.roomConns[rootID]; !ok {
-			h.roomConns[rootID] = make(map[*websocket.Conn]struct{})
-		}
-		h.roomConns[rootID][conn] = struct{}{}
-	}
+	h.markPresenceDirty()
 }
 
 func (h *WsHub) BindRoom(conn *websocket.Conn, rootID int64) {
@@ -128,6 +194,33 @@ func (h *WsHub) BindRoom(conn *websocket.Conn, rootID int64) {
 	if !ok {
 		return
 	}
+	h.stopSpectator(info)
+	h.bindRoomLocked(conn, info, rootID)
+}
+
+// BindSpectator 以观战身份绑定房间，delay 大于0时推送按延迟发送
+func (h *WsHub) BindSpectator(conn *websocket.Conn, rootID int64, delay time.Duration, hideChat bool) {
+	if rootID <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	info, ok := h.connInfo[conn]
+	if !ok {
+		return
+	}
+	h.stopSpectator(info)
+	h.bindRoomLocked(conn, info, rootID)
+	spectator := &wsSpectator{Delay: delay, HideChat: hideChat}
+	if delay > 0 {
+		spectator.queue = make(chan wsDelayedMessage, wsSpectatorQueueSize)
+		spectator.done = make(chan struct{})
+		go h.runSpectatorQueue(conn, spectator)
+	}
+	info.Spectator = spectator
+}
+
+func (h *WsHub) bindRoomLocked(conn *websocket.Conn, info *wsConnInfo, rootID int64) {
 	if info.RootID == rootID {
 		return
 	}
@@ -144,6 +237,7 @@ func (h *WsHub) BindRoom(conn *websocket.Conn, rootID int64) {
 		h.roomConns[rootID] = make(map[*websocket.Conn]struct{})
 	}
 	h.roomConns[rootID][conn] = struct{}{}
+	h.markPresenceDirty()
 }
 
 func (h *WsHub) UnbindRoom(conn *websocket.Conn, rootID int64) {
@@ -167,9 +261,43 @@ func (h *WsHub) UnbindRoom(conn *websocket.Conn, rootID int64) {
 	}
 	if info.RootID == rootID {
 		info.RootID = 0
+		h.stopSpectator(info)
+	}
+	h.markPresenceDirty()
+}
+
+// UnbindUserRoom 解除用户所有连接与房间的绑定，用于踢出玩家，其他实例上的连接通过控制频道解除
+func (h *WsHub) UnbindUserRoom(userID int64, rootID int64) {
+	h.unbindLocalUserRoom(userID, rootID)
+	if broker := h.broker.Load(); broker != nil {
+		broker.publishMessage(REDIS_WS_CHANNEL_CONTROL, wsBrokerMessage{
+			RootID: rootID,
+			UserID: userID,
+			Action: wsBrokerActionUnbindRoom,
+		})
 	}
 }
 
+func (h *WsHub) unbindLocalUserRoom(userID int64, rootID int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	roomSet, ok := h.roomConns[rootID]
+	if !ok {
+		return
+	}
+	for conn := range h.userConns[userID] {
+		delete(roomSet, conn)
+		if info, ok := h.connInfo[conn]; ok && info.RootID == rootID {
+			info.RootID = 0
+			h.stopSpectator(info)
+		}
+	}
+	if len(roomSet) == 0 {
+		delete(h.roomConns, rootID)
+	}
+	h.markPresenceDirty()
+}
+
 func (h *WsHub) unregister(conn *websocket.Conn) {
 	h.mu.Lock()
 	info, ok := h.connInfo[conn]
@@ -179,6 +307,8 @@ func (h *WsHub) unregister(conn *websocket.Conn) {
 	}
 	userID := info.UserID
 	rootID := info.RootID
+	spectator := info.Spectator != nil
+	h.stopSpectator(info)
 	delete(h.connInfo, conn)
 	if userSet, ok := h.userConns[info.UserID]; ok {
 		delete(userSet, conn)
@@ -195,10 +325,12 @@ func (h *WsHub) unregister(conn *websocket.Conn) {
 		}
 	}
 	h.mu.Unlock()
-	if userID > 0 && rootID > 0 {
-		if err := h.autoLeaveTeamRoom(context.Background(), conn, userID, rootID); err != nil {
-			zlog.Warnf("websocket自动离开团队房间失败:%v", err)
-		}
+	h.markPresenceDirty()
+	// 可能在房间worker广播失败时被调用，异步处理避免重入房间锁
+	if spectator && rootID > 0 {
+		go broadcastTeamRoomSpectators(rootID)
+	} else if userID > 0 && rootID > 0 {
+		go h.handleRoomDisconnect(userID, rootID)
 	}
 	_ = conn.Close()
 }
@@ -210,6 +342,58 @@ func (h *WsHub) getInfo(conn *websocket.Conn) (*wsConnInfo, bool) {
 	return info, ok
 }
 
+func (h *WsHub) getSpectator(conn *websocket.Conn) *wsSpectator {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if info, ok := h.connInfo[conn]; ok {
+		return info.Spectator
+	}
+	return nil
+}
+
+// stopSpectator 需在持有 h.mu 时调用
+func (h *WsHub) stopSpectator(info *wsConnInfo) {
+	if info.Spectator == nil {
+		return
+	}
+	if info.Spectator.done != nil {
+		close(info.Spectator.done)
+	}
+	info.Spectator = nil
+}
+
+func (h *WsHub) runSpectatorQueue(conn *websocket.Conn, spectator *wsSpectator) {
+	for {
+		select {
+		case msg := <-spectator.queue:
+			if wait := time.Until(msg.at); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-spectator.done:
+					timer.Stop()
+					return
+				}
+			}
+			if err := h.Send(conn, msg.resp); err != nil {
+				h.unregister(conn)
+				return
+			}
+		case <-spectator.done:
+			return
+		}
+	}
+}
+
+func (s *wsSpectator) enqueue(resp types.WsResponse) {
+	select {
+	case <-s.done:
+	case s.queue <- wsDelayedMessage{at: time.Now().Add(s.Delay), resp: resp}:
+	default:
+		// 队列已满时丢弃，避免阻塞房间广播
+	}
+}
+
 func (h *WsHub) readLoop(ctx context.Context, conn *websocket.Conn) {
 	conn.SetReadLimit(wsReadLimit)
 	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
@@ -242,18 +426,22 @@ func (h *WsHub) handleMessage(ctx context.Context, conn *websocket.Conn, message
 	var req types.WsRequest
 	if err := json.Unmarshal(message, &req); err != nil || req.Type == "" {
 		_ = h.Send(conn, types.WsResponse{
+			ID:      req.ID,
 			Type:    "error",
 			Code:    response.PARAM_NOT_VALID.Code,
 			Message: "消息格式错误",
+			Data:    types.WsErrorData{Type: req.Type},
 		})
 		return
 	}
 	handler, ok := h.handlers[req.Type]
 	if !ok {
 		_ = h.Send(conn, types.WsResponse{
+			ID:      req.ID,
 			Type:    "error",
 			Code:    response.MESSAGE_NOT_EXIST.Code,
 			Message: "消息类型不存在",
+			Data:    types.WsErrorData{Type: req.Type},
 		})
 		return
 	}
@@ -262,28 +450,35 @@ func (h *WsHub) handleMessage(ctx context.Context, conn *websocket.Conn, message
 		return
 	}
 	err := handler(&WsContext{
-		Ctx:    ctx,
-		Conn:   conn,
-		UserID: info.UserID,
-		RootID: info.RootID,
-		Hub:    h,
+		Ctx:       ctx,
+		Conn:      conn,
+		UserID:    info.UserID,
+		Role:      info.Role,
+		RootID:    info.RootID,
+		Version:   info.Version,
+		RequestID: req.ID,
+		Hub:       h,
 	}, req.Data)
 	if err != nil {
-		_ = h.Send(conn, types.WsResponse{
-			Type:    "error",
-			Code:    response.COMMON_FAIL.Code,
-			Message: err.Error(),
-		})
+		_ = h.Send(conn, buildWsErrorResp(req, err))
+		return
+	}
+	if _, ok := wsDirectReplyTypes[req.Type]; ok {
+		return
+	}
+	if req.ID != "" && info.Version >= 2 {
+		_ = h.Send(conn, buildWsAckResp(req))
 	}
 }
 
 func (h *WsHub) handlePing(ctx *WsContext, data json.RawMessage) error {
 	return h.Send(ctx.Conn, types.WsResponse{
+		ID:      ctx.RequestID,
 		Type:    "pong",
 		Code:    response.SUCCESS.Code,
 		Message: response.SUCCESS.Msg,
-		Data: map[string]int64{
-			"ts": time.Now().Unix(),
+		Data: types.WsPongData{
+			Ts: time.Now().Unix(),
 		},
 	})
 }
@@ -293,46 +488,182 @@ func (h *WsHub) handleTeamRoomJoin(ctx *WsContext, data json.RawMessage) error {
 	if err := json.Unmarshal(data, &req); err != nil {
 		return errors.New("param blank")
 	}
-	roomIDStr := req.RoomID
-	if roomIDStr == "" && ctx.RootID > 0 {
-		roomIDStr = strconv.FormatInt(ctx.RootID, 10)
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
 	}
-	roomID, err := parseTeamRoomID(roomIDStr)
+	return h.autoJoinTeamRoom(ctx.Ctx, ctx.Conn, ctx.UserID, roomID, req.TeamRoomInviteCredential)
+}
+
+func (h *WsHub) handleTeamRoomLeave(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsLeaveReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	if h.getSpectator(ctx.Conn) != nil {
+		h.UnbindRoom(ctx.Conn, roomID)
+		broadcastTeamRoomSpectators(roomID)
+		return nil
+	}
+	roomInfo, err := NewTeamRoomLogic().LeaveRoom(ctx.Ctx, ctx.UserID, roomID)
 	if err != nil {
+		return err
+	}
+	h.SendToRoom(roomID, types.WsResponse{
+		Type:    "team_room_member_update",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomMemberUpdateData{
+			Room:   roomInfo,
+			Action: "leave",
+			UserID: ctx.UserID,
+		},
+	})
+	h.UnbindRoom(ctx.Conn, roomID)
+	return nil
+}
+
+func (h *WsHub) handleTeamRoomChat(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsChatReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	spectator := h.getSpectator(ctx.Conn)
+	if spectator != nil && spectator.HideChat {
+		return response.ErrResp(errors.New("chat disabled for spectators"), response.PERMISSION_DENIED)
+	}
+	message, err := NewTeamRoomLogic().SendChat(ctx.Ctx, ctx.UserID, roomID, req.Content)
+	if err != nil {
+		return err
+	}
+	// 公开房间中非成员也能发言，只有成员才绑定为玩家连接
+	if spectator == nil && isTeamRoomMember(roomID, ctx.UserID) {
+		h.BindRoom(ctx.Conn, roomID)
+	}
+	h.SendToRoom(roomID, types.WsResponse{
+		Type:    "team_room_chat",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data:    message,
+	})
+	return nil
+}
+
+func (h *WsHub) handleTeamRoomChatDelete(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsChatDeleteReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	messageID, err := parseTeamRoomID(req.MessageID)
+	if err != nil {
+		return errors.New("param blank")
+	}
+	return NewTeamRoomLogic().DeleteChat(ctx.Ctx, ctx.UserID, ctx.Role, roomID, messageID)
+}
+
+// handleTeamRoomClaim 已认领时再次发送用于更新状态
+func (h *WsHub) handleTeamRoomClaim(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsClaimReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	return NewTeamRoomLogic().ClaimProblem(ctx.Ctx, ctx.UserID, roomID, req.ProblemID, req.State)
+}
+
+func (h *WsHub) handleTeamRoomUnclaim(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsClaimReq
+	if err := json.Unmarshal(data, &req); err != nil {
 		return errors.New("param blank")
 	}
-	roomInfo, err := NewTeamRoomLogic().JoinRoom(ctx.Ctx, ctx.UserID, roomID)
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	return NewTeamRoomLogic().UnclaimProblem(ctx.Ctx, ctx.UserID, roomID, req.ProblemID)
+}
+
+func (h *WsHub) handleTeamRoomTeamCreate(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsTeamCreateReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	roomInfo, err := NewTeamRoomLogic().CreateTeam(ctx.Ctx, ctx.UserID, roomID, req.Name)
 	if err != nil {
 		return err
 	}
-	h.BindRoom(ctx.Conn, roomID)
 	h.SendToRoom(roomID, types.WsResponse{
 		Type:    "team_room_member_update",
 		Code:    response.SUCCESS.Code,
 		Message: response.SUCCESS.Msg,
-		Data: map[string]interface{}{
-			"room":    roomInfo,
-			"action":  "join",
-			"user_id": strconv.FormatInt(ctx.UserID, 10),
+		Data: types.TeamRoomMemberUpdateData{
+			Room:   roomInfo,
+			Action: "team_create",
+			UserID: ctx.UserID,
 		},
 	})
 	return nil
 }
 
-func (h *WsHub) handleTeamRoomLeave(ctx *WsContext, data json.RawMessage) error {
-	var req types.TeamRoomWsLeaveReq
+func (h *WsHub) handleTeamRoomTeamJoin(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsTeamJoinReq
 	if err := json.Unmarshal(data, &req); err != nil {
 		return errors.New("param blank")
 	}
-	roomIDStr := req.RoomID
-	if roomIDStr == "" && ctx.RootID > 0 {
-		roomIDStr = strconv.FormatInt(ctx.RootID, 10)
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
 	}
-	roomID, err := parseTeamRoomID(roomIDStr)
+	roomInfo, err := NewTeamRoomLogic().JoinTeam(ctx.Ctx, ctx.UserID, roomID, req.TeamID)
 	if err != nil {
+		return err
+	}
+	h.SendToRoom(roomID, types.WsResponse{
+		Type:    "team_room_member_update",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomMemberUpdateData{
+			Room:   roomInfo,
+			Action: "team_join",
+			UserID: ctx.UserID,
+		},
+	})
+	return nil
+}
+
+func (h *WsHub) handleTeamRoomKick(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsKickReq
+	if err := json.Unmarshal(data, &req); err != nil {
 		return errors.New("param blank")
 	}
-	roomInfo, err := NewTeamRoomLogic().LeaveRoom(ctx.Ctx, ctx.UserID, roomID)
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	targetID, err := strconv.ParseInt(req.UserID, 10, 64)
+	if err != nil {
+		return errors.New("param blank")
+	}
+	roomInfo, err := NewTeamRoomLogic().KickPlayer(ctx.Ctx, ctx.UserID, roomID, targetID)
 	if err != nil {
 		return err
 	}
@@ -340,91 +671,301 @@ func (h *WsHub) handleTeamRoomLeave(ctx *WsContext, data json.RawMessage) error
 		Type:    "team_room_member_update",
 		Code:    response.SUCCESS.Code,
 		Message: response.SUCCESS.Msg,
-		Data: map[string]interface{}{
-			"room":    roomInfo,
-			"action":  "leave",
-			"user_id": strconv.FormatInt(ctx.UserID, 10),
+		Data: types.TeamRoomMemberUpdateData{
+			Room:     roomInfo,
+			Action:   "kick",
+			UserID:   targetID,
+			Operator: ctx.UserID,
 		},
 	})
-	h.UnbindRoom(ctx.Conn, roomID)
+	h.UnbindUserRoom(targetID, roomID)
 	return nil
 }
 
-func (h *WsHub) handleTeamRoomChat(ctx *WsContext, data json.RawMessage) error {
-	var req types.TeamRoomWsChatReq
+func (h *WsHub) handleTeamRoomTransfer(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsTransferReq
 	if err := json.Unmarshal(data, &req); err != nil {
 		return errors.New("param blank")
 	}
-	content := strings.TrimSpace(req.Content)
-	if content == "" {
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	targetID, err := strconv.ParseInt(req.UserID, 10, 64)
+	if err != nil {
 		return errors.New("param blank")
 	}
-	roomIDStr := req.RoomID
-	if roomIDStr == "" && ctx.RootID > 0 {
-		roomIDStr = strconv.FormatInt(ctx.RootID, 10)
+	roomInfo, err := NewTeamRoomLogic().TransferOwner(ctx.Ctx, ctx.UserID, roomID, targetID)
+	if err != nil {
+		return err
 	}
-	roomID, err := parseTeamRoomID(roomIDStr)
+	h.SendToRoom(roomID, types.WsResponse{
+		Type:    "team_room_member_update",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomMemberUpdateData{
+			Room:     roomInfo,
+			Action:   "transfer",
+			UserID:   targetID,
+			Operator: ctx.UserID,
+		},
+	})
+	return nil
+}
+
+func (h *WsHub) handleTeamRoomLock(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsLockReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	roomInfo, err := NewTeamRoomLogic().LockRoom(ctx.Ctx, ctx.UserID, roomID, req.Locked)
+	if err != nil {
+		return err
+	}
+	action := "lock"
+	if !req.Locked {
+		action = "unlock"
+	}
+	h.SendToRoom(roomID, types.WsResponse{
+		Type:    "team_room_member_update",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomMemberUpdateData{
+			Room:   roomInfo,
+			Action: action,
+			UserID: ctx.UserID,
+		},
+	})
+	return nil
+}
+
+// handleTeamRoomPause 暂停、恢复和延长的结果由 team_room_timer 广播给房间
+func (h *WsHub) handleTeamRoomPause(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsPauseReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	_, err = NewTeamRoomLogic().PauseRoom(ctx.Ctx, ctx.UserID, ctx.Role, roomID)
+	return err
+}
+
+func (h *WsHub) handleTeamRoomResume(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsPauseReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	_, err = NewTeamRoomLogic().ResumeRoom(ctx.Ctx, ctx.UserID, ctx.Role, roomID)
+	return err
+}
+
+func (h *WsHub) handleTeamRoomExtend(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsExtendReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	roomID, err := ctx.resolveRoomID(req.RoomID)
 	if err != nil {
+		return err
+	}
+	_, err = NewTeamRoomLogic().ExtendRoom(ctx.Ctx, ctx.UserID, ctx.Role, roomID, req.Seconds)
+	return err
+}
+
+func (h *WsHub) handleTeamRoomEnd(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsEndReq
+	if err := json.Unmarshal(data, &req); err != nil {
 		return errors.New("param blank")
 	}
-	user, err := repo.NewUserRepo(global.DB).GetByID(ctx.UserID)
+	roomID, err := ctx.resolveRoomID(req.RoomID)
 	if err != nil {
-		return response.ErrResp(err, response.MEMBER_NOT_EXIST)
+		return err
+	}
+	roomInfo, err := NewTeamRoomLogic().EndRoom(ctx.Ctx, ctx.UserID, roomID)
+	if err != nil {
+		return err
 	}
-	h.BindRoom(ctx.Conn, roomID)
 	h.SendToRoom(roomID, types.WsResponse{
-		Type:    "team_room_chat",
+		Type:    "team_room_member_update",
 		Code:    response.SUCCESS.Code,
 		Message: response.SUCCESS.Msg,
-		Data: map[string]interface{}{
-			"room_id":  roomID,
-			"user_id":  strconv.FormatInt(user.ID, 10),
-			"username": user.Username,
-			"content":  content,
-			"ts":       time.Now().Unix(),
+		Data: types.TeamRoomMemberUpdateData{
+			Room:   roomInfo,
+			Action: "end",
+			UserID: ctx.UserID,
 		},
 	})
 	return nil
 }
 
-func (h *WsHub) autoJoinTeamRoom(ctx context.Context, conn *websocket.Conn, userID int64, roomID int64) error {
-	roomInfo, err := NewTeamRoomLogic().JoinRoom(ctx, userID, roomID)
+func (h *WsHub) handleTeamRoomReady(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsReadyReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	roomID, err := ctx.resolveRoomID(req.RoomID)
 	if err != nil {
 		return err
 	}
-	h.BindRoom(conn, roomID)
+	roomInfo, err := NewTeamRoomLogic().SetReady(ctx.Ctx, ctx.UserID, roomID, req.Ready)
+	if err != nil {
+		return err
+	}
+	action := "ready"
+	if !req.Ready {
+		action = "unready"
+	}
 	h.SendToRoom(roomID, types.WsResponse{
 		Type:    "team_room_member_update",
 		Code:    response.SUCCESS.Code,
 		Message: response.SUCCESS.Msg,
-		Data: map[string]interface{}{
-			"room":    roomInfo,
-			"action":  "join",
-			"user_id": strconv.FormatInt(userID, 10),
+		Data: types.TeamRoomMemberUpdateData{
+			Room:   roomInfo,
+			Action: action,
+			UserID: ctx.UserID,
+		},
+	})
+	return nil
+}
+
+func (h *WsHub) handleTeamRoomStart(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsStartReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	roomInfo, err := NewTeamRoomLogic().StartNow(ctx.Ctx, ctx.UserID, roomID)
+	if err != nil {
+		return err
+	}
+	h.SendToRoom(roomID, types.WsResponse{
+		Type:    "team_room_member_update",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomMemberUpdateData{
+			Room:   roomInfo,
+			Action: "start",
+			UserID: ctx.UserID,
+		},
+	})
+	return nil
+}
+
+func (h *WsHub) handleTeamRoomSpectate(ctx *WsContext, data json.RawMessage) error {
+	var req types.TeamRoomWsJoinReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	roomID, err := ctx.resolveRoomID(req.RoomID)
+	if err != nil {
+		return err
+	}
+	return h.spectateTeamRoom(ctx.Ctx, ctx.Conn, ctx.UserID, roomID, req.TeamRoomInviteCredential)
+}
+
+func (h *WsHub) spectateTeamRoom(ctx context.Context, conn *websocket.Conn, userID int64, roomID int64, invite types.TeamRoomInviteCredential) error {
+	roomInfo, err := NewTeamRoomLogic().SpectateRoom(ctx, userID, roomID, invite)
+	if err != nil {
+		return err
+	}
+	h.BindSpectator(conn, roomID, time.Duration(roomInfo.SpectatorDelay)*time.Second, roomInfo.SpectatorHideChat)
+	h.sendToRoomConn(conn, types.WsResponse{
+		Type:    "team_room_spectate",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomSpectateData{
+			Room: roomInfo,
 		},
 	})
+	if !roomInfo.SpectatorHideChat {
+		h.sendTeamRoomChatHistory(ctx, conn, roomID)
+	}
+	broadcastTeamRoomSpectators(roomID)
 	return nil
 }
 
-func (h *WsHub) autoLeaveTeamRoom(ctx context.Context, conn *websocket.Conn, userID int64, roomID int64) error {
-	roomInfo, err := NewTeamRoomLogic().LeaveRoom(ctx, userID, roomID)
+// autoJoinTeamRoom 已是成员的玩家重连时只更新在线状态，不再广播加入
+func (h *WsHub) autoJoinTeamRoom(ctx context.Context, conn *websocket.Conn, userID int64, roomID int64, invite types.TeamRoomInviteCredential) error {
+	member := isTeamRoomMember(roomID, userID)
+	roomInfo, err := NewTeamRoomLogic().JoinRoom(ctx, userID, roomID, invite)
 	if err != nil {
 		return err
 	}
-	h.UnbindRoom(conn, roomID)
+	h.BindRoom(conn, roomID)
+	markTeamRoomOnline(roomID, userID)
+	h.sendTeamRoomChatHistory(ctx, conn, roomID)
+	if member {
+		return nil
+	}
 	h.SendToRoom(roomID, types.WsResponse{
 		Type:    "team_room_member_update",
 		Code:    response.SUCCESS.Code,
 		Message: response.SUCCESS.Msg,
-		Data: map[string]interface{}{
-			"room":    roomInfo,
-			"action":  "leave",
-			"user_id": strconv.FormatInt(userID, 10),
+		Data: types.TeamRoomMemberUpdateData{
+			Room:   roomInfo,
+			Action: "join",
+			UserID: userID,
 		},
 	})
 	return nil
 }
 
+// sendTeamRoomChatHistory 加入或观战时推送最近的聊天记录
+func (h *WsHub) sendTeamRoomChatHistory(ctx context.Context, conn *websocket.Conn, roomID int64) {
+	messages, hasMore, err := listTeamRoomChat(roomID, 0, getTeamRoomChatHistorySize())
+	if err != nil {
+		zlog.CtxWarnf(ctx, "加载团队房间聊天记录失败:%v", err)
+		return
+	}
+	h.sendToRoomConn(conn, types.WsResponse{
+		Type:    "team_room_chat_history",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data: types.TeamRoomChatHistoryResp{
+			Messages: messages,
+			HasMore:  hasMore,
+		},
+	})
+}
+
+// handleRoomDisconnect 玩家在房间内的连接全部断开后进入重连宽限期，成员身份保留
+func (h *WsHub) handleRoomDisconnect(userID int64, rootID int64) {
+	if h.hasUserRoomConnection(userID, rootID) {
+		return
+	}
+	markTeamRoomAway(rootID, userID)
+}
+
+// hasUserRoomConnection 开启跨实例推送时同时检查其他实例的在线快照
+func (h *WsHub) hasUserRoomConnection(userID int64, rootID int64) bool {
+	h.mu.RLock()
+	for conn := range h.userConns[userID] {
+		if info, ok := h.connInfo[conn]; ok && info.RootID == rootID && info.Spectator == nil {
+			h.mu.RUnlock()
+			return true
+		}
+	}
+	h.mu.RUnlock()
+	if broker := h.broker.Load(); broker != nil {
+		return broker.hasRemoteRoomPlayer(rootID, userID)
+	}
+	return false
+}
+
 func (h *WsHub) Send(conn *websocket.Conn, resp types.WsResponse) error {
 	payload, err := json.Marshal(resp)
 	if err != nil {
@@ -433,7 +974,65 @@ func (h *WsHub) Send(conn *websocket.Conn, resp types.WsResponse) error {
 	return h.writeMessage(conn, websocket.TextMessage, payload)
 }
 
+// SendToUser 先推送本实例的连接，开启广播时再发布给其他实例
 func (h *WsHub) SendToUser(userID int64, resp types.WsResponse) {
+	resp = h.recordUserEvent(userID, resp)
+	h.sendToLocalUser(userID, resp)
+	if broker := h.broker.Load(); broker != nil {
+		broker.publish(REDIS_WS_CHANNEL_USER, wsBrokerMessage{UserID: userID}, resp)
+	}
+}
+
+func (h *WsHub) SendToRoom(rootID int64, resp types.WsResponse) {
+	resp = h.recordRoomEvent(rootID, resp)
+	h.sendToLocalRoom(rootID, 0, resp)
+	if broker := h.broker.Load(); broker != nil {
+		broker.publish(REDIS_WS_CHANNEL_ROOM, wsBrokerMessage{RootID: rootID}, resp)
+	}
+}
+
+// SendToRoomUsers 按连接所属用户分别生成推送内容，其他实例上的用户按在线快照逐个发布；
+// build 只在调用时执行，生成的内容按用户保存用于补发
+func (h *WsHub) SendToRoomUsers(rootID int64, build func(userID int64) types.WsResponse) {
+	seq := h.nextEventSeq(wsStreamKey{Room: true, ID: rootID})
+	views := make(map[int64]types.WsResponse)
+	view := func(userID int64) types.WsResponse {
+		if resp, ok := views[userID]; ok {
+			return resp
+		}
+		resp := build(userID)
+		resp.RoomSeq = seq
+		views[userID] = resp
+		return resp
+	}
+	conns := h.getRoomConnections(rootID)
+	connUsers := make(map[*websocket.Conn]int64, len(conns))
+	for _, conn := range conns {
+		info, ok := h.getInfo(conn)
+		if !ok {
+			continue
+		}
+		connUsers[conn] = info.UserID
+		view(info.UserID)
+	}
+	broker := h.broker.Load()
+	var remoteIDs []int64
+	if broker != nil {
+		remoteIDs = broker.remoteRoomViewerIDs(rootID)
+		for _, userID := range remoteIDs {
+			view(userID)
+		}
+	}
+	h.recordRoomViewsEvent(rootID, seq, views)
+	for conn, userID := range connUsers {
+		h.sendToRoomConn(conn, views[userID])
+	}
+	for _, userID := range remoteIDs {
+		broker.publish(REDIS_WS_CHANNEL_ROOM, wsBrokerMessage{RootID: rootID, UserID: userID}, views[userID])
+	}
+}
+
+func (h *WsHub) sendToLocalUser(userID int64, resp types.WsResponse) {
 	conns := h.getUserConnections(userID)
 	for _, conn := range conns {
 		if err := h.Send(conn, resp); err != nil {
@@ -442,12 +1041,33 @@ func (h *WsHub) SendToUser(userID int64, resp types.WsResponse) {
 	}
 }
 
-func (h *WsHub) SendToRoom(rootID int64, resp types.WsResponse) {
+// sendToLocalRoom userID 非0时只推送该用户在房间内的连接
+func (h *WsHub) sendToLocalRoom(rootID int64, userID int64, resp types.WsResponse) {
 	conns := h.getRoomConnections(rootID)
 	for _, conn := range conns {
-		if err := h.Send(conn, resp); err != nil {
-			h.unregister(conn)
+		if userID > 0 {
+			info, ok := h.getInfo(conn)
+			if !ok || info.UserID != userID {
+				continue
+			}
 		}
+		h.sendToRoomConn(conn, resp)
+	}
+}
+
+// sendToRoomConn 观战连接按设置过滤聊天，有延迟时放入队列
+func (h *WsHub) sendToRoomConn(conn *websocket.Conn, resp types.WsResponse) {
+	if spectator := h.getSpectator(conn); spectator != nil {
+		if spectator.HideChat && strings.HasPrefix(resp.Type, "team_room_chat") {
+			return
+		}
+		if spectator.Delay > 0 {
+			spectator.enqueue(resp)
+			return
+		}
+	}
+	if err := h.Send(conn, resp); err != nil {
+		h.unregister(conn)
 	}
 }
 
@@ -479,37 +1099,81 @@ func (h *WsHub) getRoomConnections(rootID int64) []*websocket.Conn {
 	return conns
 }
 
-func (h *WsHub) ActiveUserIDs() []int64 {
+// LocalUserIDs 只包含本实例连接的用户，CF 轮询按实例分摊，避免每个实例都轮询全部在线用户
+func (h *WsHub) LocalUserIDs() []int64 {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	if len(h.userConns) == 0 {
-		return nil
-	}
-	ids := make([]int64, 0, len(h.userConns))
+	unique := make(map[int64]struct{}, len(h.userConns))
 	for userID := range h.userConns {
-		ids = append(ids, userID)
+		unique[userID] = struct{}{}
 	}
-	return ids
+	return wsUserIDList(unique)
 }
 
-func (h *WsHub) ActiveRoomUserIDs(rootID int64) []int64 {
+// ActiveUserIDs 开启广播时合并所有实例的在线用户
+func (h *WsHub) ActiveUserIDs() []int64 {
+	unique := make(map[int64]struct{})
 	h.mu.RLock()
-	defer h.mu.RUnlock()
-	set := h.roomConns[rootID]
-	if len(set) == 0 {
-		return nil
+	for userID := range h.userConns {
+		unique[userID] = struct{}{}
+	}
+	h.mu.RUnlock()
+	if broker := h.broker.Load(); broker != nil {
+		for _, snapshot := range broker.remoteSnapshots() {
+			for _, userID := range snapshot.Users {
+				unique[userID] = struct{}{}
+			}
+		}
 	}
+	return wsUserIDList(unique)
+}
+
+func (h *WsHub) ActiveRoomUserIDs(rootID int64) []int64 {
 	unique := make(map[int64]struct{})
-	for conn := range set {
+	h.mu.RLock()
+	for conn := range h.roomConns[rootID] {
 		info, ok := h.connInfo[conn]
 		if !ok {
 			continue
 		}
-		if info.UserID == 0 {
+		// 观战者的提交不计入房间
+		if info.UserID == 0 || info.Spectator != nil {
 			continue
 		}
 		unique[info.UserID] = struct{}{}
 	}
+	h.mu.RUnlock()
+	if broker := h.broker.Load(); broker != nil {
+		for _, snapshot := range broker.remoteSnapshots() {
+			for _, userID := range snapshot.Rooms[rootID] {
+				unique[userID] = struct{}{}
+			}
+		}
+	}
+	return wsUserIDList(unique)
+}
+
+// RoomSpectatorCount 按用户去重统计观战人数
+func (h *WsHub) RoomSpectatorCount(rootID int64) int {
+	unique := make(map[int64]struct{})
+	h.mu.RLock()
+	for conn := range h.roomConns[rootID] {
+		if info, ok := h.connInfo[conn]; ok && info.Spectator != nil {
+			unique[info.UserID] = struct{}{}
+		}
+	}
+	h.mu.RUnlock()
+	if broker := h.broker.Load(); broker != nil {
+		for _, snapshot := range broker.remoteSnapshots() {
+			for _, userID := range snapshot.Spectators[rootID] {
+				unique[userID] = struct{}{}
+			}
+		}
+	}
+	return len(unique)
+}
+
+func wsUserIDList(unique map[int64]struct{}) []int64 {
 	if len(unique) == 0 {
 		return nil
 	}
@@ -0,0 +1,478 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"tgwp/global"
+	"tgwp/log/zlog"
+	"tgwp/response"
+	"tgwp/types"
+	"tgwp/utils/snowflake"
+)
+
+const (
+	REDIS_WS_CHANNEL_USER    = "ws:channel:user"
+	REDIS_WS_CHANNEL_ROOM    = "ws:channel:room"
+	REDIS_WS_CHANNEL_CONTROL = "ws:channel:control"
+	REDIS_WS_CHANNEL_CALL    = "ws:channel:call:%s"
+	REDIS_WS_INSTANCES       = "ws:instances"
+	REDIS_WS_PRESENCE        = "ws:presence:%s"
+
+	// 在线快照有变化时每秒刷新，无变化时定期续期，实例宕机后快照随过期时间失效
+	wsPresenceFlushInterval = time.Second
+	wsPresenceRenewInterval = 10 * time.Second
+	wsPresenceTTL           = 30 * time.Second
+	wsPresenceCacheTTL      = time.Second
+	wsBrokerTimeout         = 3 * time.Second
+)
+
+// wsBroker 通过 Redis 频道在多个实例间转发推送并合并在线状态，每个实例只负责本地连接。
+// 房间 worker 只运行在持有房间租约的实例上，其他实例收到的房间命令通过实例频道转发给该实例执行
+type wsBroker struct {
+	hub        *WsHub
+	instanceID string
+	pubsub     *redis.PubSub
+	dirty      int32
+	stopCh     chan struct{}
+	doneCh     chan struct{}
+
+	cacheMu sync.Mutex
+	cache   []wsPresenceSnapshot
+	cacheAt time.Time
+
+	callMu  sync.Mutex
+	pending map[string]chan wsBrokerMessage
+}
+
+// wsBrokerMessage 用户频道按 UserID 投递；房间频道 UserID 非0时只投递给该用户在房间内的连接；
+// 控制频道按 Action 在所有实例上执行连接操作，例如踢人后解除绑定，不带推送内容；
+// 实例频道用于命令调用，CallID 关联请求和回复，Body 为命令或结果，Code 和 Message 为执行失败时的错误
+type wsBrokerMessage struct {
+	Origin  string          `json:"origin"`
+	RootID  int64           `json:"root_id,omitempty"`
+	UserID  int64           `json:"user_id,omitempty"`
+	Action  string          `json:"action,omitempty"`
+	Resp    json.RawMessage `json:"resp,omitempty"`
+	CallID  string          `json:"call_id,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty"`
+	Code    int             `json:"code,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+const (
+	wsBrokerActionUnbindRoom = "unbind_room"
+	wsBrokerActionCall       = "call"
+	wsBrokerActionReply      = "reply"
+)
+
+type wsBrokerResp struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	RoomSeq int64           `json:"room_seq,omitempty"`
+	UserSeq int64           `json:"user_seq,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// wsPresenceSnapshot Rooms 为房间内的玩家，Spectators 为观战用户
+type wsPresenceSnapshot struct {
+	Users      []int64           `json:"users"`
+	Rooms      map[int64][]int64 `json:"rooms"`
+	Spectators map[int64][]int64 `json:"spectators"`
+}
+
+func wsBrokerEnabled() bool {
+	return global.Rdb != nil && global.Config != nil && global.Config.Redis.WsBroker
+}
+
+// StartWsBroker 配置开启 ws-broker 且 Redis 可用时启用跨实例推送
+func StartWsBroker() {
+	if !wsBrokerEnabled() {
+		return
+	}
+	hub := GetWsHub()
+	if hub.broker.Load() != nil {
+		return
+	}
+	instanceID := newWsInstanceID()
+	broker := &wsBroker{
+		hub:        hub,
+		instanceID: instanceID,
+		pubsub: global.Rdb.Subscribe(context.Background(), REDIS_WS_CHANNEL_USER, REDIS_WS_CHANNEL_ROOM,
+			REDIS_WS_CHANNEL_CONTROL, fmt.Sprintf(REDIS_WS_CHANNEL_CALL, instanceID)),
+		dirty:   1,
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+		pending: make(map[string]chan wsBrokerMessage),
+	}
+	hub.broker.Store(broker)
+	go broker.receiveLoop()
+	go broker.presenceLoop()
+	go runTeamRoomLeaseLoop(broker)
+	zlog.Infof("websocket跨实例推送已启用，实例：%s", broker.instanceID)
+}
+
+func StopWsBroker() {
+	hub := GetWsHub()
+	if hub.broker.Load() != nil {
+		releaseTeamRoomLeases()
+	}
+	broker := hub.broker.Swap(nil)
+	if broker == nil {
+		return
+	}
+	close(broker.stopCh)
+	<-broker.doneCh
+	_ = broker.pubsub.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), wsBrokerTimeout)
+	defer cancel()
+	pipe := global.Rdb.TxPipeline()
+	pipe.Del(ctx, fmt.Sprintf(REDIS_WS_PRESENCE, broker.instanceID))
+	pipe.SRem(ctx, REDIS_WS_INSTANCES, broker.instanceID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		zlog.Warnf("清理websocket在线快照失败：%v", err)
+	}
+}
+
+// HasLiveWsInstances 启动前检查是否有其他实例的在线快照未过期
+func HasLiveWsInstances() bool {
+	if !wsBrokerEnabled() {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsBrokerTimeout)
+	defer cancel()
+	instanceIDs, err := global.Rdb.SMembers(ctx, REDIS_WS_INSTANCES).Result()
+	if err != nil || len(instanceIDs) == 0 {
+		return false
+	}
+	keys := make([]string, 0, len(instanceIDs))
+	for _, instanceID := range instanceIDs {
+		keys = append(keys, fmt.Sprintf(REDIS_WS_PRESENCE, instanceID))
+	}
+	count, err := global.Rdb.Exists(ctx, keys...).Result()
+	return err == nil && count > 0
+}
+
+func newWsInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), snowflake.GetStringId(global.SnowflakeNode))
+}
+
+func (h *WsHub) markPresenceDirty() {
+	if broker := h.broker.Load(); broker != nil {
+		atomic.StoreInt32(&broker.dirty, 1)
+	}
+}
+
+// publish 发布失败只记录日志，本实例的连接已经推送
+func (b *wsBroker) publish(channel string, msg wsBrokerMessage, resp types.WsResponse) {
+	payload, err := json.Marshal(resp)
+	if err != nil {
+		zlog.Warnf("websocket广播消息序列化失败：%v", err)
+		return
+	}
+	msg.Resp = payload
+	b.publishMessage(channel, msg)
+}
+
+func (b *wsBroker) publishMessage(channel string, msg wsBrokerMessage) {
+	msg.Origin = b.instanceID
+	body, err := json.Marshal(msg)
+	if err != nil {
+		zlog.Warnf("websocket广播消息序列化失败：%v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsBrokerTimeout)
+	defer cancel()
+	if err := global.Rdb.Publish(ctx, channel, body).Err(); err != nil {
+		zlog.Warnf("websocket广播消息发布失败：%v", err)
+	}
+}
+
+func (b *wsBroker) receiveLoop() {
+	ch := b.pubsub.Channel()
+	for msg := range ch {
+		var brokerMsg wsBrokerMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &brokerMsg); err != nil {
+			zlog.Warnf("websocket广播消息解析失败：%v", err)
+			continue
+		}
+		if brokerMsg.Origin == b.instanceID {
+			continue
+		}
+		if msg.Channel == REDIS_WS_CHANNEL_CONTROL {
+			b.handleControl(brokerMsg)
+			continue
+		}
+		if brokerMsg.Action == wsBrokerActionCall {
+			// 命令可能需要等待房间锁，不能阻塞接收
+			go b.handleCall(brokerMsg)
+			continue
+		}
+		if brokerMsg.Action == wsBrokerActionReply {
+			b.handleReply(brokerMsg)
+			continue
+		}
+		var raw wsBrokerResp
+		if err := json.Unmarshal(brokerMsg.Resp, &raw); err != nil {
+			zlog.Warnf("websocket广播消息解析失败：%v", err)
+			continue
+		}
+		resp := types.WsResponse{
+			ID:      raw.ID,
+			Type:    raw.Type,
+			Code:    raw.Code,
+			Message: raw.Message,
+			RoomSeq: raw.RoomSeq,
+			UserSeq: raw.UserSeq,
+		}
+		if len(raw.Data) > 0 {
+			resp.Data = raw.Data
+		}
+		b.hub.recordBrokerEvent(msg.Channel, brokerMsg.RootID, brokerMsg.UserID, resp)
+		switch msg.Channel {
+		case REDIS_WS_CHANNEL_USER:
+			b.hub.sendToLocalUser(brokerMsg.UserID, resp)
+		case REDIS_WS_CHANNEL_ROOM:
+			b.hub.sendToLocalRoom(brokerMsg.RootID, brokerMsg.UserID, resp)
+		}
+	}
+}
+
+func (b *wsBroker) handleControl(msg wsBrokerMessage) {
+	switch msg.Action {
+	case wsBrokerActionUnbindRoom:
+		b.hub.unbindLocalUserRoom(msg.UserID, msg.RootID)
+	}
+}
+
+// call 在指定实例上执行命令并等待结果，body 为命令内容，返回执行结果
+func (b *wsBroker) call(instanceID string, body interface{}, timeout time.Duration) (json.RawMessage, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	callID := snowflake.GetStringId(global.SnowflakeNode)
+	replyCh := make(chan wsBrokerMessage, 1)
+	b.callMu.Lock()
+	b.pending[callID] = replyCh
+	b.callMu.Unlock()
+	defer func() {
+		b.callMu.Lock()
+		delete(b.pending, callID)
+		b.callMu.Unlock()
+	}()
+	b.publishMessage(fmt.Sprintf(REDIS_WS_CHANNEL_CALL, instanceID), wsBrokerMessage{
+		Action: wsBrokerActionCall,
+		CallID: callID,
+		Body:   payload,
+	})
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case reply := <-replyCh:
+		if reply.Code != 0 {
+			return nil, response.ErrResp(errors.New(reply.Message), response.MsgCode{Code: reply.Code, Msg: reply.Message})
+		}
+		return reply.Body, nil
+	case <-timer.C:
+		return nil, response.ErrResp(errors.New("call instance timeout"), response.INTERNAL_ERROR)
+	}
+}
+
+func (b *wsBroker) handleCall(msg wsBrokerMessage) {
+	reply := wsBrokerMessage{Action: wsBrokerActionReply, CallID: msg.CallID}
+	result, err := executeTeamRoomCommand(msg.Body)
+	if err != nil {
+		respErr := &response.RespError{}
+		if errors.As(err, &respErr) {
+			reply.Code, reply.Message = respErr.Code, respErr.Message
+		} else {
+			reply.Code, reply.Message = response.COMMON_FAIL.Code, err.Error()
+		}
+	} else if result != nil {
+		payload, err := json.Marshal(result)
+		if err != nil {
+			reply.Code, reply.Message = response.COMMON_FAIL.Code, err.Error()
+		}
+		reply.Body = payload
+	}
+	b.publishMessage(fmt.Sprintf(REDIS_WS_CHANNEL_CALL, msg.Origin), reply)
+}
+
+func (b *wsBroker) handleReply(msg wsBrokerMessage) {
+	b.callMu.Lock()
+	replyCh, ok := b.pending[msg.CallID]
+	b.callMu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case replyCh <- msg:
+	default:
+	}
+}
+
+func (b *wsBroker) presenceLoop() {
+	defer close(b.doneCh)
+	ticker := time.NewTicker(wsPresenceFlushInterval)
+	defer ticker.Stop()
+	var renewAt time.Time
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			if atomic.SwapInt32(&b.dirty, 0) == 0 && now.Before(renewAt) {
+				continue
+			}
+			if err := b.savePresence(); err != nil {
+				atomic.StoreInt32(&b.dirty, 1)
+				zlog.Warnf("保存websocket在线快照失败：%v", err)
+				continue
+			}
+			renewAt = now.Add(wsPresenceRenewInterval)
+		case <-b.stopCh:
+			return
+		}
+	}
+}
+
+func (b *wsBroker) savePresence() error {
+	payload, err := json.Marshal(b.hub.localPresence())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsBrokerTimeout)
+	defer cancel()
+	pipe := global.Rdb.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf(REDIS_WS_PRESENCE, b.instanceID), payload, wsPresenceTTL)
+	pipe.SAdd(ctx, REDIS_WS_INSTANCES, b.instanceID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (h *WsHub) localPresence() wsPresenceSnapshot {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	snapshot := wsPresenceSnapshot{
+		Users:      make([]int64, 0, len(h.userConns)),
+		Rooms:      make(map[int64][]int64),
+		Spectators: make(map[int64][]int64),
+	}
+	for userID := range h.userConns {
+		snapshot.Users = append(snapshot.Users, userID)
+	}
+	for rootID, set := range h.roomConns {
+		players := make(map[int64]struct{})
+		spectators := make(map[int64]struct{})
+		for conn := range set {
+			info, ok := h.connInfo[conn]
+			if !ok || info.UserID == 0 {
+				continue
+			}
+			if info.Spectator != nil {
+				spectators[info.UserID] = struct{}{}
+			} else {
+				players[info.UserID] = struct{}{}
+			}
+		}
+		if ids := wsUserIDList(players); len(ids) > 0 {
+			snapshot.Rooms[rootID] = ids
+		}
+		if ids := wsUserIDList(spectators); len(ids) > 0 {
+			snapshot.Spectators[rootID] = ids
+		}
+	}
+	return snapshot
+}
+
+// remoteSnapshots 读取其他实例的在线快照，短时间内复用缓存，快照已过期的实例从集合中移除
+func (b *wsBroker) remoteSnapshots() []wsPresenceSnapshot {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	if time.Since(b.cacheAt) < wsPresenceCacheTTL {
+		return b.cache
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsBrokerTimeout)
+	defer cancel()
+	instanceIDs, err := global.Rdb.SMembers(ctx, REDIS_WS_INSTANCES).Result()
+	if err != nil {
+		zlog.Warnf("读取websocket实例列表失败：%v", err)
+		return b.cache
+	}
+	remoteIDs := make([]string, 0, len(instanceIDs))
+	keys := make([]string, 0, len(instanceIDs))
+	for _, instanceID := range instanceIDs {
+		if instanceID == b.instanceID {
+			continue
+		}
+		remoteIDs = append(remoteIDs, instanceID)
+		keys = append(keys, fmt.Sprintf(REDIS_WS_PRESENCE, instanceID))
+	}
+	snapshots := make([]wsPresenceSnapshot, 0, len(keys))
+	if len(keys) > 0 {
+		values, err := global.Rdb.MGet(ctx, keys...).Result()
+		if err != nil {
+			zlog.Warnf("读取websocket在线快照失败：%v", err)
+			return b.cache
+		}
+		expired := make([]interface{}, 0)
+		for i, value := range values {
+			str, ok := value.(string)
+			if !ok {
+				expired = append(expired, remoteIDs[i])
+				continue
+			}
+			var snapshot wsPresenceSnapshot
+			if err := json.Unmarshal([]byte(str), &snapshot); err != nil {
+				continue
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+		if len(expired) > 0 {
+			global.Rdb.SRem(ctx, REDIS_WS_INSTANCES, expired...)
+		}
+	}
+	b.cache = snapshots
+	b.cacheAt = time.Now()
+	return snapshots
+}
+
+// hasRemoteRoomPlayer 其他实例上是否有该用户以玩家身份绑定房间的连接
+func (b *wsBroker) hasRemoteRoomPlayer(rootID int64, userID int64) bool {
+	for _, snapshot := range b.remoteSnapshots() {
+		for _, id := range snapshot.Rooms[rootID] {
+			if id == userID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// remoteRoomViewerIDs 其他实例上绑定该房间的玩家和观战用户
+func (b *wsBroker) remoteRoomViewerIDs(rootID int64) []int64 {
+	unique := make(map[int64]struct{})
+	for _, snapshot := range b.remoteSnapshots() {
+		for _, userID := range snapshot.Rooms[rootID] {
+			unique[userID] = struct{}{}
+		}
+		for _, userID := range snapshot.Spectators[rootID] {
+			unique[userID] = struct{}{}
+		}
+	}
+	return wsUserIDList(unique)
+}
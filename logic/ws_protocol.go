@@ -0,0 +1,136 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	// wsProtocolVersion 版本2起连接后推送 hello，带 id 的请求处理成功后回复 ack；版本3起支持 resume 补发
+	wsProtocolVersion    = 3
+	wsProtocolMinVersion = 1
+
+	wsDirectionClient = "client"
+	wsDirectionServer = "server"
+)
+
+// negotiateWsVersion 未指定版本的旧客户端按版本1处理，高于服务端支持的版本时降到服务端最高版本
+func negotiateWsVersion(requested int) int {
+	if requested < wsProtocolMinVersion {
+		return wsProtocolMinVersion
+	}
+	if requested > wsProtocolVersion {
+		return wsProtocolVersion
+	}
+	return requested
+}
+
+// wsDirectReplyTypes 处理函数已直接回复的请求，不再单独回复 ack
+var wsDirectReplyTypes = map[string]struct{}{
+	"ping":   {},
+	"resume": {},
+}
+
+// buildWsErrorResp 业务错误使用对应的错误码，其他错误统一为失败
+func buildWsErrorResp(req types.WsRequest, err error) types.WsResponse {
+	code := response.COMMON_FAIL.Code
+	respErr := &response.RespError{}
+	if errors.As(err, &respErr) {
+		code = respErr.Code
+	}
+	return types.WsResponse{
+		ID:      req.ID,
+		Type:    "error",
+		Code:    code,
+		Message: err.Error(),
+		Data:    types.WsErrorData{Type: req.Type},
+	}
+}
+
+func buildWsAckResp(req types.WsRequest) types.WsResponse {
+	return types.WsResponse{
+		ID:      req.ID,
+		Type:    "ack",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data:    types.WsAckData{Type: req.Type},
+	}
+}
+
+// wsCatalog 消息目录，新增消息类型时同步补充
+var wsCatalog = []types.WsCatalogItem{
+	{Type: "ping", Direction: wsDirectionClient, Description: "心跳，服务端回复 pong，不再单独回复 ack", Since: 1},
+	{Type: "resume", Direction: wsDirectionClient, Payload: "WsResumeReq", Description: "重连后按最后收到的序号补发推送，服务端回复 resume", Since: 3},
+	{Type: "team_room_join", Direction: wsDirectionClient, Payload: "TeamRoomWsJoinReq", Description: "加入团队房间", Since: 1},
+	{Type: "team_room_leave", Direction: wsDirectionClient, Payload: "TeamRoomWsLeaveReq", Description: "离开团队房间，观战连接只解除绑定", Since: 1},
+	{Type: "team_room_chat", Direction: wsDirectionClient, Payload: "TeamRoomWsChatReq", Description: "发送聊天消息", Since: 1},
+	{Type: "team_room_chat_delete", Direction: wsDirectionClient, Payload: "TeamRoomWsChatDeleteReq", Description: "房主或管理员删除聊天消息", Since: 1},
+	{Type: "team_room_claim", Direction: wsDirectionClient, Payload: "TeamRoomWsClaimReq", Description: "认领题目或更新认领状态", Since: 1},
+	{Type: "team_room_unclaim", Direction: wsDirectionClient, Payload: "TeamRoomWsClaimReq", Description: "取消认领题目", Since: 1},
+	{Type: "team_room_team_create", Direction: wsDirectionClient, Payload: "TeamRoomWsTeamCreateReq", Description: "对抗模式创建队伍", Since: 1},
+	{Type: "team_room_team_join", Direction: wsDirectionClient, Payload: "TeamRoomWsTeamJoinReq", Description: "对抗模式加入队伍", Since: 1},
+	{Type: "team_room_kick", Direction: wsDirectionClient, Payload: "TeamRoomWsKickReq", Description: "房主踢出玩家", Since: 1},
+	{Type: "team_room_transfer", Direction: wsDirectionClient, Payload: "TeamRoomWsTransferReq", Description: "房主转让房间", Since: 1},
+	{Type: "team_room_lock", Direction: wsDirectionClient, Payload: "TeamRoomWsLockReq", Description: "房主锁定或解锁房间", Since: 1},
+	{Type: "team_room_end", Direction: wsDirectionClient, Payload: "TeamRoomWsEndReq", Description: "房主提前结束比赛", Since: 1},
+	{Type: "team_room_pause", Direction: wsDirectionClient, Payload: "TeamRoomWsPauseReq", Description: "房主或管理员暂停计时", Since: 1},
+	{Type: "team_room_resume", Direction: wsDirectionClient, Payload: "TeamRoomWsPauseReq", Description: "房主或管理员恢复计时", Since: 1},
+	{Type: "team_room_extend", Direction: wsDirectionClient, Payload: "TeamRoomWsExtendReq", Description: "房主或管理员延长比赛时长", Since: 1},
+	{Type: "team_room_ready", Direction: wsDirectionClient, Payload: "TeamRoomWsReadyReq", Description: "等待阶段设置准备状态", Since: 1},
+	{Type: "team_room_start", Direction: wsDirectionClient, Payload: "TeamRoomWsStartReq", Description: "房主开始比赛", Since: 1},
+	{Type: "team_room_spectate", Direction: wsDirectionClient, Payload: "TeamRoomWsJoinReq", Description: "以观战身份进入房间", Since: 1},
+
+	{Type: "hello", Direction: wsDirectionServer, Payload: "WsHelloData", Description: "连接建立后推送协商的协议版本", Since: 2},
+	{Type: "ack", Direction: wsDirectionServer, Payload: "WsAckData", Description: "带 id 的请求处理成功", Since: 2},
+	{Type: "error", Direction: wsDirectionServer, Payload: "WsErrorData", Description: "请求处理失败，带 id 的请求会原样返回 id", Since: 1},
+	{Type: "resume", Direction: wsDirectionServer, Payload: "WsResumeData", Description: "补发完成，resync 为 true 时需要重新拉取完整数据", Since: 3},
+	{Type: "pong", Direction: wsDirectionServer, Payload: "WsPongData", Description: "心跳回复", Since: 1},
+	{Type: "room_timer", Direction: wsDirectionServer, Payload: "RoomTimerData", Description: "定期推送剩余时间", Since: 1},
+	{Type: "room_timer_warning", Direction: wsDirectionServer, Payload: "RoomTimerData", Description: "剩余15分钟提醒", Since: 1},
+	{Type: "single_room_update", Direction: wsDirectionServer, Payload: "SingleRoomUpdateData", Description: "单人房间提交结果更新", Since: 1},
+	{Type: "single_room_reroll", Direction: wsDirectionServer, Payload: "SingleRoomInfoData", Description: "单人房间换题", Since: 1},
+	{Type: "single_room_finish", Direction: wsDirectionServer, Payload: "SingleRoomInfoData", Description: "单人房间结束结算", Since: 1},
+	{Type: "achievement_unlocked", Direction: wsDirectionServer, Payload: "UserAchievementInfo", Description: "解锁成就", Since: 1},
+	{Type: "team_room_member_update", Direction: wsDirectionServer, Payload: "TeamRoomMemberUpdateData", Description: "成员、队伍或房间设置变化", Since: 1},
+	{Type: "team_room_spectate", Direction: wsDirectionServer, Payload: "TeamRoomSpectateData", Description: "观战进入成功", Since: 1},
+	{Type: "team_room_spectator_update", Direction: wsDirectionServer, Payload: "TeamRoomSpectatorUpdateData", Description: "观战人数变化", Since: 1},
+	{Type: "team_room_presence", Direction: wsDirectionServer, Payload: "TeamRoomPresenceData", Description: "成员在线状态变化", Since: 1},
+	{Type: "team_room_countdown", Direction: wsDirectionServer, Payload: "TeamRoomCountdownData", Description: "定时开始的倒计时", Since: 1},
+	{Type: "team_room_start", Direction: wsDirectionServer, Payload: "TeamRoomInfoData", Description: "比赛开始", Since: 1},
+	{Type: "team_room_update", Direction: wsDirectionServer, Payload: "TeamRoomUpdateData", Description: "提交结果和房间状态更新", Since: 1},
+	{Type: "team_room_scoreboard", Direction: wsDirectionServer, Payload: "TeamRoomScoreboardData", Description: "对抗模式榜单更新", Since: 1},
+	{Type: "team_room_freeze", Direction: wsDirectionServer, Payload: "TeamRoomFreezeData", Description: "封榜开始", Since: 1},
+	{Type: "team_room_timer", Direction: wsDirectionServer, Payload: "TeamRoomTimerInfo", Description: "暂停、恢复或延长后的计时", Since: 1},
+	{Type: "team_room_chat", Direction: wsDirectionServer, Payload: "TeamRoomChatInfo", Description: "聊天消息", Since: 1},
+	{Type: "team_room_chat_history", Direction: wsDirectionServer, Payload: "TeamRoomChatHistoryResp", Description: "进入房间时的最近聊天记录", Since: 1},
+	{Type: "team_room_chat_delete", Direction: wsDirectionServer, Payload: "TeamRoomChatDeleteInfo", Description: "聊天消息被删除", Since: 1},
+	{Type: "team_room_claim_update", Direction: wsDirectionServer, Payload: "TeamRoomClaimUpdateData", Description: "题目认领变化", Since: 1},
+	{Type: "team_room_reveal", Direction: wsDirectionServer, Payload: "TeamRoomRevealData", Description: "封榜比赛结束后的滚榜步骤", Since: 1},
+	{Type: "team_room_finish", Direction: wsDirectionServer, Payload: "TeamRoomFinishData", Description: "比赛结束", Since: 1},
+}
+
+// GetWsCatalog 客户端消息只列出已注册处理函数的类型
+func (h *WsHub) GetWsCatalog(ctx context.Context) types.WsCatalogResp {
+	_ = ctx
+	resp := types.WsCatalogResp{
+		Version:    wsProtocolVersion,
+		MinVersion: wsProtocolMinVersion,
+		Messages:   make([]types.WsCatalogItem, 0, len(wsCatalog)),
+	}
+	for _, item := range wsCatalog {
+		if item.Direction == wsDirectionClient {
+			if _, ok := h.handlers[item.Type]; !ok {
+				continue
+			}
+		}
+		resp.Messages = append(resp.Messages, item)
+	}
+	sort.SliceStable(resp.Messages, func(i, j int) bool {
+		return resp.Messages[i].Direction < resp.Messages[j].Direction
+	})
+	return resp
+}
@@ -0,0 +1,360 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"tgwp/global"
+	"tgwp/log/zlog"
+	"tgwp/response"
+	"tgwp/types"
+)
+
+const (
+	REDIS_WS_SEQ_ROOM = "ws:seq:room:%d"
+	REDIS_WS_SEQ_USER = "ws:seq:user:%d"
+
+	wsEventBufferSize  = 200
+	wsEventStreamTTL   = 10 * time.Minute
+	wsEventSweepPeriod = 1000
+	wsSeqKeyTTL        = 24 * time.Hour
+	wsSeqTimeout       = 200 * time.Millisecond
+	wsSeqRetryAfter    = 5 * time.Second
+)
+
+// wsEphemeralTypes 只反映当前状态的推送不分配序号，断线重连后不需要补发
+var wsEphemeralTypes = map[string]struct{}{
+	"room_timer":                 {},
+	"team_room_countdown":        {},
+	"team_room_spectator_update": {},
+}
+
+type wsStreamKey struct {
+	Room bool
+	ID   int64
+}
+
+func (k wsStreamKey) redisKey() string {
+	if k.Room {
+		return fmt.Sprintf(REDIS_WS_SEQ_ROOM, k.ID)
+	}
+	return fmt.Sprintf(REDIS_WS_SEQ_USER, k.ID)
+}
+
+// wsEvent resp 为所有人相同的推送，views 为按用户生成的推送在发送时的内容
+type wsEvent struct {
+	seq   int64
+	resp  *types.WsResponse
+	views map[int64]types.WsResponse
+}
+
+// wsEventStream resyncBelow 为 Redis 不可用时本地分配的最大序号，此前的推送无法保证与其他实例一致
+type wsEventStream struct {
+	next        int64
+	last        int64
+	resyncBelow int64
+	events      []wsEvent
+	touchedAt   time.Time
+}
+
+// wsEventLog 每个房间和用户各自维护递增序号和最近的推送，用于断线重连后补发
+type wsEventLog struct {
+	mu             sync.Mutex
+	streams        map[wsStreamKey]*wsEventStream
+	records        int
+	redisDownUntil time.Time
+}
+
+func newWsEventLog() *wsEventLog {
+	return &wsEventLog{streams: make(map[wsStreamKey]*wsEventStream)}
+}
+
+func (l *wsEventLog) getStreamLocked(key wsStreamKey) *wsEventStream {
+	stream, ok := l.streams[key]
+	if !ok {
+		stream = &wsEventStream{}
+		l.streams[key] = stream
+	}
+	stream.touchedAt = time.Now()
+	return stream
+}
+
+func (l *wsEventLog) nextSeq(key wsStreamKey) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stream := l.getStreamLocked(key)
+	stream.next++
+	return stream.next
+}
+
+// fallbackSeq Redis 分配失败时在本地继续递增，并要求重连的客户端重新拉取
+func (l *wsEventLog) fallbackSeq(key wsStreamKey) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stream := l.getStreamLocked(key)
+	stream.next++
+	stream.resyncBelow = stream.next
+	return stream.next
+}
+
+func (l *wsEventLog) resyncFloor(key wsStreamKey) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if stream, ok := l.streams[key]; ok {
+		return stream.resyncBelow
+	}
+	return 0
+}
+
+// redisAvailable 分配失败后一段时间内不再访问 Redis，避免每次推送都等到超时
+func (l *wsEventLog) redisAvailable() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Now().After(l.redisDownUntil)
+}
+
+func (l *wsEventLog) markRedisDown() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redisDownUntil = time.Now().Add(wsSeqRetryAfter)
+}
+
+// record 相同序号的按用户推送合并到同一事件
+func (l *wsEventLog) record(key wsStreamKey, event wsEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stream := l.getStreamLocked(key)
+	idx := sort.Search(len(stream.events), func(i int) bool {
+		return stream.events[i].seq >= event.seq
+	})
+	if idx < len(stream.events) && stream.events[idx].seq == event.seq {
+		existing := &stream.events[idx]
+		for userID, resp := range event.views {
+			if existing.views == nil {
+				existing.views = make(map[int64]types.WsResponse)
+			}
+			existing.views[userID] = resp
+		}
+	} else {
+		stream.events = append(stream.events, wsEvent{})
+		copy(stream.events[idx+1:], stream.events[idx:])
+		stream.events[idx] = event
+		if len(stream.events) > wsEventBufferSize {
+			stream.events = append([]wsEvent(nil), stream.events[len(stream.events)-wsEventBufferSize:]...)
+		}
+	}
+	if event.seq > stream.last {
+		stream.last = event.seq
+	}
+	if event.seq > stream.next {
+		stream.next = event.seq
+	}
+	l.records++
+	if l.records%wsEventSweepPeriod == 0 {
+		l.sweepLocked()
+	}
+}
+
+func (l *wsEventLog) sweepLocked() {
+	expireAt := time.Now().Add(-wsEventStreamTTL)
+	for key, stream := range l.streams {
+		if stream.touchedAt.Before(expireAt) {
+			delete(l.streams, key)
+		}
+	}
+}
+
+func (l *wsEventLog) lastSeq(key wsStreamKey) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if stream, ok := l.streams[key]; ok {
+		return stream.last
+	}
+	return 0
+}
+
+// replay 返回 after 之后的推送，缓冲区不连续或已被覆盖时返回 resync
+func (l *wsEventLog) replay(key wsStreamKey, after int64, userID int64) ([]types.WsResponse, bool) {
+	l.mu.Lock()
+	stream, ok := l.streams[key]
+	if !ok {
+		l.mu.Unlock()
+		return nil, after > 0
+	}
+	if after > stream.last || after < stream.resyncBelow {
+		l.mu.Unlock()
+		return nil, true
+	}
+	if after == stream.last {
+		l.mu.Unlock()
+		return nil, false
+	}
+	idx := sort.Search(len(stream.events), func(i int) bool {
+		return stream.events[i].seq > after
+	})
+	events := append([]wsEvent(nil), stream.events[idx:]...)
+	l.mu.Unlock()
+
+	resps := make([]types.WsResponse, 0, len(events))
+	expect := after + 1
+	for _, event := range events {
+		if event.seq != expect {
+			return nil, true
+		}
+		expect++
+		if event.resp != nil {
+			resps = append(resps, *event.resp)
+			continue
+		}
+		resp, ok := event.views[userID]
+		if !ok {
+			return nil, true
+		}
+		resps = append(resps, resp)
+	}
+	if expect <= after+1 {
+		return nil, true
+	}
+	return resps, false
+}
+
+// nextEventSeq 开启多实例推送时序号由 Redis 分配，保证各实例一致。
+// 调用方可能持有房间的锁，所以访问 Redis 使用较短的超时，失败后改为本地分配并在一段时间内不再重试，
+// 本地分配的序号会让重连的客户端重新拉取完整数据；Redis 恢复后序号从本地已分配的位置之后继续
+func (h *WsHub) nextEventSeq(key wsStreamKey) int64 {
+	if h.broker.Load() == nil {
+		return h.events.nextSeq(key)
+	}
+	if !h.events.redisAvailable() {
+		return h.events.fallbackSeq(key)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsSeqTimeout)
+	defer cancel()
+	redisKey := key.redisKey()
+	pipe := global.Rdb.TxPipeline()
+	incr := pipe.Incr(ctx, redisKey)
+	pipe.Expire(ctx, redisKey, wsSeqKeyTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		zlog.Warnf("分配websocket推送序号失败，改为本地分配：%v", err)
+		h.events.markRedisDown()
+		return h.events.fallbackSeq(key)
+	}
+	seq := incr.Val()
+	if floor := h.events.resyncFloor(key); seq <= floor {
+		next, err := global.Rdb.IncrBy(ctx, redisKey, floor-seq+1).Result()
+		if err != nil {
+			zlog.Warnf("分配websocket推送序号失败，改为本地分配：%v", err)
+			h.events.markRedisDown()
+			return h.events.fallbackSeq(key)
+		}
+		seq = next
+	}
+	return seq
+}
+
+func (h *WsHub) recordUserEvent(userID int64, resp types.WsResponse) types.WsResponse {
+	if _, ok := wsEphemeralTypes[resp.Type]; ok {
+		return resp
+	}
+	key := wsStreamKey{ID: userID}
+	seq := h.nextEventSeq(key)
+	resp.UserSeq = seq
+	stored := resp
+	h.events.record(key, wsEvent{seq: seq, resp: &stored})
+	return resp
+}
+
+func (h *WsHub) recordRoomEvent(rootID int64, resp types.WsResponse) types.WsResponse {
+	if _, ok := wsEphemeralTypes[resp.Type]; ok {
+		return resp
+	}
+	key := wsStreamKey{Room: true, ID: rootID}
+	seq := h.nextEventSeq(key)
+	resp.RoomSeq = seq
+	stored := resp
+	h.events.record(key, wsEvent{seq: seq, resp: &stored})
+	return resp
+}
+
+// recordRoomViewsEvent 按用户生成的推送共用一个房间序号，保存发送时各用户的内容，
+// 发送时不在房间内的用户补发时需要重新拉取
+func (h *WsHub) recordRoomViewsEvent(rootID int64, seq int64, views map[int64]types.WsResponse) {
+	stored := make(map[int64]types.WsResponse, len(views))
+	for userID, resp := range views {
+		stored[userID] = resp
+	}
+	h.events.record(wsStreamKey{Room: true, ID: rootID}, wsEvent{seq: seq, views: stored})
+}
+
+// recordBrokerEvent 记录其他实例发布的推送，客户端重连到本实例时也能补发
+func (h *WsHub) recordBrokerEvent(channel string, rootID int64, userID int64, resp types.WsResponse) {
+	switch {
+	case channel == REDIS_WS_CHANNEL_USER && resp.UserSeq > 0:
+		stored := resp
+		h.events.record(wsStreamKey{ID: userID}, wsEvent{seq: resp.UserSeq, resp: &stored})
+	case channel == REDIS_WS_CHANNEL_ROOM && resp.RoomSeq > 0 && userID == 0:
+		stored := resp
+		h.events.record(wsStreamKey{Room: true, ID: rootID}, wsEvent{seq: resp.RoomSeq, resp: &stored})
+	case channel == REDIS_WS_CHANNEL_ROOM && resp.RoomSeq > 0:
+		h.events.record(wsStreamKey{Room: true, ID: rootID}, wsEvent{
+			seq:   resp.RoomSeq,
+			views: map[int64]types.WsResponse{userID: resp},
+		})
+	}
+}
+
+// handleResume 客户端重连后带上最后收到的序号，补发缺失的推送，无法补发时要求客户端重新拉取
+func (h *WsHub) handleResume(ctx *WsContext, data json.RawMessage) error {
+	var req types.WsResumeReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return errors.New("param blank")
+	}
+	if req.RoomSeq < 0 || req.UserSeq < 0 {
+		return errors.New("param blank")
+	}
+	var roomID int64
+	if req.RoomID != "" || ctx.RootID > 0 {
+		id, err := ctx.resolveRoomID(req.RoomID)
+		if err != nil {
+			return err
+		}
+		if id != ctx.RootID {
+			return response.ErrResp(errors.New("connection not bound to room"), response.PERMISSION_DENIED)
+		}
+		roomID = id
+	}
+	userKey := wsStreamKey{ID: ctx.UserID}
+	result := types.WsResumeData{}
+	userResps, userResync := h.events.replay(userKey, req.UserSeq, ctx.UserID)
+	for _, resp := range userResps {
+		if err := h.Send(ctx.Conn, resp); err != nil {
+			return err
+		}
+	}
+	result.UserResync = userResync
+	result.UserSeq = h.events.lastSeq(userKey)
+	result.Replayed = len(userResps)
+	if roomID > 0 {
+		roomKey := wsStreamKey{Room: true, ID: roomID}
+		roomResps, roomResync := h.events.replay(roomKey, req.RoomSeq, ctx.UserID)
+		for _, resp := range roomResps {
+			h.sendToRoomConn(ctx.Conn, resp)
+		}
+		result.RoomID = roomID
+		result.RoomResync = roomResync
+		result.RoomSeq = h.events.lastSeq(roomKey)
+		result.Replayed += len(roomResps)
+	}
+	return h.Send(ctx.Conn, types.WsResponse{
+		ID:      ctx.RequestID,
+		Type:    "resume",
+		Code:    response.SUCCESS.Code,
+		Message: response.SUCCESS.Msg,
+		Data:    result,
+	})
+}
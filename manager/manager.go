@@ -17,19 +17,27 @@ type Middleware func() gin.HandlerFunc
 
 // RouteManager 管理不同的路由组，按业务功能分组
 type RouteManager struct {
-	LoginRoutes        *gin.RouterGroup // 登录相关的路由组
-	CommonRoutes       *gin.RouterGroup //通用功能相关的路由组
-	SinglePlayerRoutes *gin.RouterGroup //单人模式相关的路由组
-	TeamRoomRoutes     *gin.RouterGroup //团队模式相关的路由组
+	LoginRoutes          *gin.RouterGroup // 登录相关的路由组
+	CommonRoutes         *gin.RouterGroup //通用功能相关的路由组
+	SinglePlayerRoutes   *gin.RouterGroup //单人模式相关的路由组
+	TeamRoomRoutes       *gin.RouterGroup //团队模式相关的路由组
+	DailyChallengeRoutes *gin.RouterGroup //每日挑战相关的路由组
+	AchievementRoutes    *gin.RouterGroup //成就相关的路由组
+	LeaderboardRoutes    *gin.RouterGroup //排行榜相关的路由组
+	SeriesRoutes         *gin.RouterGroup //训练系列相关的路由组
 }
 
 // NewRouteManager 创建一个新的 RouteManager 实例，包含各业务功能的路由组
 func NewRouteManager(router *gin.Engine) *RouteManager {
 	return &RouteManager{
-		LoginRoutes:        router.Group("/api/login"),         // 初始化登录路由组
-		CommonRoutes:       router.Group("/api/common"),        //通用功能相关的路由组
-		SinglePlayerRoutes: router.Group("/api/single-player"), //单人模式相关的路由组
-		TeamRoomRoutes:     router.Group("/api/team-room"),     //团队模式相关的路由组
+		LoginRoutes:          router.Group("/api/login"),           // 初始化登录路由组
+		CommonRoutes:         router.Group("/api/common"),          //通用功能相关的路由组
+		SinglePlayerRoutes:   router.Group("/api/single-player"),   //单人模式相关的路由组
+		TeamRoomRoutes:       router.Group("/api/team-room"),       //团队模式相关的路由组
+		DailyChallengeRoutes: router.Group("/api/daily-challenge"), //每日挑战相关的路由组
+		AchievementRoutes:    router.Group("/api/achievement"),     //成就相关的路由组
+		LeaderboardRoutes:    router.Group("/api/leaderboard"),     //排行榜相关的路由组
+		SeriesRoutes:         router.Group("/api/series"),          //训练系列相关的路由组
 	}
 }
 
@@ -51,6 +59,22 @@ func (rm *RouteManager) RegisterTeamRoomRoutes(handler PathHandler) {
 	handler(rm.TeamRoomRoutes)
 }
 
+func (rm *RouteManager) RegisterDailyChallengeRoutes(handler PathHandler) {
+	handler(rm.DailyChallengeRoutes)
+}
+
+func (rm *RouteManager) RegisterAchievementRoutes(handler PathHandler) {
+	handler(rm.AchievementRoutes)
+}
+
+func (rm *RouteManager) RegisterLeaderboardRoutes(handler PathHandler) {
+	handler(rm.LeaderboardRoutes)
+}
+
+func (rm *RouteManager) RegisterSeriesRoutes(handler PathHandler) {
+	handler(rm.SeriesRoutes)
+}
+
 // RegisterMiddleware 根据组名为对应的路由组注册中间件
 // group 参数为 "login"、"profile"、"team"或"Common"，分别对应不同的路由组
 func (rm *RouteManager) RegisterMiddleware(group string, middleware Middleware) {
@@ -63,6 +87,14 @@ func (rm *RouteManager) RegisterMiddleware(group string, middleware Middleware)
 		rm.SinglePlayerRoutes.Use(middleware())
 	case "team-room":
 		rm.TeamRoomRoutes.Use(middleware())
+	case "daily-challenge":
+		rm.DailyChallengeRoutes.Use(middleware())
+	case "achievement":
+		rm.AchievementRoutes.Use(middleware())
+	case "leaderboard":
+		rm.LeaderboardRoutes.Use(middleware())
+	case "series":
+		rm.SeriesRoutes.Use(middleware())
 	}
 }
 
@@ -0,0 +1,28 @@
+package model
+
+type Achievement struct {
+	CommonModel
+	Code        string `gorm:"column:code;type:varchar(64);not null;uniqueIndex:idx_achievement_code;comment:成就编码"`
+	Name        string `gorm:"column:name;type:varchar(64);not null;comment:成就名称"`
+	Description string `gorm:"column:description;type:varchar(255);comment:成就描述"`
+	Icon        string `gorm:"column:icon;type:varchar(255);comment:徽章图标"`
+	Trigger     string `gorm:"column:trigger_type;type:varchar(32);not null;comment:触发条件类型"`
+	Threshold   int    `gorm:"column:threshold;type:int;default:0;comment:触发阈值"`
+	Sort        int    `gorm:"column:sort;type:int;default:0;comment:展示顺序"`
+}
+
+func (a *Achievement) TableName() string {
+	return "achievement"
+}
+
+type UserAchievement struct {
+	CommonModel
+	UserID     int64  `gorm:"column:user_id;type:bigint;not null;uniqueIndex:idx_user_achievement_user_code,priority:1;comment:玩家ID"`
+	Code       string `gorm:"column:code;type:varchar(64);not null;uniqueIndex:idx_user_achievement_user_code,priority:2;comment:成就编码"`
+	RoomID     int64  `gorm:"column:room_id;type:bigint;default:0;comment:触发房间ID"`
+	UnlockedAt int64  `gorm:"column:unlocked_at;type:bigint;default:0;comment:解锁时间戳"`
+}
+
+func (u *UserAchievement) TableName() string {
+	return "user_achievement"
+}
@@ -4,6 +4,7 @@ type CodeforcesProblem struct {
 	ID         string `gorm:"column:id;type:varchar(32);primaryKey"`
 	Url        string `gorm:"column:url;type:varchar(255);not null"`
 	Difficulty int    `gorm:"column:difficulty;type:int;default:0;index:idx_codeforces_problem_difficulty"`
+	Tags       string `gorm:"column:tags;type:varchar(512);default:'';comment:标签(逗号包围,如,dp,greedy,)"`
 }
 
 func (c *CodeforcesProblem) TableName() string {
@@ -0,0 +1,26 @@
+package model
+
+type DailyChallenge struct {
+	CommonModel
+	Date       string `gorm:"column:date;type:varchar(16);not null;uniqueIndex:idx_daily_challenge_date;comment:日期(YYYY-MM-DD)"`
+	ProblemID  string `gorm:"column:problem_id;type:varchar(32);not null;comment:题目ID"`
+	Difficulty int    `gorm:"column:difficulty;type:int;default:0;comment:题目难度"`
+}
+
+func (d *DailyChallenge) TableName() string {
+	return "daily_challenge"
+}
+
+type DailyChallengeRecord struct {
+	CommonModel
+	Date         string `gorm:"column:date;type:varchar(16);not null;uniqueIndex:idx_daily_challenge_record_user_date,priority:2;index:idx_daily_challenge_record_date;comment:日期(YYYY-MM-DD)"`
+	UserID       int64  `gorm:"column:user_id;type:bigint;not null;uniqueIndex:idx_daily_challenge_record_user_date,priority:1;comment:玩家ID"`
+	RoomID       int64  `gorm:"column:room_id;type:bigint;not null;comment:单人房间ID"`
+	Status       int8   `gorm:"column:status;type:tinyint;default:0;comment:完成状态(0进行中,1放弃,2AC)"`
+	SolveSeconds int64  `gorm:"column:solve_seconds;type:bigint;default:0;comment:AC用时(秒)"`
+	Penalty      int    `gorm:"column:penalty;type:int;default:0;comment:罚时"`
+}
+
+func (d *DailyChallengeRecord) TableName() string {
+	return "daily_challenge_record"
+}
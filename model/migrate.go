@@ -8,6 +8,22 @@ func MigrateTables(db *gorm.DB) error {
 		&CodeforcesProblem{},
 		&SinglePlayerRoom{},
 		&TeamRoom{},
+		&DailyChallenge{},
+		&DailyChallengeRecord{},
+		&Achievement{},
+		&UserAchievement{},
+		&TeamRoomMode{},
+		&TeamRoomProblemSet{},
+		&UserTeamStats{},
+		&TeamRoomEvent{},
+		&TeamRoomChatMessage{},
+		&TeamRoomPlayer{},
+		&TeamRoomProblem{},
+		&TeamRoomProblemStatus{},
+		&TeamRoomSubmission{},
+		&Series{},
+		&SeriesRound{},
+		&SeriesRoom{},
 	); err != nil {
 		return err
 	}
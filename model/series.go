@@ -0,0 +1,46 @@
+package model
+
+// Series 训练系列，由若干轮组成，每轮对应一个团队房间或一组单人房间
+type Series struct {
+	CommonModel
+	Name          string `gorm:"column:name;type:varchar(64);not null;comment:系列名称"`
+	Description   string `gorm:"column:description;type:varchar(255);comment:系列描述"`
+	CreatorID     int64  `gorm:"column:creator_id;type:bigint;not null;index:idx_series_creator_id;comment:创建人ID"`
+	RoomType      string `gorm:"column:room_type;type:varchar(16);not null;comment:房间类型(team,single)"`
+	Aggregation   string `gorm:"column:aggregation;type:varchar(16);not null;comment:积分方式(sum,best_n,rank_points)"`
+	BestN         int    `gorm:"column:best_n;type:int;default:0;comment:best_n方式计入的轮数"`
+	RankPoints    string `gorm:"column:rank_points;type:json;comment:rank_points方式各名次得分JSON"`
+	RoomConfig    string `gorm:"column:room_config;type:json;comment:团队系列创建房间参数JSON"`
+	MinDifficulty int    `gorm:"column:min_difficulty;type:int;default:0;comment:单人系列题目最低难度"`
+	MaxDifficulty int    `gorm:"column:max_difficulty;type:int;default:0;comment:单人系列题目最高难度"`
+	CurrentRound  int    `gorm:"column:current_round;type:int;default:0;comment:当前轮次"`
+}
+
+func (s *Series) TableName() string {
+	return "series"
+}
+
+type SeriesRound struct {
+	CommonModel
+	SeriesID  int64  `gorm:"column:series_id;type:bigint;not null;uniqueIndex:idx_series_round_round;comment:系列ID"`
+	Round     int    `gorm:"column:round;type:int;not null;uniqueIndex:idx_series_round_round;comment:轮次"`
+	ProblemID string `gorm:"column:problem_id;type:varchar(32);default:'';comment:单人系列本轮题目ID"`
+}
+
+func (s *SeriesRound) TableName() string {
+	return "series_round"
+}
+
+// SeriesRoom 一个房间只能属于一个系列，单人系列每名玩家每轮只有一个房间，团队房间 UserID 为空
+type SeriesRoom struct {
+	CommonModel
+	SeriesID int64  `gorm:"column:series_id;type:bigint;not null;index:idx_series_room_series_id;uniqueIndex:idx_series_room_user;comment:系列ID"`
+	Round    int    `gorm:"column:round;type:int;not null;uniqueIndex:idx_series_room_user;comment:轮次"`
+	RoomType string `gorm:"column:room_type;type:varchar(16);not null;uniqueIndex:idx_series_room_room;comment:房间类型"`
+	RoomID   int64  `gorm:"column:room_id;type:bigint;not null;uniqueIndex:idx_series_room_room;comment:房间ID"`
+	UserID   *int64 `gorm:"column:user_id;type:bigint;uniqueIndex:idx_series_room_user;comment:单人房间所属玩家ID"`
+}
+
+func (s *SeriesRoom) TableName() string {
+	return "series_room"
+}
@@ -11,6 +11,7 @@ type SinglePlayerRoom struct {
 	RatingBefore     int    `gorm:"column:rating_before;type:int;default:0;comment:结算前rating"`
 	RatingAfter      int    `gorm:"column:rating_after;type:int;default:0;comment:结算后rating"`
 	ExtraInfo        string `gorm:"column:extra_info;type:text;comment:扩展信息"`
+	ChallengeDate    string `gorm:"column:challenge_date;type:varchar(16);default:'';index:idx_single_player_room_challenge_date;comment:每日挑战日期(为空表示普通房间)"`
 }
 
 func (s *SinglePlayerRoom) TableName() string {
@@ -2,17 +2,64 @@ package model
 
 type TeamRoom struct {
 	CommonModel
-	Mode              string `gorm:"column:mode;type:varchar(32);not null;index:idx_team_room_mode;comment:模式"`
-	ProblemList       string `gorm:"column:problem_list;type:json;comment:题目列表JSON"`
-	PlayerList        string `gorm:"column:player_list;type:json;comment:参与玩家列表JSON"`
-	CreatorID         int64  `gorm:"column:creator_id;type:bigint;not null;index:idx_team_room_creator_id;comment:创建人ID"`
-	EndTime           int64  `gorm:"column:end_time;type:bigint;default:0;index:idx_team_room_end_time;comment:结束时间戳"`
-	Status            int8   `gorm:"column:status;type:tinyint;default:0;index:idx_team_room_status;comment:房间状态(0进行中,1结束)"`
-	SubmissionRecords string `gorm:"column:submission_records;type:json;comment:提交记录JSON"`
-	ProblemStatus     string `gorm:"column:problem_status;type:json;comment:题目情况JSON"`
-	ExtraInfo         string `gorm:"column:extra_info;type:json;comment:额外信息JSON"`
+	Mode      string `gorm:"column:mode;type:varchar(32);not null;index:idx_team_room_mode;comment:模式"`
+	CreatorID int64  `gorm:"column:creator_id;type:bigint;not null;index:idx_team_room_creator_id;comment:创建人ID"`
+	StartTime int64  `gorm:"column:start_time;type:bigint;default:0;comment:实际开始时间戳"`
+	EndTime   int64  `gorm:"column:end_time;type:bigint;default:0;index:idx_team_room_end_time;comment:结束时间戳"`
+	Status    int8   `gorm:"column:status;type:tinyint;default:0;index:idx_team_room_status;comment:房间状态(0进行中,1结束,2等待开始)"`
+	ExtraInfo string `gorm:"column:extra_info;type:json;comment:额外信息JSON"`
+	// 以下字段保存在 team_room_problems、team_room_players、team_room_submissions、team_room_problem_status 表中，
+	// 读取房间后由逻辑层加载。房间按值传递时共享底层数组，修改前需要先复制
+	Problems      []TeamRoomProblemItem       `gorm:"-"`
+	Players       []TeamRoomPlayerItem        `gorm:"-"`
+	Submissions   []TeamRoomSubmissionItem    `gorm:"-"`
+	ProblemStatus []TeamRoomProblemStatusItem `gorm:"-"`
 }
 
 func (t *TeamRoom) TableName() string {
 	return "team_room"
 }
+
+// TeamRoomProblemItem 等类型是房间关联数据在内存中的形式，JSON标签用于兼容旧版本的JSON列
+type TeamRoomProblemItem struct {
+	ProblemID  string `json:"problem_id"`
+	ProblemURL string `json:"problem_url"`
+	Difficulty int    `json:"difficulty"`
+}
+
+type TeamRoomPlayerItem struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	JoinAt   int64  `json:"join_at"`
+	TeamID   int    `json:"team_id,omitempty"`
+	Ready    bool   `json:"ready,omitempty"`
+}
+
+type TeamRoomSubmissionItem struct {
+	SubmissionID int64  `json:"submission_id"`
+	ProblemID    string `json:"problem_id"`
+	UserID       int64  `json:"user_id"`
+	TeamID       int    `json:"team_id,omitempty"`
+	Verdict      string `json:"verdict"`
+	SubmitTime   int64  `json:"submit_time"`
+}
+
+// TeamRoomProblemStatusItem 合作模式下 TeamID 为0，对抗模式下每支队伍每道题各一条
+type TeamRoomProblemStatusItem struct {
+	ProblemID  string `json:"problem_id"`
+	TeamID     int    `json:"team_id,omitempty"`
+	Solved     bool   `json:"solved"`
+	SolvedBy   int64  `json:"solved_by"`
+	Penalty    int    `json:"penalty"`
+	WrongCount int    `json:"wrong_count,omitempty"`
+	SolvedAt   int64  `json:"solved_at"`
+}
+
+// TeamRoomLegacyData 旧版本直接保存在 team_room 表中的JSON数据，仅用于迁移到关联表
+type TeamRoomLegacyData struct {
+	ID                int64
+	ProblemList       string
+	PlayerList        string
+	SubmissionRecords string
+	ProblemStatus     string
+}
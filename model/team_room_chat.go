@@ -0,0 +1,13 @@
+package model
+
+type TeamRoomChatMessage struct {
+	CommonModel
+	RoomID   int64  `gorm:"column:room_id;type:bigint;not null;index:idx_team_room_chat_room_id;comment:房间ID"`
+	UserID   int64  `gorm:"column:user_id;type:bigint;not null;comment:发送者ID"`
+	Username string `gorm:"column:username;type:varchar(64);comment:发送时的用户名"`
+	Content  string `gorm:"column:content;type:varchar(1024);not null;comment:消息内容"`
+}
+
+func (t *TeamRoomChatMessage) TableName() string {
+	return "team_room_chat_message"
+}
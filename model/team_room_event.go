@@ -0,0 +1,15 @@
+package model
+
+type TeamRoomEvent struct {
+	CommonModel
+	RoomID  int64  `gorm:"column:room_id;type:bigint;not null;index:idx_team_room_event_room_id;comment:房间ID"`
+	Type    string `gorm:"column:type;type:varchar(32);not null;comment:事件类型"`
+	UserID  int64  `gorm:"column:user_id;type:bigint;default:0;comment:相关玩家ID"`
+	TeamID  int    `gorm:"column:team_id;type:int;default:0;comment:相关队伍ID"`
+	EventAt int64  `gorm:"column:event_at;type:bigint;not null;comment:事件发生时间"`
+	Data    string `gorm:"column:data;type:json;comment:事件内容JSON"`
+}
+
+func (t *TeamRoomEvent) TableName() string {
+	return "team_room_event"
+}
@@ -0,0 +1,17 @@
+package model
+
+type TeamRoomMode struct {
+	CommonModel
+	Mode            string `gorm:"column:mode;type:varchar(32);not null;uniqueIndex:idx_team_room_mode_mode;comment:模式标识"`
+	Description     string `gorm:"column:description;type:varchar(255);comment:模式描述"`
+	DurationSeconds int64  `gorm:"column:duration_seconds;type:bigint;default:0;comment:比赛时长(秒)"`
+	Difficulties    string `gorm:"column:difficulties;type:json;comment:题目难度列表JSON"`
+	Tags            string `gorm:"column:tags;type:json;comment:题目标签限制JSON"`
+	PenaltyPerWrong int    `gorm:"column:penalty_per_wrong;type:int;default:20;comment:每次错误罚时(分钟)"`
+	FreezeMinutes   int    `gorm:"column:freeze_minutes;type:int;default:0;comment:结束前封榜分钟数(0不封榜)"`
+	Sort            int    `gorm:"column:sort;type:int;default:0;comment:展示顺序"`
+}
+
+func (t *TeamRoomMode) TableName() string {
+	return "team_room_mode"
+}
@@ -0,0 +1,15 @@
+package model
+
+type TeamRoomPlayer struct {
+	CommonModel
+	RoomID   int64  `gorm:"column:room_id;type:bigint;not null;uniqueIndex:idx_team_room_player_room_user;comment:房间ID"`
+	UserID   int64  `gorm:"column:user_id;type:bigint;not null;uniqueIndex:idx_team_room_player_room_user;index:idx_team_room_player_user_id;comment:玩家ID"`
+	Username string `gorm:"column:username;type:varchar(64);comment:加入时的用户名"`
+	TeamID   int    `gorm:"column:team_id;type:int;default:0;comment:所在队伍ID"`
+	Ready    bool   `gorm:"column:ready;type:tinyint(1);default:0;comment:是否已准备"`
+	JoinAt   int64  `gorm:"column:join_at;type:bigint;not null;comment:加入时间戳"`
+}
+
+func (t *TeamRoomPlayer) TableName() string {
+	return "team_room_players"
+}
@@ -0,0 +1,31 @@
+package model
+
+type TeamRoomProblem struct {
+	CommonModel
+	RoomID     int64  `gorm:"column:room_id;type:bigint;not null;uniqueIndex:idx_team_room_problem_room_problem;comment:房间ID"`
+	ProblemID  string `gorm:"column:problem_id;type:varchar(32);not null;uniqueIndex:idx_team_room_problem_room_problem;comment:题目ID"`
+	Sort       int    `gorm:"column:sort;type:int;default:0;comment:题目顺序"`
+	ProblemURL string `gorm:"column:problem_url;type:varchar(255);comment:题目链接"`
+	Difficulty int    `gorm:"column:difficulty;type:int;default:0;comment:难度"`
+}
+
+func (t *TeamRoomProblem) TableName() string {
+	return "team_room_problems"
+}
+
+// TeamRoomProblemStatus 合作模式下 TeamID 为0，对抗模式下每支队伍每道题各一条
+type TeamRoomProblemStatus struct {
+	CommonModel
+	RoomID     int64  `gorm:"column:room_id;type:bigint;not null;uniqueIndex:idx_team_room_problem_status_key;comment:房间ID"`
+	TeamID     int    `gorm:"column:team_id;type:int;not null;default:0;uniqueIndex:idx_team_room_problem_status_key;comment:队伍ID"`
+	ProblemID  string `gorm:"column:problem_id;type:varchar(32);not null;uniqueIndex:idx_team_room_problem_status_key;comment:题目ID"`
+	Solved     bool   `gorm:"column:solved;type:tinyint(1);default:0;comment:是否通过"`
+	SolvedBy   int64  `gorm:"column:solved_by;type:bigint;default:0;index:idx_team_room_problem_status_solved_by;comment:通过玩家ID"`
+	SolvedAt   int64  `gorm:"column:solved_at;type:bigint;default:0;comment:通过用时(秒)"`
+	Penalty    int    `gorm:"column:penalty;type:int;default:0;comment:错误罚时(分钟)"`
+	WrongCount int    `gorm:"column:wrong_count;type:int;default:0;comment:通过前错误次数"`
+}
+
+func (t *TeamRoomProblemStatus) TableName() string {
+	return "team_room_problem_status"
+}
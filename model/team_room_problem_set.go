@@ -0,0 +1,14 @@
+package model
+
+type TeamRoomProblemSet struct {
+	CommonModel
+	Name           string `gorm:"column:name;type:varchar(64);not null;comment:题单名称"`
+	Description    string `gorm:"column:description;type:varchar(255);comment:题单描述"`
+	ProblemIDs     string `gorm:"column:problem_ids;type:json;comment:题目ID列表JSON"`
+	HideDifficulty bool   `gorm:"column:hide_difficulty;type:tinyint(1);default:0;comment:比赛中隐藏难度"`
+	CreatorID      int64  `gorm:"column:creator_id;type:bigint;not null;index:idx_team_room_problem_set_creator_id;comment:创建人ID"`
+}
+
+func (t *TeamRoomProblemSet) TableName() string {
+	return "team_room_problem_set"
+}
@@ -0,0 +1,16 @@
+package model
+
+type TeamRoomSubmission struct {
+	CommonModel
+	RoomID       int64  `gorm:"column:room_id;type:bigint;not null;uniqueIndex:idx_team_room_submission_room_submission;comment:房间ID"`
+	SubmissionID int64  `gorm:"column:submission_id;type:bigint;not null;uniqueIndex:idx_team_room_submission_room_submission;comment:CF提交ID"`
+	ProblemID    string `gorm:"column:problem_id;type:varchar(32);not null;comment:题目ID"`
+	UserID       int64  `gorm:"column:user_id;type:bigint;not null;index:idx_team_room_submission_user_id;comment:提交玩家ID"`
+	TeamID       int    `gorm:"column:team_id;type:int;default:0;comment:所在队伍ID"`
+	Verdict      string `gorm:"column:verdict;type:varchar(32);comment:评测结果"`
+	SubmitTime   int64  `gorm:"column:submit_time;type:bigint;not null;comment:收到结果的时间戳"`
+}
+
+func (t *TeamRoomSubmission) TableName() string {
+	return "team_room_submissions"
+}
@@ -0,0 +1,16 @@
+package model
+
+type UserTeamStats struct {
+	CommonModel
+	UserID      int64 `gorm:"column:user_id;type:bigint;not null;uniqueIndex:idx_user_team_stats_user_id;comment:玩家ID"`
+	Rooms       int   `gorm:"column:rooms;type:int;default:0;comment:参与房间数"`
+	Wins        int   `gorm:"column:wins;type:int;default:0;comment:获胜房间数"`
+	Solved      int   `gorm:"column:solved;type:int;default:0;comment:通过题目数"`
+	WrongCount  int   `gorm:"column:wrong_count;type:int;default:0;comment:错误提交数"`
+	FirstBloods int   `gorm:"column:first_bloods;type:int;default:0;comment:房间首个通过次数"`
+	Points      int64 `gorm:"column:points;type:bigint;default:0;comment:通过题目难度之和"`
+}
+
+func (u *UserTeamStats) TableName() string {
+	return "user_team_stats"
+}
@@ -14,7 +14,8 @@ type Mysql struct {
 // InitDataBases 初始化
 func (m *Mysql) InitDataBase(config configs.Config) (*gorm.DB, error) {
 	dsn := m.GetDsn(config)
-	db, err := gorm.Open(mysql.Open(dsn))
+	// 开启错误转换，唯一索引冲突返回 gorm.ErrDuplicatedKey
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		zlog.Panicf("MySQL无法连接数据库！: %v", err)
 		return nil, err
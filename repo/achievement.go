@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"tgwp/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AchievementRepo struct {
+	DB *gorm.DB
+}
+
+func NewAchievementRepo(db *gorm.DB) *AchievementRepo {
+	return &AchievementRepo{DB: db}
+}
+
+func (r *AchievementRepo) List() ([]model.Achievement, error) {
+	var items []model.Achievement
+	err := r.DB.Order("sort asc").Order("id asc").Find(&items).Error
+	return items, err
+}
+
+// CreateIfAbsent 已存在的成就定义保持数据库中的版本，便于直接修改数据
+func (r *AchievementRepo) CreateIfAbsent(items []model.Achievement) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error
+}
+
+func (r *AchievementRepo) ListByUser(userID int64) ([]model.UserAchievement, error) {
+	var items []model.UserAchievement
+	err := r.DB.Where("user_id = ?", userID).Order("unlocked_at asc").Find(&items).Error
+	return items, err
+}
+
+// Unlock 返回是否为本次新解锁
+func (r *AchievementRepo) Unlock(item *model.UserAchievement) (bool, error) {
+	result := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(item)
+	return result.RowsAffected > 0, result.Error
+}
@@ -21,8 +21,62 @@ func (r *CodeforcesProblemRepo) GetRandomByDifficulty(minDifficulty, maxDifficul
 	return problem, err
 }
 
+// GetRandomByDifficultyAndTags tags 为空时不限制标签，否则需包含其中任意一个
+func (r *CodeforcesProblemRepo) GetRandomByDifficultyAndTags(minDifficulty, maxDifficulty int, tags []string) (model.CodeforcesProblem, error) {
+	var problem model.CodeforcesProblem
+	err := r.withTags(r.DB.Where("difficulty >= ? AND difficulty <= ?", minDifficulty, maxDifficulty), tags).
+		Order("RAND()").
+		First(&problem).Error
+	return problem, err
+}
+
+func (r *CodeforcesProblemRepo) CountByDifficultyAndTags(minDifficulty, maxDifficulty int, tags []string) (int64, error) {
+	var count int64
+	err := r.withTags(r.DB.Model(&model.CodeforcesProblem{}).Where("difficulty >= ? AND difficulty <= ?", minDifficulty, maxDifficulty), tags).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *CodeforcesProblemRepo) withTags(query *gorm.DB, tags []string) *gorm.DB {
+	if len(tags) == 0 {
+		return query
+	}
+	cond := r.DB.Where("tags LIKE ?", "%,"+tags[0]+",%")
+	for _, tag := range tags[1:] {
+		cond = cond.Or("tags LIKE ?", "%,"+tag+",%")
+	}
+	return query.Where(cond)
+}
+
 func (r *CodeforcesProblemRepo) GetByID(id string) (model.CodeforcesProblem, error) {
 	var problem model.CodeforcesProblem
 	err := r.DB.Where("id = ?", id).First(&problem).Error
 	return problem, err
 }
+
+func (r *CodeforcesProblemRepo) ListByIDs(ids []string) ([]model.CodeforcesProblem, error) {
+	var problems []model.CodeforcesProblem
+	if len(ids) == 0 {
+		return problems, nil
+	}
+	err := r.DB.Where("id IN ?", ids).Find(&problems).Error
+	return problems, err
+}
+
+func (r *CodeforcesProblemRepo) CountByDifficulty(minDifficulty, maxDifficulty int) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.CodeforcesProblem{}).
+		Where("difficulty >= ? AND difficulty <= ?", minDifficulty, maxDifficulty).
+		Count(&count).Error
+	return count, err
+}
+
+// GetByDifficultyOffset 按ID排序后取第offset道题，用于确定性选题
+func (r *CodeforcesProblemRepo) GetByDifficultyOffset(minDifficulty, maxDifficulty int, offset int) (model.CodeforcesProblem, error) {
+	var problem model.CodeforcesProblem
+	err := r.DB.Where("difficulty >= ? AND difficulty <= ?", minDifficulty, maxDifficulty).
+		Order("id asc").
+		Offset(offset).
+		First(&problem).Error
+	return problem, err
+}
@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"tgwp/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DailyChallengeRepo struct {
+	DB *gorm.DB
+}
+
+func NewDailyChallengeRepo(db *gorm.DB) *DailyChallengeRepo {
+	return &DailyChallengeRepo{DB: db}
+}
+
+func (r *DailyChallengeRepo) GetByDate(date string) (model.DailyChallenge, error) {
+	var challenge model.DailyChallenge
+	err := r.DB.Where("date = ?", date).First(&challenge).Error
+	return challenge, err
+}
+
+// CreateIfAbsent 并发生成同一天的挑战时只保留第一条
+func (r *DailyChallengeRepo) CreateIfAbsent(challenge *model.DailyChallenge) error {
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(challenge).Error
+}
+
+func (r *DailyChallengeRepo) GetRecord(userID int64, date string) (model.DailyChallengeRecord, error) {
+	var record model.DailyChallengeRecord
+	err := r.DB.Where("user_id = ? AND date = ?", userID, date).First(&record).Error
+	return record, err
+}
+
+func (r *DailyChallengeRepo) CreateRecord(record *model.DailyChallengeRecord) error {
+	return r.DB.Create(record).Error
+}
+
+func (r *DailyChallengeRepo) FinishRecord(roomID int64, status int8, solveSeconds int64, penalty int) error {
+	return r.DB.Model(&model.DailyChallengeRecord{}).Where("room_id = ?", roomID).Updates(map[string]interface{}{
+		"status":        status,
+		"solve_seconds": solveSeconds,
+		"penalty":       penalty,
+	}).Error
+}
+
+func (r *DailyChallengeRepo) ListSolvedByDate(date string, offset, limit int) ([]model.DailyChallengeRecord, int64, error) {
+	query := r.DB.Model(&model.DailyChallengeRecord{}).Where("date = ? AND status = ?", date, 2)
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	var records []model.DailyChallengeRecord
+	err := query.Order("solve_seconds + penalty * 60 asc").Order("updated_at asc").Offset(offset).Limit(limit).Find(&records).Error
+	return records, count, err
+}
+
+func (r *DailyChallengeRepo) CountSolvedByDate(date string) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.DailyChallengeRecord{}).Where("date = ? AND status = ?", date, 2).Count(&count).Error
+	return count, err
+}
+
+func (r *DailyChallengeRepo) ListSolvedDates(userID int64) ([]string, error) {
+	var dates []string
+	err := r.DB.Model(&model.DailyChallengeRecord{}).
+		Where("user_id = ? AND status = ?", userID, 2).
+		Order("date desc").
+		Pluck("date", &dates).Error
+	return dates, err
+}
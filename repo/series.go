@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"tgwp/model"
+
+	"gorm.io/gorm"
+)
+
+type SeriesRepo struct {
+	DB *gorm.DB
+}
+
+func NewSeriesRepo(db *gorm.DB) *SeriesRepo {
+	return &SeriesRepo{DB: db}
+}
+
+func (r *SeriesRepo) Create(item *model.Series) error {
+	return r.DB.Create(item).Error
+}
+
+func (r *SeriesRepo) GetByID(id int64) (model.Series, error) {
+	var item model.Series
+	err := r.DB.Where("id = ?", id).First(&item).Error
+	return item, err
+}
+
+func (r *SeriesRepo) List(offset, limit int) ([]model.Series, int64, error) {
+	query := r.DB.Model(&model.Series{})
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []model.Series
+	err := query.Order("created_at desc").Offset(offset).Limit(limit).Find(&items).Error
+	return items, count, err
+}
+
+// UpdateCurrentRound 只在当前轮次等于 from 时更新，避免并发开启同一轮
+func (r *SeriesRepo) UpdateCurrentRound(id int64, from int, to int) (bool, error) {
+	result := r.DB.Model(&model.Series{}).Where("id = ? AND current_round = ?", id, from).Update("current_round", to)
+	return result.RowsAffected > 0, result.Error
+}
+
+type SeriesRoundRepo struct {
+	DB *gorm.DB
+}
+
+func NewSeriesRoundRepo(db *gorm.DB) *SeriesRoundRepo {
+	return &SeriesRoundRepo{DB: db}
+}
+
+func (r *SeriesRoundRepo) Create(item *model.SeriesRound) error {
+	return r.DB.Create(item).Error
+}
+
+func (r *SeriesRoundRepo) Get(seriesID int64, round int) (model.SeriesRound, error) {
+	var item model.SeriesRound
+	err := r.DB.Where("series_id = ? AND round = ?", seriesID, round).First(&item).Error
+	return item, err
+}
+
+func (r *SeriesRoundRepo) ListBySeries(seriesID int64) ([]model.SeriesRound, error) {
+	var items []model.SeriesRound
+	err := r.DB.Where("series_id = ?", seriesID).Order("round asc").Find(&items).Error
+	return items, err
+}
+
+type SeriesRoomRepo struct {
+	DB *gorm.DB
+}
+
+func NewSeriesRoomRepo(db *gorm.DB) *SeriesRoomRepo {
+	return &SeriesRoomRepo{DB: db}
+}
+
+func (r *SeriesRoomRepo) Create(item *model.SeriesRoom) error {
+	return r.DB.Create(item).Error
+}
+
+func (r *SeriesRoomRepo) GetByRoom(roomType string, roomID int64) (model.SeriesRoom, error) {
+	var item model.SeriesRoom
+	err := r.DB.Where("room_type = ? AND room_id = ?", roomType, roomID).First(&item).Error
+	return item, err
+}
+
+func (r *SeriesRoomRepo) GetUserRoom(seriesID int64, round int, userID int64) (model.SeriesRoom, error) {
+	var item model.SeriesRoom
+	err := r.DB.Where("series_id = ? AND round = ? AND user_id = ?", seriesID, round, userID).First(&item).Error
+	return item, err
+}
+
+func (r *SeriesRoomRepo) ListBySeries(seriesID int64) ([]model.SeriesRoom, error) {
+	var items []model.SeriesRoom
+	err := r.DB.Where("series_id = ?", seriesID).Order("round asc, id asc").Find(&items).Error
+	return items, err
+}
@@ -25,9 +25,18 @@ func (r *SinglePlayerRoomRepo) GetByID(id int64) (model.SinglePlayerRoom, error)
 	return room, err
 }
 
+func (r *SinglePlayerRoomRepo) ListByIDs(ids []int64) ([]model.SinglePlayerRoom, error) {
+	var rooms []model.SinglePlayerRoom
+	if len(ids) == 0 {
+		return rooms, nil
+	}
+	err := r.DB.Where("id IN ?", ids).Find(&rooms).Error
+	return rooms, err
+}
+
 func (r *SinglePlayerRoomRepo) GetActiveByUser(userID int64) (model.SinglePlayerRoom, error) {
 	var room model.SinglePlayerRoom
-	err := r.DB.Where("user_id = ? AND status = ?", userID, 0).Order("created_at desc").First(&room).Error
+	err := r.DB.Where("user_id = ? AND status = ? AND challenge_date = ?", userID, 0, "").Order("created_at desc").First(&room).Error
 	return room, err
 }
 
@@ -43,6 +52,27 @@ func (r *SinglePlayerRoomRepo) ListActiveBefore(before time.Time) ([]model.Singl
 	return rooms, err
 }
 
+func (r *SinglePlayerRoomRepo) CountSolvedByUser(userID int64) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.SinglePlayerRoom{}).Where("user_id = ? AND status = ?", userID, 2).Count(&count).Error
+	return count, err
+}
+
+type UserSolveCount struct {
+	UserID int64 `gorm:"column:user_id"`
+	Count  int64 `gorm:"column:count"`
+}
+
+func (r *SinglePlayerRoomRepo) CountSolvedGroupByUser(since int64) ([]UserSolveCount, error) {
+	var items []UserSolveCount
+	err := r.DB.Model(&model.SinglePlayerRoom{}).
+		Select("user_id, COUNT(*) AS count").
+		Where("status = ? AND end_time >= ?", 2, since).
+		Group("user_id").
+		Scan(&items).Error
+	return items, err
+}
+
 func (r *SinglePlayerRoomRepo) UpdatePenalty(id int64, penalty int) error {
 	return r.DB.Model(&model.SinglePlayerRoom{}).Where("id = ?", id).Updates(map[string]interface{}{
 		"penalty": penalty,
@@ -55,6 +85,13 @@ func (r *SinglePlayerRoomRepo) UpdateExtraInfo(id int64, extraInfo string) error
 	}).Error
 }
 
+func (r *SinglePlayerRoomRepo) UpdateProblem(id int64, problemID string, extraInfo string) error {
+	return r.DB.Model(&model.SinglePlayerRoom{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"problem_id": problemID,
+		"extra_info": extraInfo,
+	}).Error
+}
+
 func (r *SinglePlayerRoomRepo) FinishRoom(id int64, status int8, endTime int64, performance int, ratingBefore int, ratingAfter int, penalty int) error {
 	return r.DB.Model(&model.SinglePlayerRoom{}).Where("id = ?", id).Updates(map[string]interface{}{
 		"status":            status,
@@ -24,6 +24,15 @@ func (r *TeamRoomRepo) GetByID(id int64) (model.TeamRoom, error) {
 	return room, err
 }
 
+func (r *TeamRoomRepo) ListByIDs(ids []int64) ([]model.TeamRoom, error) {
+	var rooms []model.TeamRoom
+	if len(ids) == 0 {
+		return rooms, nil
+	}
+	err := r.DB.Where("id IN ?", ids).Order("created_at desc").Find(&rooms).Error
+	return rooms, err
+}
+
 func (r *TeamRoomRepo) List(offset, limit int, status *int8) ([]model.TeamRoom, int64, error) {
 	query := r.DB.Model(&model.TeamRoom{})
 	if status != nil {
@@ -40,7 +49,13 @@ func (r *TeamRoomRepo) List(offset, limit int, status *int8) ([]model.TeamRoom,
 
 func (r *TeamRoomRepo) ListActive() ([]model.TeamRoom, error) {
 	var rooms []model.TeamRoom
-	err := r.DB.Where("status = ?", 0).Order("created_at asc").Find(&rooms).Error
+	err := r.DB.Where("status IN ?", []int8{0, 2}).Order("created_at asc").Find(&rooms).Error
+	return rooms, err
+}
+
+func (r *TeamRoomRepo) ListFinished() ([]model.TeamRoom, error) {
+	var rooms []model.TeamRoom
+	err := r.DB.Where("status = ?", 1).Order("created_at asc").Find(&rooms).Error
 	return rooms, err
 }
 
@@ -51,18 +66,48 @@ func (r *TeamRoomRepo) UpdateStatus(id int64, status int8, endTime int64) error
 	}).Error
 }
 
-func (r *TeamRoomRepo) UpdateSubmissionRecords(id int64, value string) error {
-	return r.DB.Model(&model.TeamRoom{}).Where("id = ?", id).Update("submission_records", value).Error
-}
-
-func (r *TeamRoomRepo) UpdateProblemStatus(id int64, value string) error {
-	return r.DB.Model(&model.TeamRoom{}).Where("id = ?", id).Update("problem_status", value).Error
+func (r *TeamRoomRepo) UpdateStart(id int64, startTime int64) error {
+	return r.DB.Model(&model.TeamRoom{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":     0,
+		"start_time": startTime,
+	}).Error
 }
 
-func (r *TeamRoomRepo) UpdatePlayerList(id int64, value string) error {
-	return r.DB.Model(&model.TeamRoom{}).Where("id = ?", id).Update("player_list", value).Error
+func (r *TeamRoomRepo) UpdateCreator(id int64, creatorID int64) error {
+	return r.DB.Model(&model.TeamRoom{}).Where("id = ?", id).Update("creator_id", creatorID).Error
 }
 
 func (r *TeamRoomRepo) UpdateExtraInfo(id int64, value string) error {
 	return r.DB.Model(&model.TeamRoom{}).Where("id = ?", id).Update("extra_info", value).Error
 }
+
+var teamRoomLegacyColumns = []string{"problem_list", "player_list", "submission_records", "problem_status"}
+
+// HasLegacyColumns 旧版本的表中才有JSON列
+func (r *TeamRoomRepo) HasLegacyColumns() bool {
+	for _, column := range teamRoomLegacyColumns {
+		if !r.DB.Migrator().HasColumn(&model.TeamRoom{}, column) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListLegacy 查询尚未迁移到关联表的房间
+func (r *TeamRoomRepo) ListLegacy(limit int) ([]model.TeamRoomLegacyData, error) {
+	var items []model.TeamRoomLegacyData
+	err := r.DB.Table("team_room").
+		Select("id, COALESCE(problem_list, '') AS problem_list, COALESCE(player_list, '') AS player_list, " +
+			"COALESCE(submission_records, '') AS submission_records, COALESCE(problem_status, '') AS problem_status").
+		Where("problem_list IS NOT NULL OR player_list IS NOT NULL OR submission_records IS NOT NULL OR problem_status IS NOT NULL").
+		Order("id asc").Limit(limit).Scan(&items).Error
+	return items, err
+}
+
+func (r *TeamRoomRepo) ClearLegacy(id int64) error {
+	values := make(map[string]interface{}, len(teamRoomLegacyColumns))
+	for _, column := range teamRoomLegacyColumns {
+		values[column] = nil
+	}
+	return r.DB.Table("team_room").Where("id = ?", id).Updates(values).Error
+}
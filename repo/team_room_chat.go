@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"tgwp/model"
+
+	"gorm.io/gorm"
+)
+
+type TeamRoomChatRepo struct {
+	DB *gorm.DB
+}
+
+func NewTeamRoomChatRepo(db *gorm.DB) *TeamRoomChatRepo {
+	return &TeamRoomChatRepo{DB: db}
+}
+
+func (r *TeamRoomChatRepo) Create(item *model.TeamRoomChatMessage) error {
+	return r.DB.Create(item).Error
+}
+
+func (r *TeamRoomChatRepo) GetByID(roomID int64, id int64) (model.TeamRoomChatMessage, error) {
+	var item model.TeamRoomChatMessage
+	err := r.DB.Where("room_id = ? AND id = ?", roomID, id).First(&item).Error
+	return item, err
+}
+
+func (r *TeamRoomChatRepo) Delete(id int64) error {
+	return r.DB.Where("id = ?", id).Delete(&model.TeamRoomChatMessage{}).Error
+}
+
+// ListBefore 按ID倒序取 beforeID 之前的消息，beforeID 为0时从最新一条开始
+func (r *TeamRoomChatRepo) ListBefore(roomID int64, beforeID int64, limit int) ([]model.TeamRoomChatMessage, error) {
+	var items []model.TeamRoomChatMessage
+	db := r.DB.Where("room_id = ?", roomID)
+	if beforeID > 0 {
+		db = db.Where("id < ?", beforeID)
+	}
+	err := db.Order("id desc").Limit(limit).Find(&items).Error
+	return items, err
+}
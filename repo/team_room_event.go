@@ -0,0 +1,36 @@
+package repo
+
+import (
+	"tgwp/model"
+
+	"gorm.io/gorm"
+)
+
+type TeamRoomEventRepo struct {
+	DB *gorm.DB
+}
+
+func NewTeamRoomEventRepo(db *gorm.DB) *TeamRoomEventRepo {
+	return &TeamRoomEventRepo{DB: db}
+}
+
+func (r *TeamRoomEventRepo) Create(item *model.TeamRoomEvent) error {
+	return r.DB.Create(item).Error
+}
+
+func (r *TeamRoomEventRepo) ListByRoomType(roomID int64, eventType string) ([]model.TeamRoomEvent, error) {
+	var items []model.TeamRoomEvent
+	err := r.DB.Where("room_id = ? AND type = ?", roomID, eventType).Order("id asc").Find(&items).Error
+	return items, err
+}
+
+func (r *TeamRoomEventRepo) Delete(id int64) error {
+	return r.DB.Where("id = ?", id).Delete(&model.TeamRoomEvent{}).Error
+}
+
+// ListByRoom 雪花ID随时间递增，按ID排序即事件发生顺序
+func (r *TeamRoomEventRepo) ListByRoom(roomID int64) ([]model.TeamRoomEvent, error) {
+	var items []model.TeamRoomEvent
+	err := r.DB.Where("room_id = ?", roomID).Order("id asc").Find(&items).Error
+	return items, err
+}
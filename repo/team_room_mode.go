@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"tgwp/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TeamRoomModeRepo struct {
+	DB *gorm.DB
+}
+
+func NewTeamRoomModeRepo(db *gorm.DB) *TeamRoomModeRepo {
+	return &TeamRoomModeRepo{DB: db}
+}
+
+func (r *TeamRoomModeRepo) List() ([]model.TeamRoomMode, error) {
+	var items []model.TeamRoomMode
+	err := r.DB.Order("sort asc").Order("mode asc").Find(&items).Error
+	return items, err
+}
+
+func (r *TeamRoomModeRepo) GetByMode(mode string) (model.TeamRoomMode, error) {
+	var item model.TeamRoomMode
+	err := r.DB.Where("mode = ?", mode).First(&item).Error
+	return item, err
+}
+
+// CreateIfAbsent 已存在或被删除过的模式不再写入
+func (r *TeamRoomModeRepo) CreateIfAbsent(items []model.TeamRoomMode) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error
+}
+
+// Create 同名模式被软删除过时先清理旧记录，避免唯一索引冲突
+func (r *TeamRoomModeRepo) Create(item *model.TeamRoomMode) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("mode = ? AND deleted_at IS NOT NULL", item.Mode).Delete(&model.TeamRoomMode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(item).Error
+	})
+}
+
+func (r *TeamRoomModeRepo) Update(item model.TeamRoomMode) error {
+	return r.DB.Model(&model.TeamRoomMode{}).Where("mode = ?", item.Mode).Updates(map[string]interface{}{
+		"description":       item.Description,
+		"duration_seconds":  item.DurationSeconds,
+		"difficulties":      item.Difficulties,
+		"tags":              item.Tags,
+		"penalty_per_wrong": item.PenaltyPerWrong,
+		"freeze_minutes":    item.FreezeMinutes,
+		"sort":              item.Sort,
+	}).Error
+}
+
+func (r *TeamRoomModeRepo) Delete(mode string) (bool, error) {
+	result := r.DB.Where("mode = ?", mode).Delete(&model.TeamRoomMode{})
+	return result.RowsAffected > 0, result.Error
+}
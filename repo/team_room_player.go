@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"tgwp/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TeamRoomPlayerRepo struct {
+	DB *gorm.DB
+}
+
+func NewTeamRoomPlayerRepo(db *gorm.DB) *TeamRoomPlayerRepo {
+	return &TeamRoomPlayerRepo{DB: db}
+}
+
+// Save 玩家重新加入时恢复已删除的记录
+func (r *TeamRoomPlayerRepo) Save(item *model.TeamRoomPlayer) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"username", "team_id", "ready", "join_at", "updated_at", "deleted_at"}),
+	}).Create(item).Error
+}
+
+func (r *TeamRoomPlayerRepo) CreateBatch(items []model.TeamRoomPlayer) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error
+}
+
+func (r *TeamRoomPlayerRepo) Delete(roomID int64, userID int64) error {
+	return r.DB.Where("room_id = ? AND user_id = ?", roomID, userID).Delete(&model.TeamRoomPlayer{}).Error
+}
+
+func (r *TeamRoomPlayerRepo) ListByRooms(roomIDs []int64) ([]model.TeamRoomPlayer, error) {
+	var items []model.TeamRoomPlayer
+	if len(roomIDs) == 0 {
+		return items, nil
+	}
+	err := r.DB.Where("room_id IN ?", roomIDs).Order("join_at asc, id asc").Find(&items).Error
+	return items, err
+}
+
+// ListRoomIDsByUser 包含中途离开的房间，按房间创建先后倒序
+func (r *TeamRoomPlayerRepo) ListRoomIDsByUser(userID int64, offset, limit int) ([]int64, int64, error) {
+	query := r.DB.Unscoped().Model(&model.TeamRoomPlayer{}).Where("user_id = ?", userID)
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	var ids []int64
+	err := query.Order("room_id desc").Offset(offset).Limit(limit).Pluck("room_id", &ids).Error
+	return ids, count, err
+}
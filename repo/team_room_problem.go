@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"tgwp/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TeamRoomProblemRepo struct {
+	DB *gorm.DB
+}
+
+func NewTeamRoomProblemRepo(db *gorm.DB) *TeamRoomProblemRepo {
+	return &TeamRoomProblemRepo{DB: db}
+}
+
+func (r *TeamRoomProblemRepo) CreateBatch(items []model.TeamRoomProblem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error
+}
+
+func (r *TeamRoomProblemRepo) ListByRooms(roomIDs []int64) ([]model.TeamRoomProblem, error) {
+	var items []model.TeamRoomProblem
+	if len(roomIDs) == 0 {
+		return items, nil
+	}
+	err := r.DB.Where("room_id IN ?", roomIDs).Order("room_id asc, sort asc").Find(&items).Error
+	return items, err
+}
+
+type TeamRoomProblemStatusRepo struct {
+	DB *gorm.DB
+}
+
+func NewTeamRoomProblemStatusRepo(db *gorm.DB) *TeamRoomProblemStatusRepo {
+	return &TeamRoomProblemStatusRepo{DB: db}
+}
+
+func (r *TeamRoomProblemStatusRepo) CreateBatch(items []model.TeamRoomProblemStatus) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error
+}
+
+// Save 按房间、队伍、题目更新单条题目情况
+func (r *TeamRoomProblemStatusRepo) Save(item *model.TeamRoomProblemStatus) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_id"}, {Name: "team_id"}, {Name: "problem_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"solved", "solved_by", "solved_at", "penalty", "wrong_count", "updated_at"}),
+	}).Create(item).Error
+}
+
+func (r *TeamRoomProblemStatusRepo) ListByRooms(roomIDs []int64) ([]model.TeamRoomProblemStatus, error) {
+	var items []model.TeamRoomProblemStatus
+	if len(roomIDs) == 0 {
+		return items, nil
+	}
+	err := r.DB.Where("room_id IN ?", roomIDs).Order("id asc").Find(&items).Error
+	return items, err
+}
@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"tgwp/model"
+
+	"gorm.io/gorm"
+)
+
+type TeamRoomProblemSetRepo struct {
+	DB *gorm.DB
+}
+
+func NewTeamRoomProblemSetRepo(db *gorm.DB) *TeamRoomProblemSetRepo {
+	return &TeamRoomProblemSetRepo{DB: db}
+}
+
+func (r *TeamRoomProblemSetRepo) Create(item *model.TeamRoomProblemSet) error {
+	return r.DB.Create(item).Error
+}
+
+func (r *TeamRoomProblemSetRepo) GetByID(id int64) (model.TeamRoomProblemSet, error) {
+	var item model.TeamRoomProblemSet
+	err := r.DB.Where("id = ?", id).First(&item).Error
+	return item, err
+}
+
+func (r *TeamRoomProblemSetRepo) ListByCreator(creatorID int64, offset, limit int) ([]model.TeamRoomProblemSet, int64, error) {
+	query := r.DB.Model(&model.TeamRoomProblemSet{}).Where("creator_id = ?", creatorID)
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []model.TeamRoomProblemSet
+	err := query.Order("created_at desc").Offset(offset).Limit(limit).Find(&items).Error
+	return items, count, err
+}
+
+func (r *TeamRoomProblemSetRepo) Delete(id int64) error {
+	return r.DB.Where("id = ?", id).Delete(&model.TeamRoomProblemSet{}).Error
+}
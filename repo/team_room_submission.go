@@ -0,0 +1,36 @@
+package repo
+
+import (
+	"tgwp/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TeamRoomSubmissionRepo struct {
+	DB *gorm.DB
+}
+
+func NewTeamRoomSubmissionRepo(db *gorm.DB) *TeamRoomSubmissionRepo {
+	return &TeamRoomSubmissionRepo{DB: db}
+}
+
+func (r *TeamRoomSubmissionRepo) Create(item *model.TeamRoomSubmission) error {
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(item).Error
+}
+
+func (r *TeamRoomSubmissionRepo) CreateBatch(items []model.TeamRoomSubmission) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error
+}
+
+func (r *TeamRoomSubmissionRepo) ListByRooms(roomIDs []int64) ([]model.TeamRoomSubmission, error) {
+	var items []model.TeamRoomSubmission
+	if len(roomIDs) == 0 {
+		return items, nil
+	}
+	err := r.DB.Where("room_id IN ?", roomIDs).Order("submit_time asc, id asc").Find(&items).Error
+	return items, err
+}
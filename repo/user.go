@@ -25,6 +25,21 @@ func (r *UserRepo) GetByID(id int64) (model.User, error) {
 	return user, err
 }
 
+func (r *UserRepo) ListByIDs(ids []int64) ([]model.User, error) {
+	var users []model.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.DB.Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
+func (r *UserRepo) ListAll() ([]model.User, error) {
+	var users []model.User
+	err := r.DB.Select("id", "username", "rating").Find(&users).Error
+	return users, err
+}
+
 func (r *UserRepo) Create(user *model.User) error {
 	return r.DB.Create(user).Error
 }
@@ -0,0 +1,37 @@
+package repo
+
+import (
+	"tgwp/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UserTeamStatsRepo struct {
+	DB *gorm.DB
+}
+
+func NewUserTeamStatsRepo(db *gorm.DB) *UserTeamStatsRepo {
+	return &UserTeamStatsRepo{DB: db}
+}
+
+func (r *UserTeamStatsRepo) GetByUser(userID int64) (model.UserTeamStats, error) {
+	var item model.UserTeamStats
+	err := r.DB.Where("user_id = ?", userID).First(&item).Error
+	return item, err
+}
+
+// Accumulate 不存在时插入，存在时在原有数据上累加
+func (r *UserTeamStatsRepo) Accumulate(delta *model.UserTeamStats) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"rooms":        gorm.Expr("rooms + ?", delta.Rooms),
+			"wins":         gorm.Expr("wins + ?", delta.Wins),
+			"solved":       gorm.Expr("solved + ?", delta.Solved),
+			"wrong_count":  gorm.Expr("wrong_count + ?", delta.WrongCount),
+			"first_bloods": gorm.Expr("first_bloods + ?", delta.FirstBloods),
+			"points":       gorm.Expr("points + ?", delta.Points),
+		}),
+	}).Create(delta).Error
+}
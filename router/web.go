@@ -48,19 +48,61 @@ func registerRoutes(routeManager *manager.RouteManager) {
 		rg.POST("/profile", middleware.Limiter(rate.Every(time.Second)*3, 6), middleware.Authentication(global.ROLE_USER), api.UpdateProfile)
 		rg.GET("/user-info", middleware.Limiter(rate.Every(time.Second)*5, 10), api.GetUserInfo)
 		rg.GET("/ws", middleware.Authentication(global.ROLE_USER), api.WebsocketConnect)
+		rg.GET("/ws/catalog", api.GetWsCatalog)
 	})
 
 	routeManager.RegisterSinglePlayerRoutes(func(rg *gin.RouterGroup) {
 		rg.POST("/room", middleware.Authentication(global.ROLE_USER), api.CreateSinglePlayerRoom)
 		rg.GET("/room", api.GetSinglePlayerRoomInfo)
 		rg.POST("/room/abandon", middleware.Authentication(global.ROLE_USER), api.AbandonSinglePlayerRoom)
+		rg.POST("/room/reroll", middleware.Limiter(rate.Every(time.Second), 3), middleware.Authentication(global.ROLE_USER), api.RerollSinglePlayerRoom)
 	})
 
 	routeManager.RegisterTeamRoomRoutes(func(rg *gin.RouterGroup) {
 		rg.POST("/room", middleware.Authentication(global.ROLE_USER), api.CreateTeamRoom)
 		rg.GET("/room", api.GetTeamRoomInfo)
+		rg.GET("/room/events", middleware.Authentication(global.ROLE_USER), api.ListTeamRoomEvents)
+		rg.GET("/room/export", middleware.Limiter(rate.Every(time.Second), 3), middleware.Authentication(global.ROLE_USER), api.ExportTeamRoom)
+		rg.GET("/room/chat", middleware.Limiter(rate.Every(time.Second)*5, 10), middleware.Authentication(global.ROLE_USER), api.ListTeamRoomChat)
+		rg.GET("/room/invite", middleware.Authentication(global.ROLE_USER), api.GetTeamRoomInvite)
 		rg.GET("/rooms", api.ListTeamRooms)
+		rg.GET("/user-rooms", api.ListUserTeamRooms)
 		rg.GET("/modes", api.ListTeamRoomModes)
+		rg.GET("/stats", api.GetUserTeamStats)
+		rg.POST("/mode", middleware.Authentication(global.ROLE_ADMIN), api.CreateTeamRoomMode)
+		rg.POST("/mode/update", middleware.Authentication(global.ROLE_ADMIN), api.UpdateTeamRoomMode)
+		rg.POST("/mode/delete", middleware.Authentication(global.ROLE_ADMIN), api.DeleteTeamRoomMode)
+		rg.POST("/problem-set", middleware.Authentication(global.ROLE_USER), api.CreateTeamRoomProblemSet)
+		rg.GET("/problem-set", middleware.Authentication(global.ROLE_USER), api.GetTeamRoomProblemSet)
+		rg.GET("/problem-sets", middleware.Authentication(global.ROLE_USER), api.ListTeamRoomProblemSets)
+		rg.POST("/problem-set/delete", middleware.Authentication(global.ROLE_USER), api.DeleteTeamRoomProblemSet)
+	})
+
+	routeManager.RegisterDailyChallengeRoutes(func(rg *gin.RouterGroup) {
+		rg.GET("/today", api.GetDailyChallenge)
+		rg.POST("/room", middleware.Authentication(global.ROLE_USER), api.CreateDailyChallengeRoom)
+		rg.GET("/leaderboard", api.GetDailyChallengeLeaderboard)
+		rg.GET("/streak", api.GetDailyChallengeStreak)
+	})
+
+	routeManager.RegisterAchievementRoutes(func(rg *gin.RouterGroup) {
+		rg.GET("/list", api.ListAchievements)
+		rg.GET("/user", api.ListUserAchievements)
+	})
+
+	routeManager.RegisterLeaderboardRoutes(func(rg *gin.RouterGroup) {
+		rg.GET("/list", middleware.Limiter(rate.Every(time.Second)*5, 10), api.ListLeaderboard)
+		rg.GET("/rank", middleware.Authentication(global.ROLE_USER), api.GetLeaderboardRank)
+	})
+
+	routeManager.RegisterSeriesRoutes(func(rg *gin.RouterGroup) {
+		rg.POST("/create", middleware.Authentication(global.ROLE_USER), api.CreateSeries)
+		rg.GET("/info", api.GetSeries)
+		rg.GET("/list", api.ListSeries)
+		rg.GET("/standings", middleware.Limiter(rate.Every(time.Second)*5, 10), api.GetSeriesStandings)
+		rg.POST("/round", middleware.Authentication(global.ROLE_USER), api.StartSeriesRound)
+		rg.POST("/room", middleware.Authentication(global.ROLE_USER), api.AddSeriesRoom)
+		rg.POST("/play", middleware.Authentication(global.ROLE_USER), api.PlaySeriesRound)
 	})
 
 	routeManager.RegisterLoginRoutes(func(rg *gin.RouterGroup) {
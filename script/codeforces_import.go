@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"tgwp/global"
 	"tgwp/initalize"
 	"tgwp/log/zlog"
@@ -25,9 +26,10 @@ type cfResponse struct {
 }
 
 type cfProblem struct {
-	ContestID int    `json:"contestId"`
-	Index     string `json:"index"`
-	Rating    int    `json:"rating"`
+	ContestID int      `json:"contestId"`
+	Index     string   `json:"index"`
+	Rating    int      `json:"rating"`
+	Tags      []string `json:"tags"`
 }
 
 func main() {
@@ -51,6 +53,7 @@ func main() {
 			ID:         fmt.Sprintf("%d%s", p.ContestID, p.Index),
 			Url:        url,
 			Difficulty: p.Rating,
+			Tags:       formatTags(p.Tags),
 		})
 	}
 
@@ -66,7 +69,10 @@ func main() {
 			end = len(items)
 		}
 		err = global.DB.WithContext(ctx).
-			Clauses(clause.OnConflict{DoNothing: true}).
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"difficulty", "tags"}),
+			}).
 			Create(items[i:end]).Error
 		if err != nil {
 			zlog.CtxErrorf(ctx, "写入题目失败：%v", err)
@@ -77,6 +83,14 @@ func main() {
 	zlog.CtxInfof(ctx, "写入完成，总数：%d", len(items))
 }
 
+// formatTags 首尾加逗号便于按 ,tag, 模糊匹配
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "," + strings.Join(tags, ",") + ","
+}
+
 func fetchProblems(ctx context.Context) ([]cfProblem, error) {
 	client := &http.Client{
 		Timeout: 30 * time.Second,
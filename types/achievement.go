@@ -0,0 +1,28 @@
+package types
+
+type AchievementInfo struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+	Trigger     string `json:"trigger"`
+	Threshold   int    `json:"threshold"`
+}
+
+type AchievementListResp struct {
+	Achievements []AchievementInfo `json:"achievements"`
+}
+
+type UserAchievementListReq struct {
+	UserID int64 `json:"user_id" form:"user_id"`
+}
+
+type UserAchievementListResp struct {
+	Achievements []UserAchievementInfo `json:"achievements"`
+}
+
+type UserAchievementInfo struct {
+	AchievementInfo
+	RoomID     int64 `json:"room_id,string"`
+	UnlockedAt int64 `json:"unlocked_at"`
+}
@@ -0,0 +1,50 @@
+package types
+
+type DailyChallengeInfo struct {
+	Date        string `json:"date"`
+	ProblemID   string `json:"problem_id"`
+	ProblemURL  string `json:"problem_url"`
+	Difficulty  int    `json:"difficulty"`
+	SolvedCount int64  `json:"solved_count"`
+}
+
+type DailyChallengeTodayResp struct {
+	Challenge DailyChallengeInfo `json:"challenge"`
+}
+
+type DailyChallengeRoomResp struct {
+	Challenge DailyChallengeInfo   `json:"challenge"`
+	Room      SinglePlayerRoomInfo `json:"room"`
+}
+
+type DailyChallengeLeaderboardReq struct {
+	Date  string `form:"date" json:"date"`
+	Page  int    `form:"page" json:"page"`
+	Limit int    `form:"limit" json:"limit"`
+}
+
+type DailyChallengeLeaderboardResp struct {
+	Date  string                          `json:"date"`
+	Total int64                           `json:"total"`
+	Items []DailyChallengeLeaderboardItem `json:"items"`
+}
+
+type DailyChallengeLeaderboardItem struct {
+	Rank         int    `json:"rank"`
+	UserID       int64  `json:"user_id,string"`
+	Username     string `json:"username"`
+	SolveSeconds int64  `json:"solve_seconds"`
+	Penalty      int    `json:"penalty"`
+	Score        int64  `json:"score"`
+}
+
+type DailyChallengeStreakReq struct {
+	UserID int64 `json:"user_id" form:"user_id"`
+}
+
+type DailyChallengeStreakResp struct {
+	CurrentStreak int  `json:"current_streak"`
+	LongestStreak int  `json:"longest_streak"`
+	TotalSolved   int  `json:"total_solved"`
+	TodaySolved   bool `json:"today_solved"`
+}
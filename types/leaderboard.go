@@ -0,0 +1,33 @@
+package types
+
+type LeaderboardListReq struct {
+	Type  string `form:"type" json:"type"`
+	Page  int    `form:"page" json:"page"`
+	Limit int    `form:"limit" json:"limit"`
+}
+
+type LeaderboardListResp struct {
+	Type  string            `json:"type"`
+	Total int64             `json:"total"`
+	Items []LeaderboardItem `json:"items"`
+}
+
+type LeaderboardItem struct {
+	Rank     int64  `json:"rank"`
+	UserID   int64  `json:"user_id,string"`
+	Username string `json:"username"`
+	Score    int64  `json:"score"`
+}
+
+type LeaderboardRankReq struct {
+	UserID int64  `json:"-" form:"-"`
+	Type   string `form:"type" json:"type"`
+}
+
+type LeaderboardRankResp struct {
+	Type   string `json:"type"`
+	Ranked bool   `json:"ranked"`
+	Rank   int64  `json:"rank"`
+	Score  int64  `json:"score"`
+	Total  int64  `json:"total"`
+}
@@ -0,0 +1,9 @@
+package types
+
+type RoomTimerData struct {
+	RoomID     int64  `json:"room_id,string"`
+	RoomType   string `json:"room_type"`
+	Remaining  int64  `json:"remaining"`
+	EndAt      int64  `json:"end_at"`
+	ServerTime int64  `json:"server_time"`
+}
@@ -0,0 +1,108 @@
+package types
+
+// SeriesCreateReq RoomType 为 team 时每轮按 Room 创建团队房间，为 single 时每轮从难度范围内选一道题
+type SeriesCreateReq struct {
+	UserID        int64              `json:"-" form:"-"`
+	Name          string             `json:"name"`
+	Description   string             `json:"description"`
+	RoomType      string             `json:"room_type"`
+	Aggregation   string             `json:"aggregation"`
+	BestN         int                `json:"best_n"`
+	RankPoints    []int              `json:"rank_points"`
+	Room          *TeamRoomCreateReq `json:"room"`
+	MinDifficulty int                `json:"min_difficulty"`
+	MaxDifficulty int                `json:"max_difficulty"`
+}
+
+type SeriesInfo struct {
+	ID            int64             `json:"id,string"`
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	CreatorID     int64             `json:"creator_id,string"`
+	RoomType      string            `json:"room_type"`
+	Aggregation   string            `json:"aggregation"`
+	BestN         int               `json:"best_n,omitempty"`
+	RankPoints    []int             `json:"rank_points,omitempty"`
+	Mode          string            `json:"mode,omitempty"`
+	MinDifficulty int               `json:"min_difficulty,omitempty"`
+	MaxDifficulty int               `json:"max_difficulty,omitempty"`
+	CurrentRound  int               `json:"current_round"`
+	Rounds        []SeriesRoundInfo `json:"rounds,omitempty"`
+	CreatedAt     int64             `json:"created_at"`
+}
+
+// SeriesRoundInfo RoomIDs 为本轮包含的房间，单人系列每个参赛玩家各一个
+type SeriesRoundInfo struct {
+	Round     int      `json:"round"`
+	ProblemID string   `json:"problem_id,omitempty"`
+	RoomIDs   []string `json:"room_ids"`
+	StartedAt int64    `json:"started_at"`
+}
+
+type SeriesReq struct {
+	ID string `json:"id" form:"id"`
+}
+
+type SeriesListReq struct {
+	Page  int `json:"page" form:"page"`
+	Limit int `json:"limit" form:"limit"`
+}
+
+type SeriesListResp struct {
+	Total int64        `json:"total"`
+	Items []SeriesInfo `json:"items"`
+}
+
+// SeriesRoundReq StartAt 仅团队系列使用，为0时房间创建后等待房主开始
+type SeriesRoundReq struct {
+	SeriesID string `json:"series_id"`
+	StartAt  int64  `json:"start_at"`
+}
+
+type SeriesRoundResp struct {
+	Round    SeriesRoundInfo     `json:"round"`
+	TeamRoom *TeamRoomCreateResp `json:"team_room,omitempty"`
+}
+
+// SeriesAddRoomReq Round 为0时加入当前轮次
+type SeriesAddRoomReq struct {
+	SeriesID string `json:"series_id"`
+	RoomID   string `json:"room_id"`
+	Round    int    `json:"round"`
+}
+
+type SeriesPlayReq struct {
+	UserID   int64  `json:"-" form:"-"`
+	SeriesID string `json:"series_id"`
+}
+
+type SeriesPlayResp struct {
+	Round int                  `json:"round"`
+	Room  SinglePlayerRoomInfo `json:"room"`
+}
+
+type SeriesStandingsResp struct {
+	SeriesID    int64                `json:"series_id,string"`
+	Aggregation string               `json:"aggregation"`
+	Rounds      int                  `json:"rounds"`
+	Items       []SeriesStandingItem `json:"items"`
+}
+
+type SeriesStandingItem struct {
+	Rank     int                `json:"rank"`
+	UserID   int64              `json:"user_id,string"`
+	Username string             `json:"username"`
+	Total    int64              `json:"total"`
+	Played   int                `json:"played"`
+	Rounds   []SeriesRoundScore `json:"rounds"`
+}
+
+// SeriesRoundScore Points 为按积分方式折算后计入总分的分数，未计入时为0
+type SeriesRoundScore struct {
+	Round   int   `json:"round"`
+	Score   int64 `json:"score"`
+	Penalty int64 `json:"penalty"`
+	Rank    int   `json:"rank"`
+	Points  int64 `json:"points"`
+	Counted bool  `json:"counted"`
+}
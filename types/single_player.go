@@ -25,6 +25,14 @@ type SinglePlayerAbandonResp struct {
 	Room SinglePlayerRoomInfo `json:"room"`
 }
 
+type SinglePlayerRerollReq struct {
+	RoomID string `json:"room_id" form:"room_id"`
+}
+
+type SinglePlayerRerollResp struct {
+	Room SinglePlayerRoomInfo `json:"room"`
+}
+
 type SinglePlayerRoomInfo struct {
 	RoomID          int64     `json:"room_id,string"`
 	UserID          int64     `json:"user_id,string"`
@@ -39,6 +47,8 @@ type SinglePlayerRoomInfo struct {
 	CreatedAt       time.Time `json:"created_at"`
 	EndTime         int64  `json:"end_time"`
 	Submissions     []RoomSubmissionRecord `json:"submissions"`
+	ChallengeDate   string    `json:"challenge_date"`
+	Rerolls         []RoomRerollRecord `json:"rerolls"`
 }
 
 type RoomSubmissionRecord struct {
@@ -46,3 +56,10 @@ type RoomSubmissionRecord struct {
 	Verdict      string `json:"verdict"`
 	SubmitTime   int64  `json:"submit_time"`
 }
+
+type RoomRerollRecord struct {
+	FromProblemID string `json:"from_problem_id"`
+	ToProblemID   string `json:"to_problem_id"`
+	Cost          int    `json:"cost"`
+	RerollTime    int64  `json:"reroll_time"`
+}
@@ -1,13 +1,57 @@
 package types
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type TeamRoomCreateReq struct {
-	Mode string `json:"mode" form:"mode"`
+	Mode        string   `json:"mode" form:"mode"`
+	Competitive bool     `json:"competitive" form:"competitive"`
+	Teams       []string `json:"teams" form:"teams"`
+	Private     bool     `json:"private" form:"private"`
+	MaxPlayers  int      `json:"max_players" form:"max_players"`
+	StartAt     int64    `json:"start_at" form:"start_at"`
+	// Custom 不为空时按自定义配置创建，忽略 Mode
+	Custom *TeamRoomCustomConfig `json:"custom" form:"-"`
+	// ProblemIDs 或 ProblemSetID 指定题目时忽略 Mode，Custom 中只使用时长和罚时
+	ProblemIDs     []string `json:"problem_ids" form:"problem_ids"`
+	ProblemSetID   string   `json:"problem_set_id" form:"problem_set_id"`
+	HideDifficulty bool     `json:"hide_difficulty" form:"hide_difficulty"`
+	// SpectatorDelay 观战推送延迟秒数，SpectatorHideChat 为 true 时观战者不接收也不能发送聊天
+	SpectatorDelay    int64 `json:"spectator_delay" form:"spectator_delay"`
+	SpectatorHideChat bool  `json:"spectator_hide_chat" form:"spectator_hide_chat"`
+}
+
+type TeamRoomCustomConfig struct {
+	Difficulties    []int    `json:"difficulties"`
+	Duration        int64    `json:"duration"`
+	Tags            []string `json:"tags"`
+	PenaltyPerWrong *int     `json:"penalty_per_wrong"`
+	FreezeMinutes   int      `json:"freeze_minutes"`
 }
 
 type TeamRoomCreateResp struct {
-	Room TeamRoomInfo `json:"room"`
+	Room   TeamRoomInfo        `json:"room"`
+	Invite *TeamRoomInviteInfo `json:"invite,omitempty"`
+}
+
+type TeamRoomInviteReq struct {
+	UserID int64  `json:"-" form:"-"`
+	RoomID string `form:"room_id" json:"room_id"`
+}
+
+type TeamRoomInviteInfo struct {
+	RoomID      int64  `json:"room_id,string"`
+	InviteCode  string `json:"invite_code"`
+	InviteToken string `json:"invite_token"`
+	ExpireAt    int64  `json:"expire_at"`
+}
+
+// TeamRoomInviteCredential 加入私密房间时提供邀请码或签名邀请链接中的token
+type TeamRoomInviteCredential struct {
+	InviteCode  string `json:"invite_code" form:"invite_code"`
+	InviteToken string `json:"invite_token" form:"invite_token"`
 }
 
 type TeamRoomInfoReq struct {
@@ -24,6 +68,12 @@ type TeamRoomListReq struct {
 	Status *int8 `form:"status" json:"status"`
 }
 
+type TeamRoomUserRoomsReq struct {
+	UserID int64 `form:"user_id" json:"user_id"`
+	Page   int   `form:"page" json:"page"`
+	Limit  int   `form:"limit" json:"limit"`
+}
+
 type TeamRoomListResp struct {
 	Total int64              `json:"total"`
 	Rooms []TeamRoomListItem `json:"rooms"`
@@ -34,9 +84,67 @@ type TeamRoomModeListResp struct {
 }
 
 type TeamRoomModeInfo struct {
-	Mode     string `json:"mode"`
-	Duration int64  `json:"duration"`
-	Problems []int  `json:"problems"`
+	Mode            string   `json:"mode"`
+	Description     string   `json:"description"`
+	Duration        int64    `json:"duration"`
+	Problems        []int    `json:"problems"`
+	Tags            []string `json:"tags"`
+	PenaltyPerWrong int      `json:"penalty_per_wrong"`
+	FreezeMinutes   int      `json:"freeze_minutes"`
+	Sort            int      `json:"sort"`
+}
+
+type TeamRoomModeReq struct {
+	Mode            string   `json:"mode" form:"mode"`
+	Description     string   `json:"description" form:"description"`
+	Duration        int64    `json:"duration" form:"duration"`
+	Difficulties    []int    `json:"difficulties" form:"difficulties"`
+	Tags            []string `json:"tags" form:"tags"`
+	PenaltyPerWrong *int     `json:"penalty_per_wrong" form:"penalty_per_wrong"`
+	FreezeMinutes   int      `json:"freeze_minutes" form:"freeze_minutes"`
+	Sort            int      `json:"sort" form:"sort"`
+}
+
+type TeamRoomProblemSetCreateReq struct {
+	UserID         int64    `json:"-" form:"-"`
+	Name           string   `json:"name" form:"name"`
+	Description    string   `json:"description" form:"description"`
+	ProblemIDs     []string `json:"problem_ids" form:"problem_ids"`
+	HideDifficulty bool     `json:"hide_difficulty" form:"hide_difficulty"`
+}
+
+type TeamRoomProblemSetReq struct {
+	UserID int64  `json:"-" form:"-"`
+	ID     string `json:"id" form:"id"`
+}
+
+type TeamRoomProblemSetListReq struct {
+	UserID int64 `json:"-" form:"-"`
+	Page   int   `json:"page" form:"page"`
+	Limit  int   `json:"limit" form:"limit"`
+}
+
+type TeamRoomProblemSetListResp struct {
+	Total int64                    `json:"total"`
+	Items []TeamRoomProblemSetInfo `json:"items"`
+}
+
+type TeamRoomProblemSetInfo struct {
+	ID             int64     `json:"id,string"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	ProblemIDs     []string  `json:"problem_ids"`
+	HideDifficulty bool      `json:"hide_difficulty"`
+	CreatorID      int64     `json:"creator_id,string"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type TeamRoomModeDeleteReq struct {
+	Mode string `json:"mode" form:"mode"`
+}
+
+type TeamRoomModeDeleteResp struct {
+	Mode string `json:"mode"`
 }
 
 type TeamRoomListItem struct {
@@ -47,25 +155,112 @@ type TeamRoomListItem struct {
 	EndTime      int64     `json:"end_time"`
 	PlayerCount  int       `json:"player_count"`
 	ProblemCount int       `json:"problem_count"`
+	Private      bool      `json:"private"`
+	Locked       bool      `json:"locked"`
+	MaxPlayers   int       `json:"max_players"`
 }
 
 type TeamRoomInfo struct {
-	RoomID      int64                    `json:"room_id,string"`
-	Mode        string                   `json:"mode"`
-	Status      int8                     `json:"status"`
-	CreatedAt   time.Time                `json:"created_at"`
-	EndTime     int64                    `json:"end_time"`
-	Players     []TeamRoomPlayerInfo     `json:"players"`
-	Problems    []TeamRoomProblemInfo    `json:"problems"`
-	Submissions []TeamRoomSubmissionInfo `json:"submissions"`
-	Score       int64                    `json:"score"`
-	Duration    int64                    `json:"duration"`
+	RoomID            int64                    `json:"room_id,string"`
+	Mode              string                   `json:"mode"`
+	Status            int8                     `json:"status"`
+	CreatedAt         time.Time                `json:"created_at"`
+	StartTime         int64                    `json:"start_time"`
+	StartAt           int64                    `json:"start_at"`
+	EndTime           int64                    `json:"end_time"`
+	Players           []TeamRoomPlayerInfo     `json:"players"`
+	Problems          []TeamRoomProblemInfo    `json:"problems"`
+	Submissions       []TeamRoomSubmissionInfo `json:"submissions"`
+	Score             int64                    `json:"score"`
+	Duration          int64                    `json:"duration"`
+	Tags              []string                 `json:"tags,omitempty"`
+	PenaltyPerWrong   int                      `json:"penalty_per_wrong"`
+	HideDifficulty    bool                     `json:"hide_difficulty"`
+	Competitive       bool                     `json:"competitive"`
+	CreatorID         int64                    `json:"creator_id,string"`
+	Private           bool                     `json:"private"`
+	Locked            bool                     `json:"locked"`
+	MaxPlayers        int                      `json:"max_players"`
+	Spectators        int                      `json:"spectators"`
+	SpectatorDelay    int64                    `json:"spectator_delay"`
+	SpectatorHideChat bool                     `json:"spectator_hide_chat"`
+	Paused            bool                     `json:"paused"`
+	PausedSeconds     int64                    `json:"paused_seconds"`
+	EndAt             int64                    `json:"end_at,omitempty"`
+	Claims            []TeamRoomClaimInfo      `json:"claims,omitempty"`
+	Frozen            bool                     `json:"frozen"`
+	FreezeAt          int64                    `json:"freeze_at,omitempty"`
+	Teams             []TeamRoomTeamInfo       `json:"teams,omitempty"`
+	Scoreboard        []TeamRoomScoreboardItem `json:"scoreboard,omitempty"`
+	PlayerStats       []TeamRoomPlayerStat     `json:"player_stats,omitempty"`
+}
+
+// TeamRoomPlayerStat 得分为通过题目难度之和，ScoreShare 为占所在队伍得分的比例
+type TeamRoomPlayerStat struct {
+	UserID       int64   `json:"user_id,string"`
+	Username     string  `json:"username"`
+	TeamID       int     `json:"team_id"`
+	Solved       int     `json:"solved"`
+	WrongCount   int     `json:"wrong_count"`
+	FirstSolveAt int64   `json:"first_solve_at"`
+	Points       int64   `json:"points"`
+	ScoreShare   float64 `json:"score_share"`
+}
+
+type UserTeamStatsReq struct {
+	UserID int64 `json:"user_id" form:"user_id"`
+}
+
+type UserTeamStatsResp struct {
+	UserID      int64 `json:"user_id,string"`
+	Rooms       int   `json:"rooms"`
+	Wins        int   `json:"wins"`
+	Solved      int   `json:"solved"`
+	WrongCount  int   `json:"wrong_count"`
+	FirstBloods int   `json:"first_bloods"`
+	Points      int64 `json:"points"`
+}
+
+type TeamRoomTeamInfo struct {
+	TeamID int    `json:"team_id"`
+	Name   string `json:"name"`
+}
+
+type TeamRoomScoreboardItem struct {
+	Rank     int                         `json:"rank"`
+	TeamID   int                         `json:"team_id"`
+	Name     string                      `json:"name"`
+	Solved   int                         `json:"solved"`
+	Penalty  int64                       `json:"penalty"`
+	Problems []TeamRoomScoreboardProblem `json:"problems"`
+}
+
+type TeamRoomScoreboardProblem struct {
+	ProblemID  string `json:"problem_id"`
+	Solved     bool   `json:"solved"`
+	SolvedAt   int64  `json:"solved_at"`
+	WrongCount int    `json:"wrong_count"`
+	Pending    int    `json:"pending,omitempty"`
+}
+
+// TeamRoomRevealStep 滚榜的一步，Rank 为揭晓后该队伍的排名
+type TeamRoomRevealStep struct {
+	TeamID     int    `json:"team_id"`
+	ProblemID  string `json:"problem_id"`
+	Solved     bool   `json:"solved"`
+	SolvedAt   int64  `json:"solved_at"`
+	WrongCount int    `json:"wrong_count"`
+	Rank       int    `json:"rank"`
 }
 
 type TeamRoomPlayerInfo struct {
-	UserID   int64  `json:"user_id,string"`
-	Username string `json:"username"`
-	JoinAt   int64  `json:"join_at"`
+	UserID        int64  `json:"user_id,string"`
+	Username      string `json:"username"`
+	JoinAt        int64  `json:"join_at"`
+	TeamID        int    `json:"team_id"`
+	Ready         bool   `json:"ready"`
+	Presence      string `json:"presence"`
+	PresenceSince int64  `json:"presence_since"`
 }
 
 type TeamRoomProblemInfo struct {
@@ -78,16 +273,28 @@ type TeamRoomProblemInfo struct {
 	SolvedAt   int64  `json:"solved_at"`
 }
 
+// TeamRoomClaimInfo State 为 reading、coding 或 stuck
+type TeamRoomClaimInfo struct {
+	ProblemID string `json:"problem_id"`
+	TeamID    int    `json:"team_id"`
+	UserID    int64  `json:"user_id,string"`
+	State     string `json:"state"`
+	ClaimedAt int64  `json:"claimed_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
 type TeamRoomSubmissionInfo struct {
 	SubmissionID int64  `json:"submission_id,string"`
 	ProblemID    string `json:"problem_id"`
 	UserID       int64  `json:"user_id,string"`
+	TeamID       int    `json:"team_id"`
 	Verdict      string `json:"verdict"`
 	SubmitTime   int64  `json:"submit_time"`
 }
 
 type TeamRoomWsJoinReq struct {
 	RoomID string `json:"room_id"`
+	TeamRoomInviteCredential
 }
 
 type TeamRoomWsLeaveReq struct {
@@ -98,3 +305,143 @@ type TeamRoomWsChatReq struct {
 	RoomID  string `json:"room_id"`
 	Content string `json:"content"`
 }
+
+type TeamRoomWsClaimReq struct {
+	RoomID    string `json:"room_id"`
+	ProblemID string `json:"problem_id"`
+	State     string `json:"state"`
+}
+
+type TeamRoomWsChatDeleteReq struct {
+	RoomID    string `json:"room_id"`
+	MessageID string `json:"message_id"`
+}
+
+type TeamRoomChatInfo struct {
+	ID       int64  `json:"id,string"`
+	RoomID   int64  `json:"room_id,string"`
+	UserID   int64  `json:"user_id,string"`
+	Username string `json:"username"`
+	Content  string `json:"content"`
+	Ts       int64  `json:"ts"`
+}
+
+type TeamRoomChatDeleteInfo struct {
+	RoomID    int64 `json:"room_id,string"`
+	MessageID int64 `json:"message_id,string"`
+	DeletedBy int64 `json:"deleted_by,string"`
+}
+
+// TeamRoomChatHistoryReq BeforeID 为空时从最新一条开始向前翻页
+type TeamRoomChatHistoryReq struct {
+	UserID   int64  `json:"-" form:"-"`
+	RoomID   string `json:"room_id" form:"room_id"`
+	BeforeID string `json:"before_id" form:"before_id"`
+	Limit    int    `json:"limit" form:"limit"`
+}
+
+type TeamRoomChatHistoryResp struct {
+	Messages []TeamRoomChatInfo `json:"messages"`
+	HasMore  bool               `json:"has_more"`
+}
+
+type TeamRoomWsTeamCreateReq struct {
+	RoomID string `json:"room_id"`
+	Name   string `json:"name"`
+}
+
+type TeamRoomWsTeamJoinReq struct {
+	RoomID string `json:"room_id"`
+	TeamID int    `json:"team_id"`
+}
+
+type TeamRoomWsKickReq struct {
+	RoomID string `json:"room_id"`
+	UserID string `json:"user_id"`
+}
+
+type TeamRoomWsTransferReq struct {
+	RoomID string `json:"room_id"`
+	UserID string `json:"user_id"`
+}
+
+type TeamRoomWsLockReq struct {
+	RoomID string `json:"room_id"`
+	Locked bool   `json:"locked"`
+}
+
+type TeamRoomWsPauseReq struct {
+	RoomID string `json:"room_id"`
+}
+
+type TeamRoomWsExtendReq struct {
+	RoomID  string `json:"room_id"`
+	Seconds int64  `json:"seconds"`
+}
+
+// TeamRoomTimerInfo 结束时间已计入暂停时间，暂停中 Remaining 保持不变
+type TeamRoomTimerInfo struct {
+	RoomID        int64 `json:"room_id,string"`
+	Paused        bool  `json:"paused"`
+	PausedAt      int64 `json:"paused_at"`
+	PausedSeconds int64 `json:"paused_seconds"`
+	Duration      int64 `json:"duration"`
+	StartTime     int64 `json:"start_time"`
+	EndAt         int64 `json:"end_at"`
+	Remaining     int64 `json:"remaining"`
+	ServerTime    int64 `json:"server_time"`
+}
+
+type TeamRoomWsEndReq struct {
+	RoomID string `json:"room_id"`
+}
+
+type TeamRoomWsReadyReq struct {
+	RoomID string `json:"room_id"`
+	Ready  bool   `json:"ready"`
+}
+
+type TeamRoomWsStartReq struct {
+	RoomID string `json:"room_id"`
+}
+
+type TeamRoomEventReq struct {
+	UserID int64  `json:"-" form:"-"`
+	RoomID string `form:"room_id" json:"room_id"`
+}
+
+// TeamRoomExportReq Format 为 csv、json 或 feed，默认 csv
+type TeamRoomExportReq struct {
+	UserID int64  `json:"-" form:"-"`
+	RoomID string `form:"room_id" json:"room_id"`
+	Format string `form:"format" json:"format"`
+}
+
+type TeamRoomExportResp struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+type TeamRoomExportData struct {
+	Room       TeamRoomInfo             `json:"room"`
+	Standings  []TeamRoomScoreboardItem `json:"standings"`
+	ExportedAt int64                    `json:"exported_at"`
+}
+
+// TeamRoomEventInfo Offset 为相对房间开始的秒数，等待阶段的事件为负数
+type TeamRoomEventInfo struct {
+	Type    string          `json:"type"`
+	UserID  int64           `json:"user_id,string"`
+	TeamID  int             `json:"team_id"`
+	EventAt int64           `json:"event_at"`
+	Offset  int64           `json:"offset"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type TeamRoomEventResp struct {
+	RoomID    int64               `json:"room_id,string"`
+	StartTime int64               `json:"start_time"`
+	EndTime   int64               `json:"end_time"`
+	Events    []TeamRoomEventInfo `json:"events"`
+}
@@ -2,14 +2,165 @@ package types
 
 import "encoding/json"
 
+// WsRequest ID 由客户端生成，服务端在对应的 ack、error 和直接回复中原样返回
 type WsRequest struct {
+	ID   string          `json:"id,omitempty"`
 	Type string          `json:"type"`
 	Data json.RawMessage `json:"data"`
 }
 
+// WsResponse RoomSeq、UserSeq 分别为房间推送和用户推送的递增序号，重连时通过 resume 补发
 type WsResponse struct {
+	ID      string      `json:"id,omitempty"`
 	Type    string      `json:"type"`
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
+	RoomSeq int64       `json:"room_seq,omitempty"`
+	UserSeq int64       `json:"user_seq,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 }
+
+// WsHelloData 协议版本2及以上在连接建立后首先推送
+type WsHelloData struct {
+	Version    int   `json:"version"`
+	MinVersion int   `json:"min_version"`
+	MaxVersion int   `json:"max_version"`
+	ServerTime int64 `json:"server_time"`
+}
+
+// WsAckData Type 为被确认的请求类型
+type WsAckData struct {
+	Type string `json:"type"`
+}
+
+type WsErrorData struct {
+	Type string `json:"type,omitempty"`
+}
+
+// WsResumeReq 序号为客户端最后收到的推送序号，RoomID 为空时使用连接绑定的房间
+type WsResumeReq struct {
+	RoomID  string `json:"room_id"`
+	RoomSeq int64  `json:"room_seq"`
+	UserSeq int64  `json:"user_seq"`
+}
+
+// WsResumeData Resync 为 true 时缺失的推送已无法补发，客户端需要重新拉取完整数据
+type WsResumeData struct {
+	RoomID     int64 `json:"room_id,string,omitempty"`
+	RoomSeq    int64 `json:"room_seq"`
+	UserSeq    int64 `json:"user_seq"`
+	Replayed   int   `json:"replayed"`
+	RoomResync bool  `json:"room_resync"`
+	UserResync bool  `json:"user_resync"`
+}
+
+type WsPongData struct {
+	Ts int64 `json:"ts"`
+}
+
+type TeamRoomMemberUpdateData struct {
+	Room     TeamRoomInfo `json:"room"`
+	Action   string       `json:"action"`
+	UserID   int64        `json:"user_id,string"`
+	Operator int64        `json:"operator,string,omitempty"`
+}
+
+type TeamRoomSpectateData struct {
+	Room TeamRoomInfo `json:"room"`
+}
+
+type SingleRoomUpdateData struct {
+	Room        SinglePlayerRoomInfo `json:"room"`
+	LastVerdict string               `json:"last_verdict"`
+}
+
+// SingleRoomInfoData 用于 single_room_reroll 和 single_room_finish
+type SingleRoomInfoData struct {
+	Room SinglePlayerRoomInfo `json:"room"`
+}
+
+// TeamRoomInfoData 用于 team_room_start
+type TeamRoomInfoData struct {
+	Room TeamRoomInfo `json:"room"`
+}
+
+// TeamRoomUpdateData 封榜期间其他队伍的 LastVerdict 会被隐藏
+type TeamRoomUpdateData struct {
+	Room        TeamRoomInfo `json:"room"`
+	UserID      int64        `json:"user_id,string"`
+	ProblemID   string       `json:"problem_id"`
+	LastVerdict string       `json:"last_verdict"`
+}
+
+// TeamRoomClaimUpdateData 对抗模式下其他队伍只收到自己队伍的认领列表，不包含本次变化的内容
+type TeamRoomClaimUpdateData struct {
+	RoomID    int64               `json:"room_id"`
+	Action    string              `json:"action,omitempty"`
+	UserID    int64               `json:"user_id,string,omitempty"`
+	ProblemID string              `json:"problem_id,omitempty"`
+	Claims    []TeamRoomClaimInfo `json:"claims"`
+}
+
+// TeamRoomPresenceData GraceUntil 仅在离线宽限期内返回
+type TeamRoomPresenceData struct {
+	RoomID     int64  `json:"room_id"`
+	UserID     int64  `json:"user_id,string"`
+	State      string `json:"state"`
+	Ts         int64  `json:"ts"`
+	GraceUntil int64  `json:"grace_until,omitempty"`
+}
+
+type TeamRoomSpectatorUpdateData struct {
+	RoomID     int64 `json:"room_id"`
+	Spectators int   `json:"spectators"`
+}
+
+type TeamRoomCountdownData struct {
+	RoomID     int64 `json:"room_id"`
+	StartAt    int64 `json:"start_at"`
+	Remaining  int64 `json:"remaining"`
+	ServerTime int64 `json:"server_time"`
+}
+
+type TeamRoomScoreboardData struct {
+	RoomID     int64                    `json:"room_id"`
+	Frozen     bool                     `json:"frozen"`
+	Scoreboard []TeamRoomScoreboardItem `json:"scoreboard"`
+}
+
+type TeamRoomFreezeData struct {
+	RoomID   int64 `json:"room_id"`
+	FreezeAt int64 `json:"freeze_at"`
+	EndAt    int64 `json:"end_at"`
+}
+
+// TeamRoomRevealData Frozen 为封榜时的榜单，Final 为最终榜单
+type TeamRoomRevealData struct {
+	RoomID int64                    `json:"room_id"`
+	Frozen []TeamRoomScoreboardItem `json:"frozen"`
+	Steps  []TeamRoomRevealStep     `json:"steps"`
+	Final  []TeamRoomScoreboardItem `json:"final"`
+}
+
+type TeamRoomFinishData struct {
+	Room        TeamRoomInfo             `json:"room"`
+	AllSolved   bool                     `json:"all_solved"`
+	SolvedCount int                      `json:"solved_count"`
+	Teams       []TeamRoomScoreboardItem `json:"teams"`
+	Players     []TeamRoomPlayerStat     `json:"players"`
+}
+
+type WsCatalogResp struct {
+	Version    int             `json:"version"`
+	MinVersion int             `json:"min_version"`
+	Messages   []WsCatalogItem `json:"messages"`
+}
+
+// WsCatalogItem Direction 为 client（客户端发送）或 server（服务端推送），Since 为开始支持的协议版本
+type WsCatalogItem struct {
+	Type        string `json:"type"`
+	Direction   string `json:"direction"`
+	Payload     string `json:"payload,omitempty"`
+	Description string `json:"description"`
+	Since       int    `json:"since"`
+}